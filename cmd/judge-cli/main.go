@@ -0,0 +1,487 @@
+// judge-cli is a terminal client for the Judge JSON API (/api/v1).
+//
+// Usage:
+//
+//	judge-cli login -server https://host:8443 -user user_abc123 [-insecure]
+//	judge-cli problems
+//	judge-cli submit [-no-wait] [-timeout 5m] [problem letter or id] [file.cpp|file.py]
+//	judge-cli status [submission id]
+//	judge-cli standings
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Config is persisted as JSON in the user's config directory
+type Config struct {
+	Server   string `json:"server"`
+	Token    string `json:"token"`
+	Insecure bool   `json:"insecure"` // Accept self-signed certificates
+}
+
+type problem struct {
+	ID        int    `json:"id"`
+	Letter    string `json:"letter"`
+	TimeLimit int    `json:"time_limit_ms"`
+}
+
+type testResult struct {
+	Test     int    `json:"test"`
+	Verdict  string `json:"verdict"`
+	TimeMs   int    `json:"time_ms"`
+	MemoryKB int    `json:"memory_kb"`
+}
+
+type submission struct {
+	ID        int          `json:"id"`
+	Problem   string       `json:"problem"`
+	Status    string       `json:"status"`
+	Pending   bool         `json:"pending"`
+	CreatedAt time.Time    `json:"created_at"`
+	Tests     []testResult `json:"tests"`
+}
+
+type scoreboard struct {
+	Problems []struct {
+		Letter string `json:"letter"`
+	} `json:"problems"`
+	Rows []struct {
+		Rank        int    `json:"rank"`
+		DisplayName string `json:"display_name"`
+		Solved      int    `json:"solved"`
+		Penalty     int    `json:"penalty"`
+		Cells       map[string]struct {
+			Solved   bool `json:"solved"`
+			Attempts int  `json:"attempts"`
+			Pending  bool `json:"pending"`
+		} `json:"cells"`
+	} `json:"rows"`
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: judge-cli <command> [arguments]
+
+Commands:
+  login      Authenticate and store an API token
+  problems   List problems
+  submit     Submit a file and wait for the verdict
+  status     Show recent submissions, or one submission in detail
+  standings  Print the scoreboard
+
+Run "judge-cli <command> -h" for command flags.`)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case "login":
+		err = cmdLogin(args)
+	case "problems":
+		err = cmdProblems(args)
+	case "submit":
+		err = cmdSubmit(args)
+	case "status":
+		err = cmdStatus(args)
+	case "standings":
+		err = cmdStandings(args)
+	case "-h", "--help", "help":
+		usage()
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "judge-cli: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "judge-cli: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// --- Config ---
+
+func configPath() (string, error) {
+	if p := os.Getenv("JUDGE_CLI_CONFIG"); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "judge-cli", "config.json"), nil
+}
+
+func loadConfig() (*Config, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errors.New("not logged in (run: judge-cli login)")
+	} else if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("corrupt config %s: %v", path, err)
+	}
+	if cfg.Server == "" || cfg.Token == "" {
+		return nil, errors.New("not logged in (run: judge-cli login)")
+	}
+	return &cfg, nil
+}
+
+func saveConfig(cfg *Config) (string, error) {
+	path, err := configPath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	raw, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	// The token grants full account access: keep the file private
+	return path, os.WriteFile(path, raw, 0600)
+}
+
+// --- HTTP ---
+
+type client struct {
+	cfg  *Config
+	http *http.Client
+}
+
+func newClient(cfg *Config) *client {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.Insecure {
+		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &client{cfg: cfg, http: &http.Client{Transport: tr, Timeout: 30 * time.Second}}
+}
+
+// do sends a request and decodes a JSON response into out (if non-nil)
+func (c *client) do(method, path, contentType string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.cfg.Server, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	if c.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.Token)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("server: %s (HTTP %d)", apiErr.Error, resp.StatusCode)
+		}
+		return fmt.Errorf("server returned HTTP %d", resp.StatusCode)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// --- Commands ---
+
+func cmdLogin(args []string) error {
+	fs := flag.NewFlagSet("login", flag.ExitOnError)
+	server := fs.String("server", "https://localhost:8443", "Judge server base URL")
+	user := fs.String("user", "", "Username")
+	token := fs.String("token", "", "Use an existing API token (from the My Account page) instead of a password")
+	insecure := fs.Bool("insecure", false, "Skip TLS certificate verification (self-signed certs)")
+	fs.Parse(args)
+
+	cfg := &Config{Server: *server, Insecure: *insecure, Token: *token}
+	c := newClient(cfg)
+
+	if cfg.Token == "" {
+		if *user == "" {
+			return errors.New("either -user or -token is required")
+		}
+		password, err := readPassword()
+		if err != nil {
+			return err
+		}
+
+		host, _ := os.Hostname()
+		form := url.Values{
+			"username": {*user},
+			"password": {strings.TrimRight(password, "\r\n")},
+			"name":     {"judge-cli@" + host},
+		}
+		var resp struct {
+			Token string `json:"token"`
+		}
+		err = c.do(http.MethodPost, "/api/v1/login", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()), &resp)
+		if err != nil {
+			return err
+		}
+		cfg.Token = resp.Token
+	}
+
+	// Verify the token before persisting it
+	var problems []problem
+	if err := c.do(http.MethodGet, "/api/v1/problems", "", nil, &problems); err != nil {
+		return err
+	}
+
+	path, err := saveConfig(cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Logged in. Token saved to %s\n", path)
+	return nil
+}
+
+func cmdProblems(args []string) error {
+	fs := flag.NewFlagSet("problems", flag.ExitOnError)
+	fs.Parse(args)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	var problems []problem
+	if err := newClient(cfg).do(http.MethodGet, "/api/v1/problems", "", nil, &problems); err != nil {
+		return err
+	}
+	for _, p := range problems {
+		fmt.Printf("%-4s id=%-4d %dms\n", p.Letter, p.ID, p.TimeLimit)
+	}
+	return nil
+}
+
+func cmdSubmit(args []string) error {
+	fs := flag.NewFlagSet("submit", flag.ExitOnError)
+	noWait := fs.Bool("no-wait", false, "Return right after the upload instead of waiting for the verdict")
+	timeout := fs.Duration("timeout", 5*time.Minute, "Give up waiting for the verdict after this long")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: judge-cli submit [-no-wait] [-timeout 5m] <problem letter or id> <file.cpp|file.py>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	c := newClient(cfg)
+
+	problemID, err := resolveProblem(c, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	src, err := os.ReadFile(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("problem_id", strconv.Itoa(problemID))
+	part, err := mw.CreateFormFile("code", filepath.Base(fs.Arg(1)))
+	if err != nil {
+		return err
+	}
+	part.Write(src)
+	mw.Close()
+
+	var created struct {
+		ID int `json:"id"`
+	}
+	if err := c.do(http.MethodPost, "/api/v1/submissions", mw.FormDataContentType(), &body, &created); err != nil {
+		return err
+	}
+	fmt.Printf("Submitted #%d\n", created.ID)
+
+	if *noWait {
+		return nil
+	}
+
+	// Poll until the worker writes a final verdict
+	deadline := time.Now().Add(*timeout)
+	for {
+		var s submission
+		if err := c.do(http.MethodGet, fmt.Sprintf("/api/v1/submissions/%d", created.ID), "", nil, &s); err != nil {
+			return err
+		}
+		if !s.Pending {
+			printSubmission(&s)
+			if s.Status != "AC" {
+				os.Exit(1)
+			}
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("no verdict for #%d after %s; check later with: judge-cli status %d", created.ID, *timeout, created.ID)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// readPassword prompts on a terminal without echoing; piped input is read as a line
+func readPassword() (string, error) {
+	fmt.Fprint(os.Stderr, "Password: ")
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		password, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(password), err
+	}
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return password, nil
+}
+
+// resolveProblem accepts either a numeric ID or a letter code
+func resolveProblem(c *client, ref string) (int, error) {
+	var problems []problem
+	if err := c.do(http.MethodGet, "/api/v1/problems", "", nil, &problems); err != nil {
+		return 0, err
+	}
+	for _, p := range problems {
+		if strings.EqualFold(p.Letter, ref) || strconv.Itoa(p.ID) == ref {
+			return p.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown problem %q", ref)
+}
+
+func cmdStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: judge-cli status [submission id]")
+	}
+	fs.Parse(args)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	c := newClient(cfg)
+
+	if fs.NArg() > 0 {
+		id, err := strconv.Atoi(fs.Arg(0))
+		if err != nil {
+			return fmt.Errorf("invalid submission id %q", fs.Arg(0))
+		}
+		var s submission
+		if err := c.do(http.MethodGet, fmt.Sprintf("/api/v1/submissions/%d", id), "", nil, &s); err != nil {
+			return err
+		}
+		printSubmission(&s)
+		return nil
+	}
+
+	var subs []submission
+	if err := c.do(http.MethodGet, "/api/v1/submissions", "", nil, &subs); err != nil {
+		return err
+	}
+	fmt.Printf("%-6s %-8s %-20s %s\n", "ID", "PROBLEM", "VERDICT", "TIME")
+	for i, s := range subs {
+		if i == 20 {
+			break
+		}
+		fmt.Printf("%-6d %-8s %-20s %s\n", s.ID, s.Problem, s.Status, s.CreatedAt.Local().Format("15:04:05"))
+	}
+	return nil
+}
+
+func printSubmission(s *submission) {
+	fmt.Printf("Submission #%d (problem %s): %s\n", s.ID, s.Problem, s.Status)
+	for _, t := range s.Tests {
+		fmt.Printf("  test %-3d %-4s %5dms %7dKB\n", t.Test, t.Verdict, t.TimeMs, t.MemoryKB)
+	}
+}
+
+func cmdStandings(args []string) error {
+	fs := flag.NewFlagSet("standings", flag.ExitOnError)
+	fs.Parse(args)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	var board scoreboard
+	if err := newClient(cfg).do(http.MethodGet, "/api/v1/standings", "", nil, &board); err != nil {
+		return err
+	}
+
+	fmt.Printf("%-5s %-20s %-6s %-8s", "RANK", "USER", "SOLVED", "PENALTY")
+	for _, p := range board.Problems {
+		fmt.Printf(" %-4s", p.Letter)
+	}
+	fmt.Println()
+
+	for _, row := range board.Rows {
+		fmt.Printf("%-5d %-20s %-6d %-8d", row.Rank, row.DisplayName, row.Solved, row.Penalty)
+		for _, p := range board.Problems {
+			cell := row.Cells[p.Letter]
+			mark := ""
+			switch {
+			case cell.Solved && cell.Attempts > 0:
+				mark = "+" + strconv.Itoa(cell.Attempts)
+			case cell.Solved:
+				mark = "+"
+			case cell.Pending:
+				mark = "?"
+			case cell.Attempts > 0:
+				mark = "-" + strconv.Itoa(cell.Attempts)
+			}
+			fmt.Printf(" %-4s", mark)
+		}
+		fmt.Println()
+	}
+	return nil
+}
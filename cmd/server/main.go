@@ -1,98 +1,325 @@
 package main
 
 import (
-	"flag"
+	"context"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/ifuaslaerl/Judge/internal/auth"
+	"github.com/ifuaslaerl/Judge/internal/config"
 	"github.com/ifuaslaerl/Judge/internal/data"
 	"github.com/ifuaslaerl/Judge/internal/engine"
 	"github.com/ifuaslaerl/Judge/internal/handlers"
 	"github.com/ifuaslaerl/Judge/internal/middleware"
 	"github.com/ifuaslaerl/Judge/internal/tasks"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// --- Commands ---
+// judge <command> [flags] [args]; without a command the server starts.
+// "judge help <command>" and "judge <command> -h" show per-command help.
+// Failures exit with status 1, usage errors with 2.
+
+type command struct {
+	name  string // One or two words, e.g. "user add"
+	usage string // Flags and arguments
+	help  string
+	run   func(args []string)
+}
+
+var commands = []command{
+	{"serve", "", "Start the HTTPS server (the default when no command is given).", nil}, // nil: main calls serve, which needs the config
+	{"user add", "[-admin]", "Create a contestant (or admin) with random credentials and print them.", tasks.AddUser},
+	{"user list", "", "List all accounts with role and submission count.", tasks.ListUsers},
+	{"user reset", "<username>", "Set a new random password and revoke the user's sessions and API tokens.", tasks.ResetUser},
+	{"user delete", "[-yes] <username>", "Delete a user with their sessions, tokens and submissions. Asks for confirmation.", tasks.DeleteUser},
+	{"sessions flush", "", "Log every user out of the web interface.", tasks.FlushSessions},
+	{"wipe", "[-yes]", "DANGER: delete all submissions, users, sessions and API tokens (problems are kept). Asks for confirmation.", tasks.Wipe},
+	{"rejudge", "[-problem A] [-user name] [-status WA] [-all] [-yes] [ID...]", "Judge the selected submissions again. The running server picks them up within seconds.", tasks.Rejudge},
+	{"problem add", "[-letter C] [-name Title] [-pdf file] -time-limit ms", "Create an empty problem and its storage directory.", tasks.AddProblem},
+	{"problem list", "", "List problems with test counts, checker and statement.", tasks.ListProblems},
+	{"problem import", "[-letter C] [-time-limit ms] <package>", "Install a Polygon package (zip or directory) or a Kattis problem directory.", tasks.ImportProblem},
+	{"bake", "<id> [seed count]", "Generate tests: from testplan.txt, or count tests from generator.py with seeds seed+1..seed+count.", tasks.BakeTests},
+	{"validate-tests", "<id>", "Check every test input of a problem with its validator.", tasks.ValidateTests},
+	{"export", "[-o contest.zip] [-problems A,B] [-users] [-submissions]", "Bundle problems (and optionally users and submissions) into one archive.", tasks.ExportArchive},
+	{"import", "<contest.zip>", "Restore an archive made with export into an empty server.", tasks.ImportArchive},
+	{"export-feed", "[-o file] [-start time] [-duration d] [-freeze d] [-penalty min] [-name n]", "Write the CLICS event feed (for the ICPC Resolver).", tasks.ExportEventFeed},
+	{"export-standings", "[-format csv|json|html] [-o file]", "Write the standings.", tasks.ExportStandings},
+	{"audit-log", "[-actor name] [-action a] [-since 24h] [-n 50]", "Show the audit log, newest first.", tasks.ShowAuditLog},
+	{"migrate", "", "Apply pending database migrations (the server also does this at startup).", tasks.Migrate},
+	{"migrate status", "", "List database migrations and whether they are applied.", tasks.MigrateStatus},
+}
+
+// Commands that open the database without applying migrations first
+var rawDB = map[string]bool{"migrate": true, "migrate status": true}
+
+// Old spellings, still accepted
+var aliases = map[string]string{
+	"import-problem":   "problem import",
+	"--flush-sessions": "sessions flush",
+	"-flush-sessions":  "sessions flush",
+	"--wipe-all":       "wipe",
+	"-wipe-all":        "wipe",
+	"--add-user":       "user add",
+	"-add-user":        "user add",
+	"--add-admin":      "user add -admin",
+	"-add-admin":       "user add -admin",
+}
+
+// findCommand matches the longest command name at the start of args
+func findCommand(args []string) (*command, []string) {
+	if len(args) > 0 {
+		if alias, ok := aliases[args[0]]; ok {
+			log.Printf("NOTE: %q is deprecated, use: judge %s", args[0], alias)
+			args = append(strings.Fields(alias), args[1:]...)
+		}
+	}
+	var best *command
+	bestLen := 0
+	for i := range commands {
+		words := strings.Fields(commands[i].name)
+		if len(words) <= bestLen || len(args) < len(words) || strings.Join(args[:len(words)], " ") != commands[i].name {
+			continue
+		}
+		best, bestLen = &commands[i], len(words)
+	}
+	return best, args[bestLen:]
+}
+
+func printCommandHelp(c *command) {
+	fmt.Printf("Usage: judge %s %s\n\n%s\n", c.name, c.usage, c.help)
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: judge <command> [flags] [args]\n\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-18s %s\n", c.name, c.help)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun \"judge help <command>\" for its flags and arguments.")
+}
+
+func isHelp(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help"
+}
+
 func main() {
-	// 1. Initialize Database & Queue
-	data.InitDB()
-	engine.InitQueue()
-	defer data.DB.Close()
+	args := os.Args[1:]
 
-	// 2. Parse CLI Flags
-	flushCmd := flag.Bool("flush-sessions", false, "Truncate the Sessions table and exit")
-	wipeCmd := flag.Bool("wipe-all", false, "DANGER: Delete all submissions, users, and sessions")
-	// --- PHASE 8 ADDITION ---
-	addUserCmd := flag.Bool("add-user", false, "Generate a new user with random credentials")
-	
-	// Phase 8 Step 5: Bake Flag
-	// We cannot use strict boolean flag for bake because it takes arguments.
-	// Instead, we check os.Args manually or use a specific subcommand logic.
-	// For simplicity in this structure, let's look for "bake" in os.Args.
-    
-	if len(os.Args) > 1 && os.Args[1] == "bake" {
-	   // Usage: go run . bake [id] [seed] [count]
-	   // Shift args
-           tasks.BakeTests(os.Args[2:])
-	   os.Exit(0)
-	}
-
-	flag.Parse()
-
-	// 3. Execute CLI Command if requested
-	if *flushCmd {
-		log.Println("EXECUTING: Flushing all active sessions...")
-		auth.FlushSessions()
+	// Help needs neither config nor database
+	if len(args) > 0 && (args[0] == "help" || isHelp(args[0])) {
+		if len(args) == 1 || isHelp(args[0]) {
+			printUsage()
+			os.Exit(0)
+		}
+		c, _ := findCommand(args[1:])
+		if c == nil {
+			printUsage()
+			os.Exit(2)
+		}
+		printCommandHelp(c)
 		os.Exit(0)
 	}
 
-	if *wipeCmd {
-		log.Println("EXECUTING: Weekly Wipe (Factory Reset)...")
-		tasks.WipeAll()
+	c := &commands[0] // serve
+	if len(args) > 0 {
+		var rest []string
+		if c, rest = findCommand(args); c == nil {
+			fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", strings.Join(args, " "))
+			printUsage()
+			os.Exit(2)
+		}
+		args = rest
+	}
+	if len(args) > 0 && isHelp(args[0]) {
+		printCommandHelp(c)
 		os.Exit(0)
 	}
 
-	// --- PHASE 8 ADDITION ---
-	if *addUserCmd {
-		tasks.AddUser()
-		os.Exit(0)
+	// 0. Configuration (judge.toml or $JUDGE_CONFIG, JUDGE_* env overrides)
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("CONFIG ERROR: %v", err)
+	}
+
+	// 1. Initialize Database & Queue
+	if rawDB[c.name] {
+		data.OpenDB(cfg.Storage.Database)
+		defer data.DB.Close()
+		c.run(args)
+		return
+	}
+	data.InitDB(cfg.Storage.Database)
+	engine.InitQueue(cfg.Limits.QueueSize)
+	engine.SetSandbox(cfg.Sandbox)
+	handlers.Configure(cfg.Limits)
+	defer data.DB.Close()
+
+	if err := engine.ConfigureScoring(cfg.Scoring); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// 2. Run the command
+	if c.run == nil {
+		serve(cfg, data.NewSQLiteStores(data.DB), args)
+		return
+	}
+	c.run(args)
+}
+
+// serve starts the background tasks and the HTTPS server on top of stores
+func serve(cfg *config.Config, stores *data.Stores, args []string) {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "Usage: judge serve")
+		os.Exit(2)
 	}
 
+	judge := engine.NewJudge(stores)
+	authService := auth.NewService(stores)
+	authService.RegisterMetrics()
+	h := handlers.New(stores, judge, authService)
+	guard := middleware.NewGuard(authService)
+
 	// Start Background Tasks
 	tasks.StartReaper()
-	go engine.StartWorker()
+	if err := judge.RebuildScoreboard(); err != nil {
+		log.Printf("SCOREBOARD WARNING: Initial build failed (will retry on first read): %v", err)
+	}
+	if err := judge.LoadInternalErrors(); err != nil {
+		log.Printf("HEALTH WARNING: Could not load earlier IE verdicts: %v", err)
+	}
+	go judge.StartWorker()
+	// Pending submissions from before a restart and rejudges from the CLI
+	judge.StartPendingSweep(5 * time.Second)
 
 	// 4. Server Setup
 	// Public
-	http.HandleFunc("/login", handlers.HandleLogin)
+	http.HandleFunc("/login", h.HandleLogin)
 
 	// Protected (Wrap Handlers with Middleware)
-	http.HandleFunc("/dashboard", middleware.AuthMiddleware(handlers.HandleDashboard))
-	http.HandleFunc("/status", middleware.AuthMiddleware(handlers.HandleStatus))
-	http.HandleFunc("/submit/", middleware.AuthMiddleware(handlers.HandleSubmission))
-	http.HandleFunc("/problems/", middleware.AuthMiddleware(handlers.HandlePDF))
-	
+	http.HandleFunc("/dashboard", guard.AuthMiddleware(h.HandleDashboard))
+	http.HandleFunc("/status", guard.AuthMiddleware(h.HandleStatus))
+	http.HandleFunc("/submit/", guard.AuthMiddleware(h.HandleSubmission))
+	http.HandleFunc("/submission/", guard.AuthMiddleware(h.HandleSubmissionDetail))
+	http.HandleFunc("/problems/", guard.AuthMiddleware(h.HandlePDF))
+
 	// Phase 8 Additions
-	http.HandleFunc("/problems/all", middleware.AuthMiddleware(handlers.HandleManualBook))
-	http.HandleFunc("/problems/view/", middleware.AuthMiddleware(handlers.HandleProblemView))
-    
-    	// Phase 8 Step 4 Addition:
-    	http.HandleFunc("/standings", middleware.AuthMiddleware(handlers.HandleStandings))
+	http.HandleFunc("/problems/all", guard.AuthMiddleware(h.HandleManualBook))
+	http.HandleFunc("/problems/view/", guard.AuthMiddleware(h.HandleProblemView))
+
+	// Phase 8 Step 4 Addition:
+	http.HandleFunc("/standings", guard.AuthMiddleware(h.HandleStandings))
+
+	// Custom Invocation (run without judging)
+	http.HandleFunc("/run/", guard.AuthMiddleware(h.HandleRun))
+
+	// Admin
+	http.HandleFunc("/admin/standings/export", guard.AuthMiddleware(guard.AdminMiddleware(h.HandleStandingsExport)))
+	http.HandleFunc("/admin/balloons", guard.AuthMiddleware(guard.AdminMiddleware(h.HandleBalloons)))
+	http.HandleFunc("/admin/audit", guard.AuthMiddleware(guard.AdminMiddleware(h.HandleAuditLog)))
+	http.HandleFunc("/admin/health", guard.AuthMiddleware(guard.AdminMiddleware(h.HandleHealth)))
+	http.HandleFunc("/admin/submissions", guard.AuthMiddleware(guard.AdminMiddleware(h.HandleAdminSubmissions)))
+	http.HandleFunc("/admin/submissions/", guard.AuthMiddleware(guard.AdminMiddleware(h.HandleAdminSubmissions)))
+
+	// Live Updates (Server-Sent Events)
+	http.HandleFunc("/events", guard.AuthMiddleware(h.HandleEvents))
+
+	// Account & API Tokens
+	http.HandleFunc("/account", guard.AuthMiddleware(h.HandleAccount))
+	http.HandleFunc("/account/tokens", guard.AuthMiddleware(h.HandleAccountTokens))
+	http.HandleFunc("/account/tokens/", guard.AuthMiddleware(h.HandleAccountTokens))
+
+	// JSON API (Bearer token auth; /api/v1/login issues tokens)
+	http.HandleFunc("/api/v1/login", h.HandleAPILogin)
+	http.HandleFunc("/api/v1/problems", guard.APITokenMiddleware(h.HandleAPIProblems))
+	http.HandleFunc("/api/v1/problems/", guard.APITokenMiddleware(h.HandleAPIProblems))
+	http.HandleFunc("/api/v1/submissions", guard.APITokenMiddleware(h.HandleAPISubmissions))
+	http.HandleFunc("/api/v1/submissions/", guard.APITokenMiddleware(h.HandleAPISubmissions))
+	http.HandleFunc("/api/v1/standings", guard.APITokenMiddleware(h.HandleAPIStandings))
+	http.HandleFunc("/api/v1/admin/event-feed", guard.APITokenMiddleware(guard.AdminMiddleware(h.HandleEventFeed)))
+
+	// Prometheus metrics (scrape with an admin's API token as bearer credentials)
+	http.HandleFunc("/metrics", guard.APITokenMiddleware(guard.AdminMiddleware(promhttp.Handler().ServeHTTP)))
 
 	// Root Redirect
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
 	})
 
-	// Port & Certs
-	port := ":8443"
-	certFile := "certs/server.crt"
-	keyFile := "certs/server.key"
+	// Listener (config [server]); X-Forwarded-* only from trusted proxies (config [proxy])
+	if err := middleware.ConfigureProxy(cfg.Proxy, cfg.Server.Mode == config.ModeUnix); err != nil {
+		log.Fatalf("CONFIG ERROR: %v", err)
+	}
+	srv := &http.Server{Addr: cfg.Server.Addr, Handler: middleware.ProxyHeaders(middleware.Metrics(http.DefaultServeMux))}
+	srv.RegisterOnShutdown(handlers.CloseEventStreams)
 
-	log.Printf("Starting secure server on https://localhost%s", port)
-	err := http.ListenAndServeTLS(port, certFile, keyFile, nil)
-	if err != nil {
+	// SIGINT/SIGTERM start a graceful shutdown; a second signal kills the process
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- listen(srv, cfg.Server)
+	}()
+
+	select {
+	case err := <-errc:
 		log.Fatalf("Failed to start server: %v", err)
+	case <-ctx.Done():
+	}
+	stop()
+	shutdown(srv, judge, cfg.Server.ShutdownTimeout())
+}
+
+// listen serves in the configured mode until the server is shut down
+func listen(srv *http.Server, cfg config.Server) error {
+	switch cfg.Mode {
+	case config.ModeHTTP:
+		log.Printf("Starting plain HTTP server on http://localhost%s (put a TLS proxy in front)", cfg.Addr)
+		return srv.ListenAndServe()
+	case config.ModeUnix:
+		// A socket left by an unclean exit would make Listen fail
+		if info, err := os.Stat(cfg.Addr); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(cfg.Addr)
+		}
+		ln, err := net.Listen("unix", cfg.Addr)
+		if err != nil {
+			return err
+		}
+		if err := os.Chmod(cfg.Addr, 0660); err != nil {
+			return err
+		}
+		log.Printf("Starting plain HTTP server on unix socket %s", cfg.Addr)
+		return srv.Serve(ln)
+	default:
+		log.Printf("Starting secure server on https://localhost%s", cfg.Addr)
+		return srv.ListenAndServeTLS(cfg.CertFile, cfg.KeyFile)
 	}
 }
+
+// shutdown stops accepting requests and waits for those in flight, lets the
+// worker finish (or abandon) the submission being judged and cleans up the
+// sandbox. Both waits share one deadline, so the whole shutdown takes at most
+// timeout (plus the test being run when judging is abandoned). main closes the
+// database once it returns.
+func shutdown(srv *http.Server, judge *engine.Judge, timeout time.Duration) {
+	log.Printf("SHUTDOWN: Signal received, waiting up to %s for requests and judging", timeout)
+
+	deadline := time.Now().Add(timeout)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("SHUTDOWN WARNING: Closing requests still running: %v", err)
+		srv.Close()
+	}
+
+	judge.StopWorker(max(time.Until(deadline), 0))
+	engine.CleanupSandboxes()
+	log.Println("SHUTDOWN: Complete.")
+}
@@ -0,0 +1,504 @@
+// Package archive moves contests between servers: problems (with statements,
+// tests, checkers, generators and reference solutions) and optionally users
+// and submissions, bundled into a single zip.
+package archive
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ifuaslaerl/Judge/internal/data"
+	"github.com/ifuaslaerl/Judge/internal/importer"
+)
+
+// Layout inside the zip:
+//   manifest.json              Everything stored in the database
+//   problems/[id]/...          Copy of storage/problems/[id] (compiled binaries excluded)
+//   statements/[id].pdf        Problem statement
+//   submissions/[id].cpp|.py   Sources (only with submissions)
+// Original IDs are kept, which is why restoring needs an empty database.
+
+const manifestVersion = 1
+
+type Manifest struct {
+	Version     int                `json:"version"`
+	Created     time.Time          `json:"created"`
+	Problems    []ProblemRecord    `json:"problems"`
+	Users       []UserRecord       `json:"users,omitempty"`
+	Submissions []SubmissionRecord `json:"submissions,omitempty"`
+}
+
+type ProblemRecord struct {
+	ID          int    `json:"id"`
+	Letter      string `json:"letter"`
+	Name        string `json:"name"`
+	TimeLimitMs int    `json:"time_limit_ms"`
+	Color       string `json:"color"`
+	Statement   string `json:"statement,omitempty"` // Path inside the archive
+}
+
+type UserRecord struct {
+	ID           int    `json:"id"`
+	Username     string `json:"username"`
+	DisplayName  string `json:"display_name"`
+	PasswordHash string `json:"password_hash"`
+	IsAdmin      bool   `json:"is_admin"`
+}
+
+type SubmissionRecord struct {
+	ID        int            `json:"id"`
+	UserID    int            `json:"user_id"`
+	ProblemID int            `json:"problem_id"`
+	Status    string         `json:"status"`
+	Created   time.Time      `json:"created_at"`
+	Judged    *time.Time     `json:"judged_at,omitempty"`
+	Source    string         `json:"source,omitempty"` // Path inside the archive
+	Compile   string         `json:"compile_output,omitempty"`
+	Tests     []TestRecord   `json:"tests,omitempty"`
+	Balloon   *BalloonRecord `json:"balloon,omitempty"`
+}
+
+type TestRecord struct {
+	Test     int    `json:"test"`
+	Verdict  string `json:"verdict"`
+	TimeMs   int    `json:"time_ms"`
+	MemoryKB int    `json:"memory_kb"`
+	Sample   bool   `json:"sample"`
+	Output   string `json:"output,omitempty"`
+}
+
+// BalloonRecord is the balloon queue state of an AC (only once it was toggled)
+type BalloonRecord struct {
+	Delivered   bool       `json:"delivered"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+}
+
+type ExportOptions struct {
+	Letters     []string // Empty: all problems
+	Users       bool
+	Submissions bool // Implies Users (submissions reference them)
+}
+
+// Files rebuilt on demand that are not worth shipping
+var skipFiles = map[string]bool{
+	"solution_exec":   true, // bake
+	"validator_exec":  true, // bake / validate-tests
+	"checker/checker": true, // engine.LoadChecker
+}
+
+// --- Export ---
+
+func Export(w io.Writer, opts ExportOptions) (*Manifest, error) {
+	if opts.Submissions {
+		opts.Users = true
+	}
+	m := &Manifest{Version: manifestVersion, Created: time.Now().UTC()}
+	zw := zip.NewWriter(w)
+
+	// 1. Problems
+	rows, err := data.DB.Query("SELECT id, letter_code, name, time_limit, color, pdf_path FROM problems ORDER BY letter_code")
+	if err != nil {
+		return nil, err
+	}
+	type problemRow struct {
+		rec     ProblemRecord
+		pdfPath string
+	}
+	var problems []problemRow
+	for rows.Next() {
+		var p problemRow
+		if err := rows.Scan(&p.rec.ID, &p.rec.Letter, &p.rec.Name, &p.rec.TimeLimitMs, &p.rec.Color, &p.pdfPath); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if len(opts.Letters) == 0 || containsString(opts.Letters, p.rec.Letter) {
+			problems = append(problems, p)
+		}
+	}
+	rows.Close()
+
+	if len(problems) < len(opts.Letters) {
+		return nil, fmt.Errorf("some of the problems %v do not exist", opts.Letters)
+	}
+
+	selected := make(map[int]bool)
+	for _, p := range problems {
+		selected[p.rec.ID] = true
+		id := strconv.Itoa(p.rec.ID)
+
+		if p.pdfPath != "" {
+			if _, err := os.Stat(p.pdfPath); err == nil {
+				p.rec.Statement = "statements/" + id + ".pdf"
+				if err := addFile(zw, p.rec.Statement, p.pdfPath); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		dir := filepath.Join("storage", "problems", id)
+		err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+			if os.IsNotExist(err) && file == dir {
+				return filepath.SkipDir // Problem without tests
+			}
+			if err != nil || info.IsDir() {
+				return err
+			}
+			rel, _ := filepath.Rel(dir, file)
+			rel = filepath.ToSlash(rel)
+			if skipFiles[rel] || file == filepath.Clean(p.pdfPath) {
+				return nil
+			}
+			return addFile(zw, "problems/"+id+"/"+rel, file)
+		})
+		if err != nil {
+			return nil, err
+		}
+		m.Problems = append(m.Problems, p.rec)
+	}
+
+	// 2. Users (passwords stay hashed; sessions and API tokens are not exported)
+	if opts.Users {
+		rows, err := data.DB.Query("SELECT id, username, COALESCE(display_name, ''), password_hash, is_admin FROM users ORDER BY id")
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var u UserRecord
+			if err := rows.Scan(&u.ID, &u.Username, &u.DisplayName, &u.PasswordHash, &u.IsAdmin); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			m.Users = append(m.Users, u)
+		}
+		rows.Close()
+	}
+
+	// 3. Submissions of the selected problems, with per-test results
+	if opts.Submissions {
+		if err := exportSubmissions(zw, m, selected); err != nil {
+			return nil, err
+		}
+	}
+
+	mw, err := zw.Create("manifest.json")
+	if err != nil {
+		return nil, err
+	}
+	enc := json.NewEncoder(mw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return nil, err
+	}
+	return m, zw.Close()
+}
+
+func exportSubmissions(zw *zip.Writer, m *Manifest, selected map[int]bool) error {
+	tests := make(map[int][]TestRecord)
+	rows, err := data.DB.Query("SELECT submission_id, test_number, verdict, time_ms, memory_kb, sample, output FROM submission_tests ORDER BY submission_id, test_number")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var subID int
+		var t TestRecord
+		if err := rows.Scan(&subID, &t.Test, &t.Verdict, &t.TimeMs, &t.MemoryKB, &t.Sample, &t.Output); err != nil {
+			rows.Close()
+			return err
+		}
+		tests[subID] = append(tests[subID], t)
+	}
+	rows.Close()
+
+	balloons := make(map[int]*BalloonRecord)
+	rows, err = data.DB.Query("SELECT submission_id, delivered, delivered_at FROM balloons")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var subID int
+		var b BalloonRecord
+		var at sql.NullTime
+		if err := rows.Scan(&subID, &b.Delivered, &at); err != nil {
+			rows.Close()
+			return err
+		}
+		if at.Valid {
+			b.DeliveredAt = &at.Time
+		}
+		balloons[subID] = &b
+	}
+	rows.Close()
+
+	rows, err = data.DB.Query("SELECT id, user_id, problem_id, status, file_path, created_at, judged_at, compile_output FROM submissions ORDER BY id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s SubmissionRecord
+		var filePath string
+		var judged sql.NullTime
+		if err := rows.Scan(&s.ID, &s.UserID, &s.ProblemID, &s.Status, &filePath, &s.Created, &judged, &s.Compile); err != nil {
+			return err
+		}
+		if !selected[s.ProblemID] {
+			continue
+		}
+		if judged.Valid {
+			s.Judged = &judged.Time
+		}
+		if filePath != "" {
+			if _, err := os.Stat(filePath); err == nil {
+				s.Source = "submissions/" + strconv.Itoa(s.ID) + filepath.Ext(filePath)
+				if err := addFile(zw, s.Source, filePath); err != nil {
+					return err
+				}
+			}
+		}
+		s.Tests = tests[s.ID]
+		s.Balloon = balloons[s.ID]
+		m.Submissions = append(m.Submissions, s)
+	}
+	return rows.Err()
+}
+
+func addFile(zw *zip.Writer, name, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// --- Import ---
+
+// Import restores an archive into an empty database and storage/.
+// Either everything is restored or nothing is (rows and files are rolled back).
+func Import(archivePath string) (*Manifest, error) {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	// Same bound as problem packages. The zip reader fails on entries that
+	// inflate past their declared size, so the declared sizes can be trusted.
+	files := make(map[string]*zip.File)
+	var total uint64
+	for _, f := range zr.File {
+		files[f.Name] = f
+		total += f.UncompressedSize64
+	}
+	if total > importer.MaxExtractSize {
+		return nil, fmt.Errorf("archive is too large (%d MB uncompressed, limit %d MB)", total>>20, importer.MaxExtractSize>>20)
+	}
+
+	// 1. Manifest
+	mf, ok := files["manifest.json"]
+	if !ok {
+		return nil, errors.New("not a contest archive: manifest.json is missing")
+	}
+	rc, err := mf.Open()
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	err = json.NewDecoder(rc).Decode(&m)
+	rc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("manifest.json: %v", err)
+	}
+	if m.Version != manifestVersion {
+		return nil, fmt.Errorf("unsupported archive version %d", m.Version)
+	}
+
+	// 2. Target must be fresh: IDs are restored as-is
+	var count int
+	data.DB.QueryRow("SELECT (SELECT COUNT(*) FROM problems) + (SELECT COUNT(*) FROM users) + (SELECT COUNT(*) FROM submissions)").Scan(&count)
+	if count > 0 {
+		return nil, errors.New("database is not empty (run `judge wipe` and remove existing problems first)")
+	}
+	for _, p := range m.Problems {
+		if _, err := os.Stat(filepath.Join("storage", "problems", strconv.Itoa(p.ID))); err == nil {
+			return nil, fmt.Errorf("storage/problems/%d already exists", p.ID)
+		}
+	}
+
+	var written []string // Rolled back on failure
+	fail := func(tx *sql.Tx, cause error) (*Manifest, error) {
+		tx.Rollback()
+		for i := len(written) - 1; i >= 0; i-- {
+			os.RemoveAll(written[i])
+		}
+		return nil, cause
+	}
+
+	tx, err := data.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Users
+	for _, u := range m.Users {
+		_, err := tx.Exec("INSERT INTO users (id, username, display_name, password_hash, is_admin) VALUES (?, ?, ?, ?, ?)",
+			u.ID, u.Username, u.DisplayName, u.PasswordHash, u.IsAdmin)
+		if err != nil {
+			return fail(tx, fmt.Errorf("user %s: %v", u.Username, err))
+		}
+	}
+
+	// 4. Problems: statement and storage/problems/[id]
+	for _, p := range m.Problems {
+		id := strconv.Itoa(p.ID)
+		dir := filepath.Join("storage", "problems", id)
+		written = append(written, dir)
+
+		prefix := "problems/" + id + "/"
+		for name, f := range files {
+			if !strings.HasPrefix(name, prefix) || strings.HasSuffix(name, "/") {
+				continue
+			}
+			target, err := safeJoin(dir, strings.TrimPrefix(name, prefix))
+			if err != nil {
+				return fail(tx, err)
+			}
+			if err := extractFile(f, target); err != nil {
+				return fail(tx, err)
+			}
+		}
+
+		pdfPath := ""
+		if p.Statement != "" {
+			f, ok := files[p.Statement]
+			if !ok {
+				return fail(tx, fmt.Errorf("statement %s is missing from the archive", p.Statement))
+			}
+			pdfPath = filepath.Join(dir, "statement.pdf")
+			if err := extractFile(f, pdfPath); err != nil {
+				return fail(tx, err)
+			}
+		}
+
+		_, err := tx.Exec("INSERT INTO problems (id, letter_code, name, time_limit, color, pdf_path) VALUES (?, ?, ?, ?, ?, ?)",
+			p.ID, p.Letter, p.Name, p.TimeLimitMs, p.Color, pdfPath)
+		if err != nil {
+			return fail(tx, fmt.Errorf("problem %s: %v", p.Letter, err))
+		}
+	}
+
+	// 5. Submissions and per-test results
+	for _, s := range m.Submissions {
+		filePath := ""
+		if s.Source != "" {
+			f, ok := files[s.Source]
+			if !ok {
+				return fail(tx, fmt.Errorf("source %s is missing from the archive", s.Source))
+			}
+			filePath = filepath.Join("storage", "submissions", strconv.Itoa(s.ID)+path.Ext(s.Source))
+			if _, err := os.Stat(filePath); err == nil {
+				return fail(tx, fmt.Errorf("%s already exists", filePath))
+			}
+			written = append(written, filePath)
+			if err := extractFile(f, filePath); err != nil {
+				return fail(tx, err)
+			}
+		}
+
+		var judged interface{}
+		if s.Judged != nil {
+			judged = sqliteTime(*s.Judged)
+		}
+		_, err := tx.Exec("INSERT INTO submissions (id, user_id, problem_id, status, file_path, created_at, judged_at, compile_output) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			s.ID, s.UserID, s.ProblemID, s.Status, filePath, sqliteTime(s.Created), judged, s.Compile)
+		if err != nil {
+			return fail(tx, fmt.Errorf("submission %d: %v", s.ID, err))
+		}
+		for _, t := range s.Tests {
+			_, err := tx.Exec("INSERT INTO submission_tests (submission_id, test_number, verdict, time_ms, memory_kb, sample, output) VALUES (?, ?, ?, ?, ?, ?, ?)",
+				s.ID, t.Test, t.Verdict, t.TimeMs, t.MemoryKB, t.Sample, t.Output)
+			if err != nil {
+				return fail(tx, fmt.Errorf("submission %d test %d: %v", s.ID, t.Test, err))
+			}
+		}
+		if b := s.Balloon; b != nil {
+			var at interface{}
+			if b.DeliveredAt != nil {
+				at = sqliteTime(*b.DeliveredAt)
+			}
+			_, err := tx.Exec("INSERT INTO balloons (submission_id, delivered, delivered_at) VALUES (?, ?, ?)", s.ID, b.Delivered, at)
+			if err != nil {
+				return fail(tx, fmt.Errorf("submission %d balloon: %v", s.ID, err))
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fail(tx, err)
+	}
+	return &m, nil
+}
+
+// sqliteTime matches the CURRENT_TIMESTAMP format used for rows created by the server
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// safeJoin rejects archive entries that would escape dir
+func safeJoin(dir, name string) (string, error) {
+	target := filepath.Join(dir, filepath.FromSlash(name))
+	if !strings.HasPrefix(target, filepath.Clean(dir)+string(os.PathSeparator)) {
+		return "", fmt.Errorf("illegal path in archive: %s", name)
+	}
+	return target, nil
+}
+
+func extractFile(f *zip.File, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(out, io.LimitReader(rc, importer.MaxExtractSize+1))
+	if err == nil && n > importer.MaxExtractSize {
+		err = fmt.Errorf("%s is too large", f.Name)
+	}
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
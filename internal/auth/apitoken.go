@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/ifuaslaerl/Judge/internal/data"
+)
+
+// hashToken returns the SHA-256 hex digest stored in place of the raw token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIToken generates a new bearer token for a user.
+// The plaintext token is returned once; only its hash is persisted.
+func (a *Service) CreateAPIToken(userID int, name string) (string, error) {
+	token := GenerateSecureToken()
+	if err := a.stores.Tokens.CreateToken(userID, name, hashToken(token)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ReplaceAPIToken is CreateAPIToken for logins: it revokes the user's earlier
+// tokens with the same name, so each client keeps one token
+func (a *Service) ReplaceAPIToken(userID int, name string) (string, error) {
+	token := GenerateSecureToken()
+	if err := a.stores.Tokens.ReplaceToken(userID, name, hashToken(token)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// GetUserFromAPIToken validates a bearer token and returns the user ID
+func (a *Service) GetUserFromAPIToken(token string) (int, bool) {
+	userID, err := a.stores.Tokens.TokenUser(hashToken(token))
+	if err != nil {
+		return 0, false
+	}
+	return userID, true
+}
+
+// ListAPITokens returns the tokens owned by a user, newest first
+func (a *Service) ListAPITokens(userID int) ([]data.APIToken, error) {
+	return a.stores.Tokens.Tokens(userID)
+}
+
+// RevokeAPIToken deletes a token, scoped to its owner
+func (a *Service) RevokeAPIToken(userID, tokenID int) error {
+	return a.stores.Tokens.RevokeToken(userID, tokenID)
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/hex"
 	"log"
 	"github.com/ifuaslaerl/Judge/internal/data"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
 // GenerateSecureToken creates a random 32-byte hex string
@@ -16,26 +18,44 @@ func GenerateSecureToken() string {
 	return hex.EncodeToString(b)
 }
 
-// CreateSession generates a token for a user and saves it to the DB
-func CreateSession(userID int) (string, error) {
-	token := GenerateSecureToken()
+// Service checks credentials against its stores (data.NewSQLiteStores in
+// production): users, web sessions and API tokens
+type Service struct {
+	stores *data.Stores
+}
 
-	// Insert into sessions table
-	// We use OR REPLACE or simple INSERT. Since token is PK, collision is virtually impossible.
-	query := `INSERT INTO sessions (token, user_id) VALUES (?, ?)`
-	_, err := data.DB.Exec(query, token, userID)
-	if err != nil {
+func NewService(s *data.Stores) *Service {
+	return &Service{stores: s}
+}
+
+// RegisterMetrics exports judge_active_sessions; the server calls it once
+func (a *Service) RegisterMetrics() {
+	// Logged-in browser sessions (cookies never expire, so this only drops on flush/reset)
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "judge_active_sessions",
+		Help: "Web sessions in the database.",
+	}, func() float64 {
+		n, err := a.stores.Sessions.CountSessions()
+		if err != nil {
+			return 0
+		}
+		return float64(n)
+	})
+}
+
+// CreateSession generates a token for a user and saves it
+func (a *Service) CreateSession(userID int) (string, error) {
+	// Since token is the key, collision is virtually impossible.
+	token := GenerateSecureToken()
+	if err := a.stores.Sessions.CreateSession(token, userID); err != nil {
 		return "", err
 	}
 	return token, nil
 }
 
 // GetUserFromSession validates a token and returns the user ID
-func GetUserFromSession(token string) (int, bool) {
-	var userID int
-	query := `SELECT user_id FROM sessions WHERE token = ?`
-	
-	err := data.DB.QueryRow(query, token).Scan(&userID)
+func (a *Service) GetUserFromSession(token string) (int, bool) {
+	userID, err := a.stores.Sessions.SessionUser(token)
 	if err != nil {
 		return 0, false // Token not found or error
 	}
@@ -43,11 +63,24 @@ func GetUserFromSession(token string) (int, bool) {
 }
 
 // FlushSessions deletes all active session tokens (Admin CLI)
-func FlushSessions() {
-	query := `DELETE FROM sessions`
-	_, err := data.DB.Exec(query)
-	if err != nil {
+func (a *Service) FlushSessions() {
+	if err := a.stores.Sessions.FlushSessions(); err != nil {
 		log.Fatalf("Failed to flush sessions: %v", err)
 	}
 	log.Println("SUCCESS: All sessions have been flushed. Users must log in again.")
 }
+
+// LookupUser returns the ID of the user with this username
+func (a *Service) LookupUser(username string) (int, bool) {
+	u, err := a.stores.Users.UserByUsername(username)
+	if err != nil {
+		return 0, false
+	}
+	return u.ID, true
+}
+
+// IsAdmin reports whether the user has the admin (judge) role
+func (a *Service) IsAdmin(userID int) bool {
+	u, err := a.stores.Users.User(userID)
+	return err == nil && u.IsAdmin
+}
@@ -0,0 +1,270 @@
+// Package config holds the server settings: listen address, certificates,
+// database path, submission and upload limits, queue size, sandbox limits,
+// scoring rules and the reverse-proxy setup. They are read once at boot from a
+// TOML file (judge.toml by default) and JUDGE_* environment variables, which win.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// DefaultPath is read when JUDGE_CONFIG is not set; it may be absent
+const DefaultPath = "judge.toml"
+
+type Config struct {
+	Server  Server  `toml:"server"`
+	Storage Storage `toml:"storage"`
+	Limits  Limits  `toml:"limits"`
+	Sandbox Sandbox `toml:"sandbox"`
+	Scoring Scoring `toml:"scoring"`
+	Proxy   Proxy   `toml:"proxy"`
+}
+
+// Listen modes
+const (
+	ModeTLS  = "tls"  // HTTPS with cert_file/key_file
+	ModeHTTP = "http" // Plain HTTP, behind a proxy that terminates TLS
+	ModeUnix = "unix" // Plain HTTP on the Unix socket at addr
+)
+
+type Server struct {
+	Mode            string `toml:"mode"`
+	Addr            string `toml:"addr"` // host:port, or the socket path in unix mode
+	CertFile        string `toml:"cert_file"`
+	KeyFile         string `toml:"key_file"`
+	ShutdownSeconds int    `toml:"shutdown_seconds"` // Wait for requests, then for the submission being judged (in total)
+}
+
+type Storage struct {
+	Database string `toml:"database"`
+}
+
+type Limits struct {
+	MaxSubmissions int `toml:"max_submissions"` // Per user, for the whole contest
+	MaxUploadKB    int `toml:"max_upload_kb"`   // Size of a submitted source file
+	QueueSize      int `toml:"queue_size"`      // Submissions waiting for the judge
+}
+
+type Sandbox struct {
+	MemoryKB  int `toml:"memory_kb"` // isolate --mem
+	Processes int `toml:"processes"` // isolate --processes
+}
+
+// Scoring ranks the scoreboard; engine.ConfigureScoring checks the verdict and
+// tie breaker names
+type Scoring struct {
+	PenaltyMinutes  int      `toml:"penalty_minutes"`           // Per counted rejected attempt before AC
+	AttemptVerdicts []string `toml:"attempt_verdicts"`          // Verdicts that count as rejected attempts
+	TieBreakers     []string `toml:"tie_breakers"`              // Applied in order after "most solved"
+	FirstSolveBonus int      `toml:"first_solve_bonus_minutes"` // Subtracted from penalty per first solve
+}
+
+// Proxy says which peers are reverse proxies whose X-Forwarded-For/Proto
+// headers are believed. Connections on the Unix socket always are.
+type Proxy struct {
+	Trusted    []string          `toml:"trusted"`     // IPs or CIDRs, e.g. 127.0.0.1 for cloudflared
+	AuthHeader string            `toml:"auth_header"` // Identity set by an access proxy, e.g. Cf-Access-Authenticated-User-Email
+	Users      map[string]string `toml:"users"`       // auth_header value -> username (default: the value is the username)
+}
+
+// Default reproduces the values that were hardcoded before the config file existed
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Mode:     ModeTLS,
+			Addr:     ":8443",
+			CertFile: "certs/server.crt",
+			KeyFile:  "certs/server.key",
+
+			ShutdownSeconds: 30,
+		},
+		Storage: Storage{
+			Database: "storage/db/judge.sqlite",
+		},
+		Limits: Limits{
+			MaxSubmissions: 100,
+			MaxUploadKB:    1024,
+			QueueSize:      5000, // Max users (50) * max submissions (100): never blocks
+		},
+		Sandbox: Sandbox{
+			MemoryKB:  256000,
+			Processes: 10,
+		},
+		// Classic ICPC: 20 minutes per attempt, CE ignored, ties broken by penalty only
+		Scoring: Scoring{
+			PenaltyMinutes:  20,
+			AttemptVerdicts: []string{"WA", "TLE", "RTE", "IE"},
+			TieBreakers:     []string{"penalty"},
+		},
+	}
+}
+
+// Environment overrides, applied after the file
+var envVars = map[string]func(c *Config) interface{}{
+	"JUDGE_MODE":              func(c *Config) interface{} { return &c.Server.Mode },
+	"JUDGE_ADDR":              func(c *Config) interface{} { return &c.Server.Addr },
+	"JUDGE_CERT_FILE":         func(c *Config) interface{} { return &c.Server.CertFile },
+	"JUDGE_KEY_FILE":          func(c *Config) interface{} { return &c.Server.KeyFile },
+	"JUDGE_SHUTDOWN_SECONDS":  func(c *Config) interface{} { return &c.Server.ShutdownSeconds },
+	"JUDGE_DATABASE":          func(c *Config) interface{} { return &c.Storage.Database },
+	"JUDGE_MAX_SUBMISSIONS":   func(c *Config) interface{} { return &c.Limits.MaxSubmissions },
+	"JUDGE_MAX_UPLOAD_KB":     func(c *Config) interface{} { return &c.Limits.MaxUploadKB },
+	"JUDGE_QUEUE_SIZE":        func(c *Config) interface{} { return &c.Limits.QueueSize },
+	"JUDGE_SANDBOX_MEMORY_KB": func(c *Config) interface{} { return &c.Sandbox.MemoryKB },
+	"JUDGE_SANDBOX_PROCESSES": func(c *Config) interface{} { return &c.Sandbox.Processes },
+	"JUDGE_PENALTY_MINUTES":   func(c *Config) interface{} { return &c.Scoring.PenaltyMinutes },
+	"JUDGE_ATTEMPT_VERDICTS":  func(c *Config) interface{} { return &c.Scoring.AttemptVerdicts }, // Comma-separated
+	"JUDGE_TIE_BREAKERS":      func(c *Config) interface{} { return &c.Scoring.TieBreakers },     // Comma-separated
+	"JUDGE_FIRST_SOLVE_BONUS": func(c *Config) interface{} { return &c.Scoring.FirstSolveBonus },
+	"JUDGE_TRUSTED_PROXIES":   func(c *Config) interface{} { return &c.Proxy.Trusted }, // Comma-separated
+	"JUDGE_AUTH_HEADER":       func(c *Config) interface{} { return &c.Proxy.AuthHeader },
+}
+
+// Load reads the file named by JUDGE_CONFIG (or judge.toml if present), applies
+// environment overrides and validates the result.
+func Load() (*Config, error) {
+	c := Default()
+
+	path, explicit := os.LookupEnv("JUDGE_CONFIG")
+	if !explicit {
+		path = DefaultPath
+	}
+	meta, err := toml.DecodeFile(path, c)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		// No file: defaults and environment only
+	} else if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	} else if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("%s: unknown setting %q", path, undecoded[0].String())
+	}
+
+	if err := c.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Config) applyEnv() error {
+	names := make([]string, 0, len(envVars))
+	for name := range envVars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		switch dst := envVars[name](c).(type) {
+		case *string:
+			*dst = v
+		case *int:
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				return fmt.Errorf("%s: %q is not a number", name, v)
+			}
+			*dst = n
+		case *[]string:
+			*dst = nil
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					*dst = append(*dst, item)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Validate reports every mistake at once, so a bad config fails the boot with
+// a complete list
+func (c *Config) Validate() error {
+	var errs []string
+	check := func(ok bool, format string, v ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, v...))
+		}
+	}
+
+	check(c.Server.Mode == ModeTLS || c.Server.Mode == ModeHTTP || c.Server.Mode == ModeUnix,
+		"server.mode must be %q, %q or %q", ModeTLS, ModeHTTP, ModeUnix)
+	check(c.Server.Addr != "", "server.addr must not be empty")
+	if c.Server.Mode == ModeUnix {
+		check(!strings.HasPrefix(c.Server.Addr, ":"), "server.addr must be a socket path in unix mode")
+	}
+	if c.Server.Mode == ModeTLS {
+		check(c.Server.CertFile != "", "server.cert_file must not be empty")
+		check(c.Server.KeyFile != "", "server.key_file must not be empty")
+	}
+	check(c.Server.ShutdownSeconds > 0, "server.shutdown_seconds must be positive")
+	check(c.Storage.Database != "", "storage.database must not be empty")
+	if c.Storage.Database != "" {
+		dir := filepath.Dir(c.Storage.Database)
+		_, err := os.Stat(dir)
+		check(err == nil, "storage.database: directory %s does not exist (mkdir -p %s)", dir, dir)
+	}
+	check(c.Limits.MaxSubmissions > 0, "limits.max_submissions must be positive")
+	check(c.Limits.MaxUploadKB > 0, "limits.max_upload_kb must be positive")
+	check(c.Limits.QueueSize > 0, "limits.queue_size must be positive")
+	check(c.Sandbox.MemoryKB >= 16000, "sandbox.memory_kb must be at least 16000")
+	check(c.Sandbox.Processes > 0, "sandbox.processes must be positive")
+	_, err := c.Proxy.Networks()
+	check(err == nil, "proxy.trusted: %v", err)
+	// Anyone could send the header if it were not stripped by a trusted proxy
+	check(c.Proxy.AuthHeader == "" || len(c.Proxy.Trusted) > 0 || c.Server.Mode == ModeUnix,
+		"proxy.auth_header needs proxy.trusted (or unix mode)")
+
+	if len(errs) > 0 {
+		return errors.New("invalid configuration:\n  " + strings.Join(errs, "\n  "))
+	}
+	return nil
+}
+
+// Networks parses Trusted; single addresses become /32 (or /128) networks
+func (p Proxy) Networks() ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, t := range p.Trusted {
+		if !strings.Contains(t, "/") {
+			ip := net.ParseIP(t)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an IP address or CIDR", t)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(t)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR", t)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// ShutdownTimeout bounds the whole shutdown: in-flight requests, then judging
+func (s Server) ShutdownTimeout() time.Duration {
+	return time.Duration(s.ShutdownSeconds) * time.Second
+}
+
+// MaxUploadBytes is the request body limit for uploads (file plus form overhead)
+func (l Limits) MaxUploadBytes() int64 {
+	return int64(l.MaxUploadKB)*1024 + 4096
+}
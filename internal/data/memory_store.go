@@ -0,0 +1,416 @@
+package data
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore implements every store in memory, for tests and experiments.
+// Rows are copied in and out, so callers never share state with the store.
+type MemoryStore struct {
+	mu          sync.Mutex
+	users       map[int]User
+	sessions    map[string]int
+	tokens      map[int]memoryToken // Token ID -> token
+	lastTokenID int
+	problems    map[int]Problem
+	submissions map[int]Submission
+	tests       map[int]map[int]TestResult // Submission ID -> test number -> result
+	lastSubID   int
+	delivered   map[int]bool // Submission ID -> balloon delivered
+	audit       []AuditEntry // Oldest first
+}
+
+// NewMemoryStores returns empty in-memory stores and the MemoryStore behind
+// them, whose AddUser and AddProblem fill in what the interfaces cannot create
+func NewMemoryStores() (*Stores, *MemoryStore) {
+	m := &MemoryStore{
+		users:       make(map[int]User),
+		sessions:    make(map[string]int),
+		tokens:      make(map[int]memoryToken),
+		problems:    make(map[int]Problem),
+		submissions: make(map[int]Submission),
+		tests:       make(map[int]map[int]TestResult),
+		delivered:   make(map[int]bool),
+	}
+	return &Stores{Users: m, Sessions: m, Tokens: m, Problems: m, Submissions: m, Balloons: m, Audit: m}, m
+}
+
+// AddUser stores u as is (including its ID)
+func (m *MemoryStore) AddUser(u User) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[u.ID] = u
+}
+
+// AddProblem stores p as is (including its ID)
+func (m *MemoryStore) AddProblem(p Problem) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.problems[p.ID] = p
+}
+
+// --- Users ---
+
+func (m *MemoryStore) User(id int) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &u, nil
+}
+
+func (m *MemoryStore) UserByUsername(username string) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if u.Username == username {
+			return &u, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *MemoryStore) Contestants() ([]User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var users []User
+	for _, u := range m.users {
+		if !u.IsAdmin {
+			users = append(users, u)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+// --- Sessions ---
+
+func (m *MemoryStore) CreateSession(token string, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[token] = userID
+	return nil
+}
+
+func (m *MemoryStore) SessionUser(token string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	userID, ok := m.sessions[token]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return userID, nil
+}
+
+func (m *MemoryStore) CountSessions() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sessions), nil
+}
+
+func (m *MemoryStore) FlushSessions() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions = make(map[string]int)
+	return nil
+}
+
+// --- API Tokens ---
+
+type memoryToken struct {
+	APIToken
+	userID int
+	hash   string
+}
+
+func (m *MemoryStore) CreateToken(userID int, name, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastTokenID++
+	m.tokens[m.lastTokenID] = memoryToken{
+		APIToken: APIToken{ID: m.lastTokenID, Name: name, Created: time.Now().UTC().Truncate(time.Second)},
+		userID:   userID,
+		hash:     hash,
+	}
+	return nil
+}
+
+func (m *MemoryStore) ReplaceToken(userID int, name, hash string) error {
+	m.mu.Lock()
+	for id, t := range m.tokens {
+		if t.userID == userID && t.Name == name {
+			delete(m.tokens, id)
+		}
+	}
+	m.mu.Unlock()
+	return m.CreateToken(userID, name, hash)
+}
+
+func (m *MemoryStore) TokenUser(hash string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, t := range m.tokens {
+		if t.hash == hash {
+			now := time.Now().UTC().Truncate(time.Second)
+			t.LastUsed = &now
+			m.tokens[id] = t
+			return t.userID, nil
+		}
+	}
+	return 0, ErrNotFound
+}
+
+func (m *MemoryStore) Tokens(userID int) ([]APIToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var tokens []APIToken
+	for _, t := range m.tokens {
+		if t.userID == userID {
+			tokens = append(tokens, t.APIToken)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID > tokens[j].ID })
+	return tokens, nil
+}
+
+func (m *MemoryStore) RevokeToken(userID, tokenID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if t, ok := m.tokens[tokenID]; ok && t.userID == userID {
+		delete(m.tokens, tokenID)
+	}
+	return nil
+}
+
+// --- Problems ---
+
+func (m *MemoryStore) Problem(id int) (*Problem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.problems[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &p, nil
+}
+
+func (m *MemoryStore) Problems() ([]Problem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var problems []Problem
+	for _, p := range m.problems {
+		problems = append(problems, p)
+	}
+	sort.Slice(problems, func(i, j int) bool { return problems[i].Letter < problems[j].Letter })
+	return problems, nil
+}
+
+// --- Submissions ---
+
+func (m *MemoryStore) CreateSubmission(userID, problemID int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.problems[problemID]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	m.lastSubID++
+	m.submissions[m.lastSubID] = Submission{
+		ID:        m.lastSubID,
+		UserID:    userID,
+		ProblemID: problemID,
+		Problem:   p.Letter,
+		Status:    "PENDING",
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	return m.lastSubID, nil
+}
+
+// update applies fn to submission id, if it exists
+func (m *MemoryStore) update(id int, fn func(s *Submission)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.submissions[id]
+	if !ok {
+		return ErrNotFound
+	}
+	fn(&s)
+	m.submissions[id] = s
+	return nil
+}
+
+func (m *MemoryStore) SetSubmissionFile(id int, filePath string) error {
+	return m.update(id, func(s *Submission) { s.FilePath = filePath })
+}
+
+func (m *MemoryStore) DeleteSubmission(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.submissions, id)
+	delete(m.tests, id)
+	return nil
+}
+
+func (m *MemoryStore) Submission(id int) (*Submission, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.submissions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &s, nil
+}
+
+// matching lists the submissions selected by keep, newest first
+func (m *MemoryStore) matching(keep func(s Submission) bool) []Submission {
+	var subs []Submission
+	for _, s := range m.submissions {
+		if keep(s) {
+			subs = append(subs, s)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].ID > subs[j].ID })
+	return subs
+}
+
+func (f SubmissionFilter) matches(s Submission) bool {
+	return (len(f.IDs) == 0 || slices.Contains(f.IDs, s.ID)) && (f.UserID == 0 || s.UserID == f.UserID) && (f.ProblemID == 0 || s.ProblemID == f.ProblemID) &&
+		(f.Verdict == "" || HasVerdict(s.Status, f.Verdict)) &&
+		(f.From.IsZero() || !s.CreatedAt.Before(f.From)) && (f.To.IsZero() || s.CreatedAt.Before(f.To))
+}
+
+func (m *MemoryStore) CountSubmissions(f SubmissionFilter) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.matching(f.matches)), nil
+}
+
+func (m *MemoryStore) ListSubmissions(f SubmissionFilter) ([]Submission, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subs := m.matching(f.matches)
+	if f.Limit > 0 {
+		subs = subs[min(f.Offset, len(subs)):min(f.Offset+f.Limit, len(subs))]
+	}
+	return subs, nil
+}
+
+func (m *MemoryStore) PendingSubmissions() ([]Submission, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subs := m.matching(func(s Submission) bool { return s.Status == "PENDING" && s.FilePath != "" })
+	sort.Slice(subs, func(i, j int) bool { return subs[i].ID < subs[j].ID })
+	return subs, nil
+}
+
+func (m *MemoryStore) ResetResults(id int) error {
+	m.mu.Lock()
+	delete(m.tests, id)
+	m.mu.Unlock()
+	return m.update(id, func(s *Submission) { s.CompileOutput = "" })
+}
+
+func (m *MemoryStore) RecordTest(submissionID int, t TestResult) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.submissions[submissionID]; !ok {
+		return ErrNotFound
+	}
+	if m.tests[submissionID] == nil {
+		m.tests[submissionID] = make(map[int]TestResult)
+	}
+	m.tests[submissionID][t.Number] = t
+	return nil
+}
+
+func (m *MemoryStore) Tests(submissionID int) ([]TestResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var tests []TestResult
+	for _, t := range m.tests[submissionID] {
+		tests = append(tests, t)
+	}
+	sort.Slice(tests, func(i, j int) bool { return tests[i].Number < tests[j].Number })
+	return tests, nil
+}
+
+func (m *MemoryStore) SetVerdict(id int, status, compileOutput string) error {
+	return m.update(id, func(s *Submission) {
+		s.Status = status
+		s.CompileOutput = compileOutput
+		s.JudgedAt = time.Now().UTC().Truncate(time.Second)
+	})
+}
+
+// --- Balloons ---
+
+func (m *MemoryStore) Balloons() ([]Balloon, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Oldest first, so the first AC seen per team and problem is the balloon
+	accepted := m.matching(func(s Submission) bool { return s.Status == "AC" && !m.users[s.UserID].IsAdmin })
+	slices.Reverse(accepted)
+
+	type solve struct{ user, problem int }
+	seen := make(map[solve]bool)
+	solved := make(map[int]bool) // Problem ID -> first solve handed out
+	var balloons []Balloon
+	for _, s := range accepted {
+		if seen[solve{s.UserID, s.ProblemID}] {
+			continue
+		}
+		seen[solve{s.UserID, s.ProblemID}] = true
+		balloons = append(balloons, Balloon{
+			SubmissionID: s.ID,
+			Team:         m.users[s.UserID].DisplayName,
+			Problem:      s.Problem,
+			Color:        m.problems[s.ProblemID].Color,
+			SolvedAt:     s.CreatedAt,
+			FirstSolve:   !solved[s.ProblemID],
+			Delivered:    m.delivered[s.ID],
+		})
+		solved[s.ProblemID] = true
+	}
+	sort.SliceStable(balloons, func(i, j int) bool { return !balloons[i].Delivered && balloons[j].Delivered })
+	return balloons, nil
+}
+
+func (m *MemoryStore) SetDelivered(submissionID int, delivered bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.delivered[submissionID] = delivered
+	return nil
+}
+
+// --- Audit Log ---
+
+func (m *MemoryStore) AddAuditEntry(e AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e.ID = len(m.audit) + 1
+	e.Time = time.Now().UTC().Truncate(time.Second)
+	m.audit = append(m.audit, e)
+	return nil
+}
+
+func (m *MemoryStore) AuditEntries(f AuditFilter) ([]AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+	var entries []AuditEntry
+	for i := len(m.audit) - 1; i >= 0 && len(entries) < f.Limit; i-- {
+		e := m.audit[i]
+		if (f.Actor == "" || e.Actor == f.Actor) && (f.Action == "" || e.Action == f.Action) &&
+			(f.Since.IsZero() || !e.Time.Before(f.Since)) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,228 @@
+package data
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- Schema Migrations ---
+// migrations/NNNN_name.sql are applied in version order, each in its own
+// transaction together with its row in schema_migrations. Applied files must
+// never be edited: schema changes go into a new file with the next number.
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// MigrationState is a known migration and whether the database has it
+type MigrationState struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrations lists the embedded migrations in version order
+func Migrations() ([]Migration, error) {
+	names, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Migration
+	seen := make(map[int]string)
+	for _, path := range names {
+		base := strings.TrimSuffix(strings.TrimPrefix(path, "migrations/"), ".sql")
+		num, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(num)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("%s: migration files must be named NNNN_name.sql", path)
+		}
+		if other, dup := seen[version]; dup {
+			return nil, fmt.Errorf("%s and %s have the same version", other, path)
+		}
+		seen[version] = path
+
+		body, err := migrationFiles.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, Migration{Version: version, Name: name, SQL: string(body)})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+func tableExists(name string) (bool, error) {
+	var n int
+	err := DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&n)
+	return n > 0, err
+}
+
+// appliedVersions reads schema_migrations (empty if the table does not exist yet)
+func appliedVersions() (map[int]time.Time, error) {
+	applied := make(map[int]time.Time)
+	if ok, err := tableExists("schema_migrations"); err != nil || !ok {
+		return applied, err
+	}
+
+	rows, err := DB.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var v int
+		var at time.Time
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, err
+		}
+		applied[v] = at
+	}
+	return applied, rows.Err()
+}
+
+// MigrationStatus reports every known migration, applied or pending
+func MigrationStatus() ([]MigrationState, error) {
+	list, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	var states []MigrationState
+	for _, m := range list {
+		at, ok := applied[m.Version]
+		states = append(states, MigrationState{Migration: m, Applied: ok, AppliedAt: at})
+	}
+	return states, nil
+}
+
+// Migrate applies pending migrations and returns them. It refuses to run on a
+// database migrated by a newer build.
+func Migrate() ([]Migration, error) {
+	list, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := adoptLegacy(); err != nil {
+		return nil, fmt.Errorf("upgrading pre-migration database: %v", err)
+	}
+
+	_, err = DB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedVersions()
+	if err != nil {
+		return nil, err
+	}
+	latest := list[len(list)-1].Version
+	for v := range applied {
+		if v > latest {
+			return nil, fmt.Errorf("database is at schema version %d, this build only knows up to %d", v, latest)
+		}
+	}
+
+	var done []Migration
+	for _, m := range list {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := apply(m); err != nil {
+			return done, fmt.Errorf("%s: %v", m, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// apply runs one migration and records it atomically
+func apply(m Migration) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(m.SQL); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.Version, m.Name); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Columns that the first release added with ALTER TABLE; a database created
+// before them lacks them
+var legacyColumns = []struct{ table, column, definition string }{
+	{"users", "display_name", "TEXT DEFAULT ''"},
+}
+
+// adoptLegacy brings a database created before schema_migrations existed up to
+// the baseline (0001) by adding the legacy columns it lacks. Fresh and already
+// versioned databases are left alone.
+func adoptLegacy() error {
+	versioned, err := tableExists("schema_migrations")
+	if err != nil || versioned {
+		return err
+	}
+	legacy, err := tableExists("users")
+	if err != nil || !legacy {
+		return err
+	}
+
+	for _, c := range legacyColumns {
+		has, err := hasColumn(c.table, c.column)
+		if err != nil {
+			return err
+		}
+		if !has {
+			if _, err := DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.definition)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func hasColumn(table, column string) (bool, error) {
+	rows, err := DB.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
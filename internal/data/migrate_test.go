@@ -0,0 +1,123 @@
+package data
+
+import (
+	"strings"
+	"testing"
+)
+
+// legacySchema is createSchema from the first release (7b0a026), from before
+// its ALTER TABLE added display_name
+const legacySchema = `
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT NOT NULL UNIQUE,
+	password_hash TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+	token TEXT PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS problems (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	letter_code TEXT NOT NULL,
+	time_limit INTEGER NOT NULL,
+	pdf_path TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS submissions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	problem_id INTEGER NOT NULL,
+	status TEXT NOT NULL,
+	file_path TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY(problem_id) REFERENCES problems(id) ON DELETE CASCADE
+);
+`
+
+func TestMigrateAdoptsLegacyDatabase(t *testing.T) {
+	openTestDB(t)
+	if _, err := DB.Exec(legacySchema); err != nil {
+		t.Fatal(err)
+	}
+	_, err := DB.Exec(`INSERT INTO users (username, password_hash) VALUES ('alice', 'x');
+		INSERT INTO problems (letter_code, time_limit, pdf_path) VALUES ('A', 1000, 'a.pdf');
+		INSERT INTO submissions (user_id, problem_id, status, file_path) VALUES (1, 1, 'AC', 'a.cpp')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, err := Migrate()
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if len(done) != len(list) {
+		t.Errorf("applied %v, want all of %v", done, list)
+	}
+
+	for _, c := range []struct{ table, column string }{
+		{"users", "display_name"}, {"users", "is_admin"}, {"problems", "name"}, {"problems", "color"},
+		{"submissions", "judged_at"}, {"submissions", "compile_output"},
+	} {
+		if has, err := hasColumn(c.table, c.column); err != nil || !has {
+			t.Errorf("%s.%s missing after Migrate (%v)", c.table, c.column, err)
+		}
+	}
+	for _, table := range []string{"submission_tests", "balloons", "api_tokens", "audit_log"} {
+		if ok, err := tableExists(table); err != nil || !ok {
+			t.Errorf("table %s missing after Migrate (%v)", table, err)
+		}
+	}
+
+	states, err := MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range states {
+		if !s.Applied || s.AppliedAt.IsZero() {
+			t.Errorf("schema_migrations has no row for %s", s.Migration)
+		}
+	}
+
+	// The old rows survive and read through the current code
+	sub, err := NewSQLiteStores(DB).Submissions.Submission(1)
+	if err != nil || sub.Status != "AC" || sub.Problem != "A" {
+		t.Errorf("legacy submission = %+v, %v", sub, err)
+	}
+
+	// Running again changes nothing
+	done, err = Migrate()
+	if err != nil || len(done) != 0 {
+		t.Errorf("second Migrate = %v, %v; want no migrations", done, err)
+	}
+	var rows int
+	DB.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&rows)
+	if rows != len(list) {
+		t.Errorf("schema_migrations has %d rows, want %d", rows, len(list))
+	}
+}
+
+func TestMigrateRefusesNewerDatabase(t *testing.T) {
+	openTestDB(t)
+	if _, err := Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	list, _ := Migrations()
+	future := list[len(list)-1].Version + 1
+	if _, err := DB.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, 'future')", future); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Migrate()
+	if err == nil || !strings.Contains(err.Error(), "schema version") {
+		t.Fatalf("Migrate on a newer database = %v, want a refusal", err)
+	}
+}
@@ -0,0 +1,34 @@
+-- Schema of the first release, before versioned migrations. A database created
+-- by it is adopted at this version: adoptLegacy adds display_name if the
+-- database predates that column, and the statements below are no-ops.
+
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT NOT NULL UNIQUE,
+	display_name TEXT DEFAULT '',
+	password_hash TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+	token TEXT PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS problems (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	letter_code TEXT NOT NULL,
+	time_limit INTEGER NOT NULL,
+	pdf_path TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS submissions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	problem_id INTEGER NOT NULL,
+	status TEXT NOT NULL,
+	file_path TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY(problem_id) REFERENCES problems(id) ON DELETE CASCADE
+);
@@ -0,0 +1,39 @@
+-- Admins, judging details (judge time, compiler output, per-test results),
+-- problem names and balloon colors, the balloon queue and API tokens
+
+ALTER TABLE users ADD COLUMN is_admin INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE problems ADD COLUMN color TEXT NOT NULL DEFAULT '';
+ALTER TABLE problems ADD COLUMN name TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE submissions ADD COLUMN judged_at DATETIME;
+ALTER TABLE submissions ADD COLUMN compile_output TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE submission_tests (
+	submission_id INTEGER NOT NULL,
+	test_number INTEGER NOT NULL,
+	verdict TEXT NOT NULL,
+	time_ms INTEGER NOT NULL DEFAULT 0,
+	memory_kb INTEGER NOT NULL DEFAULT 0,
+	sample INTEGER NOT NULL DEFAULT 0,
+	output TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY(submission_id, test_number),
+	FOREIGN KEY(submission_id) REFERENCES submissions(id) ON DELETE CASCADE
+);
+
+CREATE TABLE balloons (
+	submission_id INTEGER PRIMARY KEY,
+	delivered INTEGER NOT NULL DEFAULT 0,
+	delivered_at DATETIME,
+	FOREIGN KEY(submission_id) REFERENCES submissions(id) ON DELETE CASCADE
+);
+
+CREATE TABLE api_tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	name TEXT NOT NULL DEFAULT '',
+	token_hash TEXT NOT NULL UNIQUE,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	last_used_at DATETIME,
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"log"
 	"os"
+	"path/filepath"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -11,15 +12,36 @@ import (
 
 var DB *sql.DB
 
-func InitDB() {
+// Path is the database file opened by OpenDB (its WAL is Path + "-wal")
+var Path string
+
+// InitDB opens (creating if needed) the database at path (config storage.database)
+// and applies pending migrations
+func InitDB(path string) {
+	OpenDB(path)
+
+	applied, err := Migrate()
+	if err != nil {
+		log.Fatalf("Failed to migrate schema: %v", err)
+	}
+	for _, m := range applied {
+		log.Printf("MIGRATION: Applied %s", m)
+	}
+
+	log.Println("SUCCESS: Database connection initialized & Schema migrated.")
+}
+
+// OpenDB opens the database without touching the schema (see "migrate status")
+func OpenDB(path string) {
 	var err error
 
-	if _, err := os.Stat("./storage/db"); os.IsNotExist(err) {
-		log.Fatal("ERROR: Directory './storage/db' does not exist. Please run: mkdir -p storage/db")
+	if dir := filepath.Dir(path); !dirExists(dir) {
+		log.Fatalf("ERROR: Directory '%s' does not exist. Please run: mkdir -p %s", dir, dir)
 	}
 
 	log.Println("Initializing Database...")
-	DB, err = sql.Open("sqlite", "./storage/db/judge.sqlite")
+	Path = path
+	DB, err = sql.Open("sqlite", path)
 	if err != nil {
 		log.Fatalf("Failed to open database struct: %v", err)
 	}
@@ -36,57 +58,9 @@ func InitDB() {
 	if _, err := DB.Exec("PRAGMA busy_timeout=5000;"); err != nil {
 		log.Fatalf("Failed to set busy timeout: %v", err)
 	}
-
-	createSchema()
-    runMigrations() //
-
-	log.Println("SUCCESS: Database connection initialized & Schema migrated.")
-}
-
-func createSchema() {
-	schema := `
-	CREATE TABLE IF NOT EXISTS users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		username TEXT NOT NULL UNIQUE,
-        display_name TEXT DEFAULT '', -- Added for Phase 8
-		password_hash TEXT NOT NULL
-	);
-
-	CREATE TABLE IF NOT EXISTS sessions (
-		token TEXT PRIMARY KEY,
-		user_id INTEGER NOT NULL,
-		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
-	);
-
-	CREATE TABLE IF NOT EXISTS problems (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		letter_code TEXT NOT NULL,
-		time_limit INTEGER NOT NULL,
-		pdf_path TEXT NOT NULL
-	);
-
-	CREATE TABLE IF NOT EXISTS submissions (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		user_id INTEGER NOT NULL,
-		problem_id INTEGER NOT NULL,
-		status TEXT NOT NULL,
-		file_path TEXT NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
-		FOREIGN KEY(problem_id) REFERENCES problems(id) ON DELETE CASCADE
-	);
-	`
-
-	_, err := DB.Exec(schema)
-	if err != nil {
-		log.Fatalf("Failed to migrate schema: %v", err)
-	}
 }
 
-// runMigrations handles schema updates for existing installations
-func runMigrations() {
-    // Phase 8: Add display_name if it doesn't exist
-    // We attempt to add it; if it fails (likely because it exists), we ignore the error.
-    // In a production env, query PRAGMA table_info to be precise.
-    _, _ = DB.Exec("ALTER TABLE users ADD COLUMN display_name TEXT DEFAULT ''")
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
 }
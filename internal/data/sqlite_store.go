@@ -0,0 +1,452 @@
+package data
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// SQLiteStore implements every store on top of the database opened by OpenDB
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStores returns the stores backed by db (normally DB, after InitDB)
+func NewSQLiteStores(db *sql.DB) *Stores {
+	s := &SQLiteStore{db: db}
+	return &Stores{Users: s, Sessions: s, Tokens: s, Problems: s, Submissions: s, Balloons: s, Audit: s}
+}
+
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+// --- Users ---
+
+const userColumns = "id, username, COALESCE(display_name, ''), password_hash, is_admin"
+
+func scanUser(row *sql.Row) (*User, error) {
+	var u User
+	if err := row.Scan(&u.ID, &u.Username, &u.DisplayName, &u.PasswordHash, &u.IsAdmin); err != nil {
+		return nil, notFound(err)
+	}
+	return &u, nil
+}
+
+func (s *SQLiteStore) User(id int) (*User, error) {
+	return scanUser(s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE id = ?", id))
+}
+
+func (s *SQLiteStore) UserByUsername(username string) (*User, error) {
+	return scanUser(s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE username = ?", username))
+}
+
+func (s *SQLiteStore) Contestants() ([]User, error) {
+	rows, err := s.db.Query("SELECT " + userColumns + " FROM users WHERE is_admin = 0 ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Username, &u.DisplayName, &u.PasswordHash, &u.IsAdmin); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// --- Sessions ---
+
+func (s *SQLiteStore) CreateSession(token string, userID int) error {
+	_, err := s.db.Exec("INSERT INTO sessions (token, user_id) VALUES (?, ?)", token, userID)
+	return err
+}
+
+func (s *SQLiteStore) SessionUser(token string) (int, error) {
+	var userID int
+	err := s.db.QueryRow("SELECT user_id FROM sessions WHERE token = ?", token).Scan(&userID)
+	return userID, notFound(err)
+}
+
+func (s *SQLiteStore) CountSessions() (int, error) {
+	var n int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&n)
+	return n, err
+}
+
+func (s *SQLiteStore) FlushSessions() error {
+	_, err := s.db.Exec("DELETE FROM sessions")
+	return err
+}
+
+// --- API Tokens ---
+
+func (s *SQLiteStore) CreateToken(userID int, name, hash string) error {
+	_, err := s.db.Exec("INSERT INTO api_tokens (user_id, name, token_hash) VALUES (?, ?, ?)", userID, name, hash)
+	return err
+}
+
+func (s *SQLiteStore) ReplaceToken(userID int, name, hash string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM api_tokens WHERE user_id = ? AND name = ?", userID, name); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO api_tokens (user_id, name, token_hash) VALUES (?, ?, ?)", userID, name, hash); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) TokenUser(hash string) (int, error) {
+	var userID int
+	if err := s.db.QueryRow("SELECT user_id FROM api_tokens WHERE token_hash = ?", hash).Scan(&userID); err != nil {
+		return 0, notFound(err)
+	}
+	// Best effort: a failed timestamp update must not reject a valid token
+	s.db.Exec("UPDATE api_tokens SET last_used_at = CURRENT_TIMESTAMP WHERE token_hash = ?", hash)
+	return userID, nil
+}
+
+func (s *SQLiteStore) Tokens(userID int) ([]APIToken, error) {
+	rows, err := s.db.Query(`
+		SELECT id, name, created_at, last_used_at
+		FROM api_tokens
+		WHERE user_id = ?
+		ORDER BY id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []APIToken
+	for rows.Next() {
+		var t APIToken
+		if err := rows.Scan(&t.ID, &t.Name, &t.Created, &t.LastUsed); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+func (s *SQLiteStore) RevokeToken(userID, tokenID int) error {
+	_, err := s.db.Exec("DELETE FROM api_tokens WHERE id = ? AND user_id = ?", tokenID, userID)
+	return err
+}
+
+// --- Problems ---
+
+const problemColumns = "id, letter_code, name, time_limit, pdf_path, color"
+
+func (s *SQLiteStore) Problem(id int) (*Problem, error) {
+	var p Problem
+	err := s.db.QueryRow("SELECT "+problemColumns+" FROM problems WHERE id = ?", id).
+		Scan(&p.ID, &p.Letter, &p.Name, &p.TimeLimit, &p.PDFPath, &p.Color)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &p, nil
+}
+
+func (s *SQLiteStore) Problems() ([]Problem, error) {
+	rows, err := s.db.Query("SELECT " + problemColumns + " FROM problems ORDER BY letter_code")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var problems []Problem
+	for rows.Next() {
+		var p Problem
+		if err := rows.Scan(&p.ID, &p.Letter, &p.Name, &p.TimeLimit, &p.PDFPath, &p.Color); err != nil {
+			return nil, err
+		}
+		problems = append(problems, p)
+	}
+	return problems, rows.Err()
+}
+
+// --- Submissions ---
+
+const submissionColumns = `s.id, s.user_id, s.problem_id, p.letter_code, s.status, s.file_path,
+	s.created_at, s.judged_at, s.compile_output`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSubmission(row scanner) (Submission, error) {
+	var sub Submission
+	var judged sql.NullTime
+	err := row.Scan(&sub.ID, &sub.UserID, &sub.ProblemID, &sub.Problem, &sub.Status, &sub.FilePath,
+		&sub.CreatedAt, &judged, &sub.CompileOutput)
+	sub.JudgedAt = judged.Time
+	return sub, err
+}
+
+func (s *SQLiteStore) CreateSubmission(userID, problemID int) (int, error) {
+	res, err := s.db.Exec(`INSERT INTO submissions (user_id, problem_id, status, file_path) VALUES (?, ?, 'PENDING', '')`, userID, problemID)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+func (s *SQLiteStore) SetSubmissionFile(id int, filePath string) error {
+	_, err := s.db.Exec("UPDATE submissions SET file_path = ? WHERE id = ?", filePath, id)
+	return err
+}
+
+func (s *SQLiteStore) DeleteSubmission(id int) error {
+	_, err := s.db.Exec("DELETE FROM submissions WHERE id = ?", id)
+	return err
+}
+
+func (s *SQLiteStore) Submission(id int) (*Submission, error) {
+	sub, err := scanSubmission(s.db.QueryRow(`
+		SELECT `+submissionColumns+`
+		FROM submissions s
+		JOIN problems p ON s.problem_id = p.id
+		WHERE s.id = ?`, id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &sub, nil
+}
+
+const sqliteTime = "2006-01-02 15:04:05"
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// where turns the filter into a WHERE clause on submissions s
+func (f SubmissionFilter) where() (string, []interface{}) {
+	cond := []string{"1 = 1"}
+	var args []interface{}
+	if len(f.IDs) > 0 {
+		cond = append(cond, "s.id IN ("+strings.TrimSuffix(strings.Repeat("?, ", len(f.IDs)), ", ")+")")
+		for _, id := range f.IDs {
+			args = append(args, id)
+		}
+	}
+	if f.UserID != 0 {
+		cond = append(cond, "s.user_id = ?")
+		args = append(args, f.UserID)
+	}
+	if f.ProblemID != 0 {
+		cond = append(cond, "s.problem_id = ?")
+		args = append(args, f.ProblemID)
+	}
+	if f.Verdict != "" {
+		cond = append(cond, "(s.status = ? OR s.status LIKE ? ESCAPE '\\')")
+		args = append(args, f.Verdict, likeEscaper.Replace(f.Verdict)+" %")
+	}
+	// created_at is CURRENT_TIMESTAMP text in UTC, which sorts like the time
+	if !f.From.IsZero() {
+		cond = append(cond, "s.created_at >= ?")
+		args = append(args, f.From.UTC().Format(sqliteTime))
+	}
+	if !f.To.IsZero() {
+		cond = append(cond, "s.created_at < ?")
+		args = append(args, f.To.UTC().Format(sqliteTime))
+	}
+	return " WHERE " + strings.Join(cond, " AND "), args
+}
+
+func (s *SQLiteStore) CountSubmissions(f SubmissionFilter) (int, error) {
+	where, args := f.where()
+	var n int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM submissions s"+where, args...).Scan(&n)
+	return n, err
+}
+
+func (s *SQLiteStore) ListSubmissions(f SubmissionFilter) ([]Submission, error) {
+	where, args := f.where()
+	query := `
+		SELECT ` + submissionColumns + `
+		FROM submissions s
+		JOIN problems p ON s.problem_id = p.id` + where + `
+		ORDER BY s.id DESC`
+	if f.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, f.Limit, f.Offset)
+	}
+	return s.querySubmissions(query, args...)
+}
+
+func (s *SQLiteStore) PendingSubmissions() ([]Submission, error) {
+	return s.querySubmissions(`
+		SELECT ` + submissionColumns + `
+		FROM submissions s
+		JOIN problems p ON s.problem_id = p.id
+		WHERE s.status = 'PENDING' AND s.file_path != ''
+		ORDER BY s.id`)
+}
+
+func (s *SQLiteStore) querySubmissions(query string, args ...interface{}) ([]Submission, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []Submission
+	for rows.Next() {
+		sub, err := scanSubmission(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+func (s *SQLiteStore) ResetResults(id int) error {
+	if _, err := s.db.Exec("DELETE FROM submission_tests WHERE submission_id = ?", id); err != nil {
+		return err
+	}
+	_, err := s.db.Exec("UPDATE submissions SET compile_output = '' WHERE id = ?", id)
+	return err
+}
+
+func (s *SQLiteStore) RecordTest(submissionID int, t TestResult) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO submission_tests (submission_id, test_number, verdict, time_ms, memory_kb, sample, output) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		submissionID, t.Number, t.Verdict, t.TimeMs, t.MemoryKB, t.Sample, t.Output)
+	return err
+}
+
+func (s *SQLiteStore) Tests(submissionID int) ([]TestResult, error) {
+	rows, err := s.db.Query(`
+		SELECT test_number, verdict, time_ms, memory_kb, sample, output
+		FROM submission_tests
+		WHERE submission_id = ?
+		ORDER BY test_number`, submissionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tests []TestResult
+	for rows.Next() {
+		var t TestResult
+		if err := rows.Scan(&t.Number, &t.Verdict, &t.TimeMs, &t.MemoryKB, &t.Sample, &t.Output); err != nil {
+			return nil, err
+		}
+		tests = append(tests, t)
+	}
+	return tests, rows.Err()
+}
+
+func (s *SQLiteStore) SetVerdict(id int, status, compileOutput string) error {
+	_, err := s.db.Exec("UPDATE submissions SET status = ?, compile_output = ?, judged_at = CURRENT_TIMESTAMP WHERE id = ?",
+		status, compileOutput, id)
+	return err
+}
+
+// --- Balloons ---
+
+func (s *SQLiteStore) Balloons() ([]Balloon, error) {
+	rows, err := s.db.Query(`
+		SELECT s.id, COALESCE(u.display_name, ''), p.letter_code, p.color, s.created_at,
+			s.id = (SELECT MIN(f.id) FROM submissions f JOIN users fu ON f.user_id = fu.id
+			        WHERE f.problem_id = s.problem_id AND f.status = 'AC' AND fu.is_admin = 0) AS first_solve,
+			COALESCE(b.delivered, 0)
+		FROM submissions s
+		JOIN users u ON s.user_id = u.id
+		JOIN problems p ON s.problem_id = p.id
+		LEFT JOIN balloons b ON b.submission_id = s.id
+		WHERE s.status = 'AC' AND u.is_admin = 0
+		  AND s.id = (SELECT MIN(t.id) FROM submissions t
+		              WHERE t.user_id = s.user_id AND t.problem_id = s.problem_id AND t.status = 'AC')
+		ORDER BY COALESCE(b.delivered, 0) ASC, s.id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balloons []Balloon
+	for rows.Next() {
+		var b Balloon
+		if err := rows.Scan(&b.SubmissionID, &b.Team, &b.Problem, &b.Color, &b.SolvedAt, &b.FirstSolve, &b.Delivered); err != nil {
+			return nil, err
+		}
+		balloons = append(balloons, b)
+	}
+	return balloons, rows.Err()
+}
+
+func (s *SQLiteStore) SetDelivered(submissionID int, delivered bool) error {
+	_, err := s.db.Exec(`
+		INSERT INTO balloons (submission_id, delivered, delivered_at)
+		VALUES (?, ?, CASE WHEN ? THEN CURRENT_TIMESTAMP END)
+		ON CONFLICT(submission_id) DO UPDATE SET
+			delivered = excluded.delivered,
+			delivered_at = excluded.delivered_at`, submissionID, delivered, delivered)
+	return err
+}
+
+// --- Audit Log ---
+
+func (s *SQLiteStore) AddAuditEntry(e AuditEntry) error {
+	var actorID interface{} // NULL for the CLI and failed logins
+	if e.ActorID != 0 {
+		actorID = e.ActorID
+	}
+	_, err := s.db.Exec("INSERT INTO audit_log (actor_id, actor, action, target, details) VALUES (?, ?, ?, ?, ?)",
+		actorID, e.Actor, e.Action, e.Target, e.Details)
+	return err
+}
+
+func (s *SQLiteStore) AuditEntries(f AuditFilter) ([]AuditEntry, error) {
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+
+	var where []string
+	var args []interface{}
+	if f.Actor != "" {
+		where = append(where, "actor = ?")
+		args = append(args, f.Actor)
+	}
+	if f.Action != "" {
+		where = append(where, "action = ?")
+		args = append(args, f.Action)
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, f.Since.UTC().Format("2006-01-02 15:04:05"))
+	}
+
+	query := "SELECT id, created_at, COALESCE(actor_id, 0), actor, action, target, details FROM audit_log"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, f.Limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.ID, &e.Time, &e.ActorID, &e.Actor, &e.Action, &e.Target, &e.Details); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
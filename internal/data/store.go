@@ -0,0 +1,171 @@
+package data
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned by the stores when the requested row does not exist
+var ErrNotFound = errors.New("not found")
+
+type User struct {
+	ID           int
+	Username     string
+	DisplayName  string
+	PasswordHash string
+	IsAdmin      bool
+}
+
+type Problem struct {
+	ID        int
+	Letter    string
+	Name      string
+	TimeLimit int    // ms
+	PDFPath   string // Empty if the problem has no PDF statement
+	Color     string
+}
+
+type Submission struct {
+	ID            int
+	UserID        int
+	ProblemID     int
+	Problem       string // Letter code
+	Status        string
+	FilePath      string // Empty while the upload is being stored
+	CreatedAt     time.Time
+	JudgedAt      time.Time // Zero while pending
+	CompileOutput string
+}
+
+// APIToken is the public view of a bearer token (only its hash is stored)
+type APIToken struct {
+	ID       int
+	Name     string
+	Created  time.Time
+	LastUsed *time.Time
+}
+
+// TestResult is the outcome of one test of a submission
+type TestResult struct {
+	Number   int
+	Verdict  string
+	TimeMs   int
+	MemoryKB int
+	Sample   bool
+	Output   string // Only kept for failed samples
+}
+
+// SubmissionFilter selects a page of submissions, newest first; zero fields match everything
+type SubmissionFilter struct {
+	IDs       []int
+	UserID    int
+	ProblemID int
+	Verdict   string    // "WA" also matches "WA on test 3"
+	From, To  time.Time // Submitted in [From, To)
+	Limit     int
+	Offset    int
+}
+
+// Balloon is a contestant's first AC on a problem
+type Balloon struct {
+	SubmissionID int
+	Team         string // Display name
+	Problem      string // Letter code
+	Color        string
+	SolvedAt     time.Time
+	FirstSolve   bool // The first AC on the problem by any contestant
+	Delivered    bool
+}
+
+// AuditEntry is one row of the audit log
+type AuditEntry struct {
+	ID      int
+	Time    time.Time
+	ActorID int    // 0 for the CLI and failed logins
+	Actor   string // Copied, so it stays readable after the user is deleted
+	Action  string
+	Target  string
+	Details string
+}
+
+// AuditFilter selects audit entries, newest first; zero fields match everything
+type AuditFilter struct {
+	Actor  string
+	Action string
+	Since  time.Time
+	Limit  int // Default 100
+}
+
+// HasVerdict reports whether status ("WA on test 3") is verdict ("WA")
+func HasVerdict(status, verdict string) bool {
+	return status == verdict || strings.HasPrefix(status, verdict+" ")
+}
+
+type UserStore interface {
+	User(id int) (*User, error)
+	UserByUsername(username string) (*User, error)
+	Contestants() ([]User, error) // Non-admin users, by ID
+}
+
+type SessionStore interface {
+	CreateSession(token string, userID int) error
+	SessionUser(token string) (int, error)
+	CountSessions() (int, error)
+	FlushSessions() error
+}
+
+type TokenStore interface {
+	CreateToken(userID int, name, hash string) error
+	// ReplaceToken creates a token in place of the user's tokens with the same name
+	ReplaceToken(userID int, name, hash string) error
+	// TokenUser returns the owner of the token with this hash and records its use
+	TokenUser(hash string) (int, error)
+	Tokens(userID int) ([]APIToken, error) // Newest first
+	RevokeToken(userID, tokenID int) error
+}
+
+type ProblemStore interface {
+	Problem(id int) (*Problem, error)
+	Problems() ([]Problem, error) // By letter
+}
+
+type SubmissionStore interface {
+	// CreateSubmission inserts a PENDING submission without a file yet
+	CreateSubmission(userID, problemID int) (int, error)
+	SetSubmissionFile(id int, filePath string) error
+	DeleteSubmission(id int) error
+	Submission(id int) (*Submission, error)
+	CountSubmissions(f SubmissionFilter) (int, error)
+	ListSubmissions(f SubmissionFilter) ([]Submission, error)
+	// PendingSubmissions lists PENDING submissions whose upload is stored, oldest first
+	PendingSubmissions() ([]Submission, error)
+
+	// Judging
+	ResetResults(id int) error // Drops the tests and compile output of a previous run
+	RecordTest(submissionID int, t TestResult) error
+	Tests(submissionID int) ([]TestResult, error)
+	SetVerdict(id int, status, compileOutput string) error
+}
+
+type BalloonStore interface {
+	Balloons() ([]Balloon, error) // Undelivered first, then by submission
+	SetDelivered(submissionID int, delivered bool) error
+}
+
+type AuditStore interface {
+	AddAuditEntry(e AuditEntry) error // The store sets ID and Time
+	AuditEntries(f AuditFilter) ([]AuditEntry, error)
+}
+
+// Stores bundles the repositories handed to the handlers, the auth and audit
+// packages and the engine
+type Stores struct {
+	Users       UserStore
+	Sessions    SessionStore
+	Tokens      TokenStore
+	Problems    ProblemStore
+	Submissions SubmissionStore
+	Balloons    BalloonStore
+	Audit       AuditStore
+}
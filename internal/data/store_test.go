@@ -0,0 +1,472 @@
+package data
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// openTestDB opens a fresh database in a temp dir as DB, without migrating it
+func openTestDB(t *testing.T) {
+	t.Helper()
+	OpenDB(filepath.Join(t.TempDir(), "judge.sqlite"))
+	t.Cleanup(func() { DB.Close() })
+}
+
+// storeFixture is one implementation under test, with a way to create the
+// users and problems the interfaces cannot
+type storeFixture struct {
+	stores     *Stores
+	addUser    func(t *testing.T, u User)
+	addProblem func(t *testing.T, p Problem)
+}
+
+// forEachStore runs the same contract test against both implementations
+func forEachStore(t *testing.T, test func(t *testing.T, f storeFixture)) {
+	t.Run("sqlite", func(t *testing.T) {
+		openTestDB(t)
+		if _, err := Migrate(); err != nil {
+			t.Fatalf("Migrate: %v", err)
+		}
+		test(t, storeFixture{
+			stores: NewSQLiteStores(DB),
+			addUser: func(t *testing.T, u User) {
+				_, err := DB.Exec("INSERT INTO users (id, username, display_name, password_hash, is_admin) VALUES (?, ?, ?, ?, ?)",
+					u.ID, u.Username, u.DisplayName, u.PasswordHash, u.IsAdmin)
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			addProblem: func(t *testing.T, p Problem) {
+				_, err := DB.Exec("INSERT INTO problems (id, letter_code, name, time_limit, pdf_path, color) VALUES (?, ?, ?, ?, ?, ?)",
+					p.ID, p.Letter, p.Name, p.TimeLimit, p.PDFPath, p.Color)
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+		})
+	})
+	t.Run("memory", func(t *testing.T) {
+		stores, m := NewMemoryStores()
+		test(t, storeFixture{
+			stores:     stores,
+			addUser:    func(t *testing.T, u User) { m.AddUser(u) },
+			addProblem: func(t *testing.T, p Problem) { m.AddProblem(p) },
+		})
+	})
+}
+
+func TestUserStore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f storeFixture) {
+		f.addUser(t, User{ID: 2, Username: "bob", DisplayName: "Bob", PasswordHash: "h2"})
+		f.addUser(t, User{ID: 1, Username: "alice", DisplayName: "Alice", PasswordHash: "h1"})
+		f.addUser(t, User{ID: 3, Username: "judge", PasswordHash: "h3", IsAdmin: true})
+		users := f.stores.Users
+
+		u, err := users.User(1)
+		if err != nil || u.Username != "alice" || u.DisplayName != "Alice" || u.PasswordHash != "h1" || u.IsAdmin {
+			t.Errorf("User(1) = %+v, %v", u, err)
+		}
+		u, err = users.UserByUsername("judge")
+		if err != nil || u.ID != 3 || !u.IsAdmin {
+			t.Errorf("UserByUsername(judge) = %+v, %v", u, err)
+		}
+		if _, err := users.User(99); err != ErrNotFound {
+			t.Errorf("User(99) error = %v, want ErrNotFound", err)
+		}
+		if _, err := users.UserByUsername("nobody"); err != ErrNotFound {
+			t.Errorf("UserByUsername(nobody) error = %v, want ErrNotFound", err)
+		}
+
+		list, err := users.Contestants()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 2 || list[0].ID != 1 || list[1].ID != 2 {
+			t.Errorf("Contestants() = %+v, want alice then bob", list)
+		}
+	})
+}
+
+func TestSessionStore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f storeFixture) {
+		f.addUser(t, User{ID: 1, Username: "alice", PasswordHash: "h"})
+		sessions := f.stores.Sessions
+
+		if err := sessions.CreateSession("tok", 1); err != nil {
+			t.Fatal(err)
+		}
+		if id, err := sessions.SessionUser("tok"); err != nil || id != 1 {
+			t.Errorf("SessionUser(tok) = %d, %v", id, err)
+		}
+		if _, err := sessions.SessionUser("other"); err != ErrNotFound {
+			t.Errorf("SessionUser(other) error = %v, want ErrNotFound", err)
+		}
+		if n, err := sessions.CountSessions(); err != nil || n != 1 {
+			t.Errorf("CountSessions() = %d, %v", n, err)
+		}
+
+		if err := sessions.FlushSessions(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := sessions.SessionUser("tok"); err != ErrNotFound {
+			t.Errorf("SessionUser after flush: error = %v, want ErrNotFound", err)
+		}
+		if n, _ := sessions.CountSessions(); n != 0 {
+			t.Errorf("CountSessions() after flush = %d", n)
+		}
+	})
+}
+
+func TestTokenStore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f storeFixture) {
+		f.addUser(t, User{ID: 1, Username: "alice", PasswordHash: "h"})
+		f.addUser(t, User{ID: 2, Username: "bob", PasswordHash: "h"})
+		tokens := f.stores.Tokens
+
+		for _, tok := range []struct {
+			user       int
+			name, hash string
+		}{{1, "laptop", "hash-a"}, {1, "ci", "hash-b"}, {2, "bob", "hash-c"}} {
+			if err := tokens.CreateToken(tok.user, tok.name, tok.hash); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		list, err := tokens.Tokens(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 2 || list[0].Name != "ci" || list[1].Name != "laptop" {
+			t.Fatalf("Tokens(1) = %+v, want ci then laptop", list)
+		}
+		if list[0].LastUsed != nil {
+			t.Errorf("unused token has LastUsed %v", list[0].LastUsed)
+		}
+
+		if id, err := tokens.TokenUser("hash-b"); err != nil || id != 1 {
+			t.Errorf("TokenUser(hash-b) = %d, %v", id, err)
+		}
+		if _, err := tokens.TokenUser("nope"); err != ErrNotFound {
+			t.Errorf("TokenUser(nope) error = %v, want ErrNotFound", err)
+		}
+		list, _ = tokens.Tokens(1)
+		if list[0].LastUsed == nil {
+			t.Error("TokenUser did not record LastUsed")
+		}
+
+		// Revoking is scoped to the owner
+		if err := tokens.RevokeToken(2, list[0].ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tokens.TokenUser("hash-b"); err != nil {
+			t.Error("bob revoked alice's token")
+		}
+		if err := tokens.RevokeToken(1, list[0].ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tokens.TokenUser("hash-b"); err != ErrNotFound {
+			t.Errorf("revoked token still valid: %v", err)
+		}
+
+		// Replacing only touches the owner's tokens with that name
+		if err := tokens.CreateToken(2, "laptop", "hash-d"); err != nil {
+			t.Fatal(err)
+		}
+		if err := tokens.ReplaceToken(1, "laptop", "hash-e"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tokens.TokenUser("hash-a"); err != ErrNotFound {
+			t.Errorf("replaced token still valid: %v", err)
+		}
+		for _, hash := range []string{"hash-d", "hash-e"} {
+			if _, err := tokens.TokenUser(hash); err != nil {
+				t.Errorf("TokenUser(%s) after replace: %v", hash, err)
+			}
+		}
+		if list, _ := tokens.Tokens(1); len(list) != 1 || list[0].Name != "laptop" {
+			t.Errorf("Tokens(1) after replace = %+v", list)
+		}
+	})
+}
+
+func TestProblemStore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f storeFixture) {
+		f.addProblem(t, Problem{ID: 1, Letter: "B", Name: "Second", TimeLimit: 2000, PDFPath: "b.pdf", Color: "#ff0000"})
+		f.addProblem(t, Problem{ID: 2, Letter: "A", Name: "First", TimeLimit: 1000})
+		problems := f.stores.Problems
+
+		p, err := problems.Problem(1)
+		if err != nil || *p != (Problem{ID: 1, Letter: "B", Name: "Second", TimeLimit: 2000, PDFPath: "b.pdf", Color: "#ff0000"}) {
+			t.Errorf("Problem(1) = %+v, %v", p, err)
+		}
+		if _, err := problems.Problem(9); err != ErrNotFound {
+			t.Errorf("Problem(9) error = %v, want ErrNotFound", err)
+		}
+
+		list, err := problems.Problems()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 2 || list[0].Letter != "A" || list[1].Letter != "B" {
+			t.Errorf("Problems() = %+v, want A then B", list)
+		}
+	})
+}
+
+func TestSubmissionStore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f storeFixture) {
+		f.addUser(t, User{ID: 1, Username: "alice", PasswordHash: "h"})
+		f.addUser(t, User{ID: 2, Username: "bob", PasswordHash: "h"})
+		f.addProblem(t, Problem{ID: 1, Letter: "A", TimeLimit: 1000})
+		f.addProblem(t, Problem{ID: 2, Letter: "B", TimeLimit: 1000})
+		subs := f.stores.Submissions
+
+		create := func(user, problem int, file string) int {
+			t.Helper()
+			id, err := subs.CreateSubmission(user, problem)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if file != "" {
+				if err := subs.SetSubmissionFile(id, file); err != nil {
+					t.Fatal(err)
+				}
+			}
+			return id
+		}
+		a1 := create(1, 1, "a1.cpp")
+		a2 := create(1, 2, "a2.py")
+		b1 := create(2, 1, "b1.cpp")
+		uploading := create(2, 2, "") // No file yet
+
+		s, err := subs.Submission(a2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.UserID != 1 || s.ProblemID != 2 || s.Problem != "B" || s.Status != "PENDING" || s.FilePath != "a2.py" || s.CreatedAt.IsZero() || !s.JudgedAt.IsZero() {
+			t.Errorf("Submission(%d) = %+v", a2, s)
+		}
+		if _, err := subs.Submission(999); err != ErrNotFound {
+			t.Errorf("Submission(999) error = %v, want ErrNotFound", err)
+		}
+
+		// Pending: oldest first, only once the upload is stored
+		pending, err := subs.PendingSubmissions()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids := submissionIDs(pending); !slices.Equal(ids, []int{a1, a2, b1}) {
+			t.Errorf("PendingSubmissions() = %v, want %v", ids, []int{a1, a2, b1})
+		}
+
+		// Filters and paging, newest first
+		for _, tc := range []struct {
+			filter SubmissionFilter
+			want   []int
+			count  int
+		}{
+			{SubmissionFilter{}, []int{uploading, b1, a2, a1}, 4},
+			{SubmissionFilter{UserID: 1}, []int{a2, a1}, 2},
+			{SubmissionFilter{ProblemID: 1}, []int{b1, a1}, 2},
+			{SubmissionFilter{UserID: 2, ProblemID: 1}, []int{b1}, 1},
+			{SubmissionFilter{Limit: 2}, []int{uploading, b1}, 4},
+			{SubmissionFilter{Limit: 2, Offset: 3}, []int{a1}, 4},
+		} {
+			list, err := subs.ListSubmissions(tc.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ids := submissionIDs(list); !slices.Equal(ids, tc.want) {
+				t.Errorf("ListSubmissions(%+v) = %v, want %v", tc.filter, ids, tc.want)
+			}
+			if n, err := subs.CountSubmissions(tc.filter); err != nil || n != tc.count {
+				t.Errorf("CountSubmissions(%+v) = %d, %v, want %d", tc.filter, n, err, tc.count)
+			}
+		}
+
+		// Judging: tests in order, verdict, then a rejudge reset
+		for _, r := range []TestResult{
+			{Number: 2, Verdict: "WA", TimeMs: 30, MemoryKB: 900, Sample: true, Output: "42"},
+			{Number: 1, Verdict: "AC", TimeMs: 10, MemoryKB: 800, Sample: true},
+		} {
+			if err := subs.RecordTest(a1, r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		tests, err := subs.Tests(a1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tests) != 2 || tests[0].Number != 1 || tests[1] != (TestResult{Number: 2, Verdict: "WA", TimeMs: 30, MemoryKB: 900, Sample: true, Output: "42"}) {
+			t.Errorf("Tests(%d) = %+v", a1, tests)
+		}
+
+		if err := subs.SetVerdict(a1, "WA on test 2", "warning: unused"); err != nil {
+			t.Fatal(err)
+		}
+		s, _ = subs.Submission(a1)
+		if s.Status != "WA on test 2" || s.CompileOutput != "warning: unused" || s.JudgedAt.IsZero() {
+			t.Errorf("after SetVerdict: %+v", s)
+		}
+		pending, _ = subs.PendingSubmissions()
+		if ids := submissionIDs(pending); !slices.Equal(ids, []int{a2, b1}) {
+			t.Errorf("PendingSubmissions() after verdict = %v", ids)
+		}
+
+		// ID, verdict and time filters
+		now := time.Now()
+		for _, tc := range []struct {
+			filter SubmissionFilter
+			want   []int
+		}{
+			{SubmissionFilter{Verdict: "WA"}, []int{a1}},
+			{SubmissionFilter{Verdict: "W"}, []int{}},
+			{SubmissionFilter{Verdict: "PENDING", ProblemID: 1}, []int{b1}},
+			{SubmissionFilter{From: now.Add(-time.Hour), To: now.Add(time.Hour)}, []int{uploading, b1, a2, a1}},
+			{SubmissionFilter{From: now.Add(time.Hour)}, []int{}},
+			{SubmissionFilter{IDs: []int{a1, b1, 99}}, []int{b1, a1}},
+			{SubmissionFilter{IDs: []int{a1, b1}, Verdict: "WA"}, []int{a1}},
+			{SubmissionFilter{To: now.Add(-time.Hour)}, []int{}},
+		} {
+			list, err := subs.ListSubmissions(tc.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ids := submissionIDs(list); !slices.Equal(ids, tc.want) {
+				t.Errorf("ListSubmissions(%+v) = %v, want %v", tc.filter, ids, tc.want)
+			}
+		}
+
+		if err := subs.ResetResults(a1); err != nil {
+			t.Fatal(err)
+		}
+		if tests, _ := subs.Tests(a1); len(tests) != 0 {
+			t.Errorf("Tests after ResetResults = %+v", tests)
+		}
+		if s, _ := subs.Submission(a1); s.CompileOutput != "" {
+			t.Errorf("compile output after ResetResults = %q", s.CompileOutput)
+		}
+
+		if err := subs.DeleteSubmission(uploading); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := subs.Submission(uploading); err != ErrNotFound {
+			t.Errorf("deleted submission: error = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestBalloonStore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f storeFixture) {
+		f.addUser(t, User{ID: 1, Username: "alice", DisplayName: "Alice", PasswordHash: "h"})
+		f.addUser(t, User{ID: 2, Username: "bob", DisplayName: "Bob", PasswordHash: "h"})
+		f.addUser(t, User{ID: 3, Username: "judge", PasswordHash: "h", IsAdmin: true})
+		f.addProblem(t, Problem{ID: 1, Letter: "A", TimeLimit: 1000, Color: "red"})
+		f.addProblem(t, Problem{ID: 2, Letter: "B", TimeLimit: 1000})
+
+		judge := func(user, problem int, verdict string) int {
+			t.Helper()
+			id, err := f.stores.Submissions.CreateSubmission(user, problem)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.stores.Submissions.SetVerdict(id, verdict, "")
+			return id
+		}
+		judge(3, 1, "AC") // Judges get no balloon and do not take the first solve
+		judge(1, 1, "WA on test 1")
+		bobA := judge(2, 1, "AC")
+		aliceA := judge(1, 1, "AC")
+		judge(1, 1, "AC") // Second AC: same balloon
+		judge(2, 2, "WA on test 2")
+		aliceB := judge(1, 2, "AC")
+
+		balloons := func() []Balloon {
+			t.Helper()
+			list, err := f.stores.Balloons.Balloons()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return list
+		}
+		list := balloons()
+		if len(list) != 3 {
+			t.Fatalf("Balloons() = %+v, want 3", list)
+		}
+		got := list[0]
+		got.SolvedAt = time.Time{}
+		if got != (Balloon{SubmissionID: bobA, Team: "Bob", Problem: "A", Color: "red", FirstSolve: true}) || list[0].SolvedAt.IsZero() {
+			t.Errorf("first balloon = %+v", list[0])
+		}
+		if list[1].SubmissionID != aliceA || list[1].FirstSolve || list[2].SubmissionID != aliceB || !list[2].FirstSolve {
+			t.Errorf("Balloons() = %+v, want Alice's A then her first-solve B", list)
+		}
+
+		if err := f.stores.Balloons.SetDelivered(bobA, true); err != nil {
+			t.Fatal(err)
+		}
+		list = balloons()
+		if len(list) != 3 || list[2].SubmissionID != bobA || !list[2].Delivered || list[0].Delivered {
+			t.Errorf("after delivering #%d: %+v, want it last", bobA, list)
+		}
+		f.stores.Balloons.SetDelivered(bobA, false)
+		if list = balloons(); list[0].SubmissionID != bobA || list[0].Delivered {
+			t.Errorf("after undoing the delivery: %+v", list)
+		}
+	})
+}
+
+func TestAuditStore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f storeFixture) {
+		audit := f.stores.Audit
+		for _, e := range []AuditEntry{
+			{Actor: "cli", Action: "wipe", Details: "deleted 3 submission files"},
+			{ActorID: 1, Actor: "alice", Action: "login", Details: "from 10.0.0.1"},
+			{Actor: "mallory", Action: "login_failed", Details: "from 10.0.0.2"},
+		} {
+			if err := audit.AddAuditEntry(e); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for _, tc := range []struct {
+			filter AuditFilter
+			want   []string // Actors, newest first
+		}{
+			{AuditFilter{}, []string{"mallory", "alice", "cli"}},
+			{AuditFilter{Actor: "alice"}, []string{"alice"}},
+			{AuditFilter{Action: "login_failed"}, []string{"mallory"}},
+			{AuditFilter{Limit: 2}, []string{"mallory", "alice"}},
+			{AuditFilter{Since: time.Now().Add(-time.Hour)}, []string{"mallory", "alice", "cli"}},
+			{AuditFilter{Since: time.Now().Add(time.Hour)}, []string{}},
+		} {
+			entries, err := audit.AuditEntries(tc.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actors := []string{}
+			for _, e := range entries {
+				actors = append(actors, e.Actor)
+			}
+			if !slices.Equal(actors, tc.want) {
+				t.Errorf("AuditEntries(%+v) = %v, want %v", tc.filter, actors, tc.want)
+			}
+		}
+
+		entries, _ := audit.AuditEntries(AuditFilter{})
+		if e := entries[1]; e.ActorID != 1 || e.Action != "login" || e.Details != "from 10.0.0.1" || e.Time.IsZero() ||
+			entries[0].ActorID != 0 || entries[0].ID <= e.ID {
+			t.Errorf("AuditEntries() = %+v", entries)
+		}
+	})
+}
+
+func submissionIDs(subs []Submission) []int {
+	ids := []int{}
+	for _, s := range subs {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
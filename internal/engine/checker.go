@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// --- Custom Checkers ---
+// storage/problems/[id]/checker/ holds the C++ sources of a checker and a
+// "protocol" file naming how it is called. Without it, CompareFiles is used.
+//
+//   testlib: checker <input> <user_output> <answer>          exit 0 = AC, 1/2 = WA
+//   kattis:  checker <input> <answer> <feedback_dir> < user   exit 42 = AC, 43 = WA
+//
+// The binary is compiled on first use and again whenever a source changes.
+// Checkers are trusted problem-setter code and run outside the sandbox.
+
+const (
+	ProtocolTestlib = "testlib"
+	ProtocolKattis  = "kattis"
+)
+
+const checkerTimeout = 30 * time.Second
+
+type Checker struct {
+	Binary   string
+	Protocol string
+}
+
+func checkerDir(problemID int) string {
+	return filepath.Join(problemDir(problemID), "checker")
+}
+
+// LoadChecker returns the problem's checker, compiling it if needed.
+// A nil Checker (and nil error) means the default token comparison applies.
+func LoadChecker(problemID int) (*Checker, error) {
+	dir := checkerDir(problemID)
+	sources, _ := filepath.Glob(filepath.Join(dir, "*.cpp"))
+	if len(sources) == 0 {
+		return nil, nil
+	}
+
+	protocol := ProtocolTestlib
+	if raw, err := os.ReadFile(filepath.Join(dir, "protocol")); err == nil {
+		protocol = strings.TrimSpace(string(raw))
+	}
+	if protocol != ProtocolTestlib && protocol != ProtocolKattis {
+		return nil, fmt.Errorf("unknown checker protocol %q in %s", protocol, dir)
+	}
+
+	c := &Checker{Binary: filepath.Join(dir, "checker"), Protocol: protocol}
+	if checkerStale(c.Binary, dir) {
+		args := append([]string{"-O2", "-std=c++17", "-I", dir}, sources...)
+		args = append(args, "-o", c.Binary)
+		if out, err := exec.Command("g++", args...).CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("checker compilation failed: %v\n%s", err, out)
+		}
+	}
+	return c, nil
+}
+
+// checkerStale reports whether the binary is missing or older than any source/header
+func checkerStale(binary, dir string) bool {
+	bin, err := os.Stat(binary)
+	if err != nil {
+		return true
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if ext != ".cpp" && ext != ".h" && ext != ".hpp" {
+			continue
+		}
+		if info, err := e.Info(); err == nil && info.ModTime().After(bin.ModTime()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check judges one output. A non-nil error means the checker itself failed (IE).
+func (c *Checker) Check(inputPath, userOutPath, answerPath string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), checkerTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	switch c.Protocol {
+	case ProtocolKattis:
+		feedbackDir, err := os.MkdirTemp("", "feedback_")
+		if err != nil {
+			return false, err
+		}
+		defer os.RemoveAll(feedbackDir)
+
+		userOut, err := os.Open(userOutPath)
+		if err != nil {
+			return false, err
+		}
+		defer userOut.Close()
+
+		cmd = exec.CommandContext(ctx, c.Binary, inputPath, answerPath, feedbackDir)
+		cmd.Stdin = userOut
+	default:
+		cmd = exec.CommandContext(ctx, c.Binary, inputPath, userOutPath, answerPath)
+	}
+
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	code := 0
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		return false, err
+	}
+
+	switch {
+	case c.Protocol == ProtocolKattis && code == 42:
+		return true, nil
+	case c.Protocol == ProtocolKattis && code == 43:
+		return false, nil
+	case c.Protocol == ProtocolTestlib && code == 0:
+		return true, nil
+	case c.Protocol == ProtocolTestlib && (code == 1 || code == 2): // WA, Presentation Error
+		return false, nil
+	}
+	return false, fmt.Errorf("checker exited with code %d: %s", code, strings.TrimSpace(string(out)))
+}
@@ -0,0 +1,61 @@
+package engine
+
+import "sync"
+
+// Event types pushed to browsers via Server-Sent Events
+const (
+	EventVerdict    = "verdict"    // A submission changed status (scoped to its owner)
+	EventScoreboard = "scoreboard" // Standings may have changed (broadcast)
+)
+
+type Event struct {
+	Type         string `json:"type"`
+	UserID       int    `json:"-"`
+	SubmissionID int    `json:"submission_id,omitempty"`
+	Status       string `json:"status,omitempty"`
+}
+
+// --- Broadcaster ---
+// Subscribers get a small buffered channel. Publishing never blocks the worker:
+// if a subscriber is too slow its event is dropped (the page can resync on reload).
+
+var (
+	subscribers = make(map[chan Event]struct{})
+	subMutex    sync.Mutex
+)
+
+// Subscribe registers a listener. The returned cancel func must be called on disconnect.
+func Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, 16)
+
+	subMutex.Lock()
+	subscribers[ch] = struct{}{}
+	subMutex.Unlock()
+
+	cancel := func() {
+		subMutex.Lock()
+		delete(subscribers, ch)
+		subMutex.Unlock()
+	}
+	return ch, cancel
+}
+
+func Publish(e Event) {
+	subMutex.Lock()
+	defer subMutex.Unlock()
+
+	for ch := range subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// PublishVerdict updates the scoreboard, then announces the status change
+// and the resulting scoreboard change
+func (j *Judge) PublishVerdict(userID, submissionID int, status string) {
+	j.ApplyVerdict(submissionID, status)
+	Publish(Event{Type: EventVerdict, UserID: userID, SubmissionID: submissionID, Status: status})
+	Publish(Event{Type: EventScoreboard})
+}
@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ifuaslaerl/Judge/internal/data"
+)
+
+// --- Health (admin dashboard) ---
+
+// WorkerStatus is a snapshot of what the worker is doing
+type WorkerStatus struct {
+	Running  bool
+	Stopping bool      // Shutdown requested (StopWorker)
+	Current  int       // Submission being judged, 0 = idle
+	Since    time.Time // When judging Current started
+	Judged   int       // Submissions judged since startup
+}
+
+// healthState is what the dashboard shows beyond the queues
+type healthState struct {
+	mu             sync.Mutex
+	status         WorkerStatus
+	internalErrors []InternalError // Newest last
+}
+
+func (j *Judge) setWorkerRunning(running bool) {
+	j.health.mu.Lock()
+	j.health.status.Running = running
+	j.health.mu.Unlock()
+}
+
+// setCurrent records the submission being judged (0 when done)
+func (j *Judge) setCurrent(id int) {
+	j.health.mu.Lock()
+	defer j.health.mu.Unlock()
+	if id == 0 && j.health.status.Current != 0 {
+		j.health.status.Judged++
+	}
+	j.health.status.Current, j.health.status.Since = id, time.Now()
+}
+
+func (j *Judge) Worker() WorkerStatus {
+	j.health.mu.Lock()
+	defer j.health.mu.Unlock()
+	s := j.health.status
+	select {
+	case <-j.worker.stopping:
+		s.Stopping = true
+	default:
+	}
+	return s
+}
+
+// InternalError explains an IE verdict; causes are only in the server log otherwise
+type InternalError struct {
+	Time       time.Time
+	Submission int
+	Problem    string
+	Test       int // 0 = not tied to a test
+	Cause      string
+}
+
+const maxInternalErrors = 20
+
+func (j *Judge) recordInternalError(sub int, problem string, test int, err error) {
+	cause := "unknown"
+	if err != nil {
+		cause = err.Error()
+	}
+	j.health.mu.Lock()
+	defer j.health.mu.Unlock()
+	list := append(j.health.internalErrors, InternalError{time.Now(), sub, problem, test, cause})
+	if len(list) > maxInternalErrors {
+		list = list[len(list)-maxInternalErrors:]
+	}
+	j.health.internalErrors = list
+}
+
+// causeBeforeRestart stands in for causes that were only logged by a previous run
+const causeBeforeRestart = "Judged before the last restart, see the server log"
+
+// LoadInternalErrors fills the list with the latest IE verdicts in the
+// database (startup), so it does not start empty after a restart. Their
+// failing test comes from the recorded test results; the cause was only logged.
+func (j *Judge) LoadInternalErrors() error {
+	subs, err := j.stores.Submissions.ListSubmissions(data.SubmissionFilter{Verdict: "IE", Limit: maxInternalErrors})
+	if err != nil {
+		return err
+	}
+	list := make([]InternalError, 0, len(subs))
+	for i := len(subs) - 1; i >= 0; i-- { // Newest last
+		s := subs[i]
+		e := InternalError{Time: s.JudgedAt, Submission: s.ID, Problem: s.Problem, Cause: causeBeforeRestart}
+		tests, err := j.stores.Submissions.Tests(s.ID)
+		if err != nil {
+			return err
+		}
+		for _, t := range tests {
+			if t.Verdict == "IE" {
+				e.Test = t.Number
+			}
+		}
+		list = append(list, e)
+	}
+
+	j.health.mu.Lock()
+	defer j.health.mu.Unlock()
+	j.health.internalErrors = append(list, j.health.internalErrors...)
+	if len(j.health.internalErrors) > maxInternalErrors {
+		j.health.internalErrors = j.health.internalErrors[len(j.health.internalErrors)-maxInternalErrors:]
+	}
+	return nil
+}
+
+// RecentInternalErrors lists the latest IE verdicts, newest first
+func (j *Judge) RecentInternalErrors() []InternalError {
+	j.health.mu.Lock()
+	defer j.health.mu.Unlock()
+	list := make([]InternalError, len(j.health.internalErrors))
+	for i, e := range j.health.internalErrors {
+		list[len(list)-1-i] = e
+	}
+	return list
+}
+
+// IsolateVersion checks that the sandbox can be started; the error says why not
+func IsolateVersion() (string, error) {
+	path, err := exec.LookPath("isolate")
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "--version").CombinedOutput()
+	if err != nil {
+		return "", err
+	}
+	version, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return version, nil
+}
@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ifuaslaerl/Judge/internal/data"
+)
+
+func TestLoadInternalErrors(t *testing.T) {
+	stores, m := data.NewMemoryStores()
+	m.AddUser(data.User{ID: 1, Username: "alice"})
+	m.AddProblem(data.Problem{ID: 1, Letter: "A"})
+	m.AddProblem(data.Problem{ID: 2, Letter: "B"})
+
+	judge := func(problem int, verdict string, tests ...data.TestResult) int {
+		id, _ := stores.Submissions.CreateSubmission(1, problem)
+		for _, tr := range tests {
+			stores.Submissions.RecordTest(id, tr)
+		}
+		stores.Submissions.SetVerdict(id, verdict, "")
+		return id
+	}
+	checker := judge(1, "IE")
+	judge(1, "AC", data.TestResult{Number: 1, Verdict: "AC"})
+	sandbox := judge(2, "IE on test 2", data.TestResult{Number: 1, Verdict: "AC"}, data.TestResult{Number: 2, Verdict: "IE"})
+
+	j := NewJudge(stores)
+	j.recordInternalError(99, "C", 1, errors.New("isolate: box busy")) // Already seen by this run
+	if err := j.LoadInternalErrors(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := j.RecentInternalErrors()
+	want := []InternalError{
+		{Submission: 99, Problem: "C", Test: 1, Cause: "isolate: box busy"},
+		{Submission: sandbox, Problem: "B", Test: 2, Cause: causeBeforeRestart},
+		{Submission: checker, Problem: "A", Test: 0, Cause: causeBeforeRestart},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("RecentInternalErrors = %+v, want %d entries", got, len(want))
+	}
+	for i := range want {
+		got[i].Time = want[i].Time
+		if got[i] != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
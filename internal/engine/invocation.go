@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// --- Custom Invocation ---
+// Contestants can run code on their own input in the judge sandbox. Runs are
+// not submissions: nothing is written to the DB and they use a separate,
+// lower-priority queue (see StartWorker) and their own isolate boxes.
+
+// RunQueue holds pending custom invocations. Kept small on purpose: when it is
+// full the HTTP handler rejects the run instead of piling up work.
+var RunQueue chan *RunRequest
+
+// Boxes 100-109 are reserved for custom runs (submissions use 0-99)
+const runBoxBase = 100
+const runBoxCount = 10
+
+// Output captured from the sandbox is truncated to this size
+const maxRunOutput = 64 * 1024
+
+var runCounter atomic.Int64
+
+type RunRequest struct {
+	Ctx         context.Context // Skipped if the client went away while queued
+	Source      []byte
+	Ext         string // ".cpp" or ".py"
+	Stdin       []byte
+	TimeLimitMs int
+	Result      chan RunOutput // Buffered (1): the worker never blocks on it
+}
+
+type RunOutput struct {
+	Verdict       string `json:"verdict"` // OK, CE, TLE, RTE, IE
+	Stdout        string `json:"stdout"`
+	Stderr        string `json:"stderr"`
+	CompileOutput string `json:"compile_output,omitempty"`
+	TimeMs        int    `json:"time_ms"`
+	MemoryKB      int    `json:"memory_kb"`
+	Truncated     bool   `json:"truncated,omitempty"`
+}
+
+// NewRunRequest prepares a request; send it on RunQueue and wait on Result
+func NewRunRequest(ctx context.Context, source []byte, ext string, stdin []byte, timeLimitMs int) *RunRequest {
+	return &RunRequest{
+		Ctx:         ctx,
+		Source:      source,
+		Ext:         ext,
+		Stdin:       stdin,
+		TimeLimitMs: timeLimitMs,
+		Result:      make(chan RunOutput, 1),
+	}
+}
+
+func processRun(req *RunRequest) {
+	if req.Ctx.Err() != nil {
+		return // Client disconnected while waiting in the queue
+	}
+	req.Result <- executeRun(req)
+}
+
+// executeRun compiles and runs the code through the same path as judging
+func executeRun(req *RunRequest) RunOutput {
+	runID := runCounter.Add(1)
+	boxID := runBoxBase + int(runID%runBoxCount)
+
+	workDir, err := os.MkdirTemp("", fmt.Sprintf("run_%d_", runID))
+	if err != nil {
+		log.Printf("RUN ERROR: Could not create work dir: %v", err)
+		return RunOutput{Verdict: "IE"}
+	}
+	defer os.RemoveAll(workDir)
+
+	srcPath := filepath.Join(workDir, "main"+req.Ext)
+	if err := os.WriteFile(srcPath, req.Source, 0644); err != nil {
+		return RunOutput{Verdict: "IE"}
+	}
+
+	// 1. Compilation / Prep (mirrors processSubmission)
+	isPython := req.Ext == ".py"
+	timeLimitMs := req.TimeLimitMs
+	binPath := srcPath
+	if isPython {
+		timeLimitMs = timeLimitMs * 2 // 2x Multiplier for Python
+	} else {
+		binPath = filepath.Join(workDir, "main.exe")
+		if out, err := compileCpp(srcPath, binPath); err != nil {
+			compileOut, _ := truncate(out)
+			return RunOutput{Verdict: "CE", CompileOutput: compileOut}
+		}
+	}
+
+	// 2. Execute in the sandbox
+	inPath := filepath.Join(workDir, "std.in")
+	outPath := filepath.Join(workDir, "std.out")
+	errPath := filepath.Join(workDir, "std.err")
+	if err := os.WriteFile(inPath, req.Stdin, 0644); err != nil {
+		return RunOutput{Verdict: "IE"}
+	}
+
+	res, err := runSecurely(boxID, binPath, isPython, timeLimitMs, inPath, outPath, errPath)
+	if err != nil {
+		log.Printf("RUN ERROR [Box %d]: %v", boxID, err)
+		res.Verdict = "IE"
+	}
+
+	out := RunOutput{Verdict: res.Verdict, TimeMs: res.TimeMs, MemoryKB: res.MemoryKB}
+	if out.Verdict == "AC" {
+		out.Verdict = "OK" // Nothing was checked; it just ran to completion
+	}
+
+	var t1, t2 bool
+	out.Stdout, t1 = readTruncated(outPath)
+	out.Stderr, t2 = readTruncated(errPath)
+	out.Truncated = t1 || t2
+	return out
+}
+
+func readTruncated(path string) (string, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	buf, _ := io.ReadAll(io.LimitReader(f, maxRunOutput+1))
+	return truncate(buf)
+}
+
+func truncate(b []byte) (string, bool) {
+	if len(b) > maxRunOutput {
+		return string(b[:maxRunOutput]), true
+	}
+	return string(b), false
+}
@@ -0,0 +1,28 @@
+package engine
+
+import (
+	"github.com/ifuaslaerl/Judge/internal/data"
+)
+
+// Judge runs the worker and the pending sweep and keeps the scoreboard, all
+// against the stores it was created with (data.NewSQLiteStores in production,
+// data.NewMemoryStores in tests). The queues and the event broadcaster stay
+// package-level: there is one of each per process.
+type Judge struct {
+	stores *data.Stores
+	worker workerState // worker.go
+	health healthState // health.go
+	board  boardCache  // standings.go
+}
+
+// NewJudge returns an idle judge; serve starts its worker and sweep, the CLI
+// only reads its scoreboard
+func NewJudge(s *data.Stores) *Judge {
+	return &Judge{
+		stores: s,
+		worker: workerState{
+			stopping: make(chan struct{}),
+			done:     make(chan struct{}),
+		},
+	}
+}
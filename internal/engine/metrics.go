@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// --- Metrics (served on /metrics) ---
+
+var (
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "judge_queue_depth",
+		Help: "Submissions waiting in SubmissionQueue.",
+	}, func() float64 { return float64(len(SubmissionQueue)) })
+
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "judge_run_queue_depth",
+		Help: "Custom invocations waiting in RunQueue.",
+	}, func() float64 { return float64(len(RunQueue)) })
+
+	judgeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "judge_judging_duration_seconds",
+		Help:    "Time the worker spent on a submission (compile and every test).",
+		Buckets: []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300},
+	}, []string{"language"})
+
+	judgeLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "judge_verdict_latency_seconds",
+		Help:    "Time from submission to verdict, including the wait in the queue.",
+		Buckets: []float64{1, 2, 5, 10, 20, 30, 60, 120, 300, 600, 1200},
+	}, []string{"language"})
+
+	verdicts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "judge_verdicts_total",
+		Help: "Verdicts written by the worker.",
+	}, []string{"verdict", "language", "problem"})
+
+	compileDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "judge_compile_duration_seconds",
+		Help:    "g++ time for submissions and custom runs.",
+		Buckets: []float64{0.25, 0.5, 1, 2, 3, 5, 10, 20},
+	})
+
+	scoreboardRebuild = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "judge_scoreboard_rebuild_seconds",
+		Help:    "Time to rebuild the scoreboard from the database.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 4, 8), // 1ms .. 16s
+	})
+)
+
+// language is the metrics label for a source file
+func language(path string) string {
+	switch filepath.Ext(path) {
+	case ".cpp":
+		return "cpp"
+	case ".py":
+		return "py"
+	default:
+		return "other"
+	}
+}
+
+// verdictLabel drops the test number ("WA on test 3" -> "WA") to bound the label values
+func verdictLabel(verdict string) string {
+	v, _, _ := strings.Cut(verdict, " ")
+	return v
+}
+
+// observeVerdict records a judged submission
+func observeVerdict(srcPath, problem, verdict string, started, submitted time.Time) {
+	lang := language(srcPath)
+	judgeDuration.WithLabelValues(lang).Observe(time.Since(started).Seconds())
+	if !submitted.IsZero() {
+		judgeLatency.WithLabelValues(lang).Observe(time.Since(submitted).Seconds())
+	}
+	verdicts.WithLabelValues(verdictLabel(verdict), lang, problem).Inc()
+}
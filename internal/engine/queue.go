@@ -1,9 +1,103 @@
 package engine
 
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ifuaslaerl/Judge/internal/config"
+)
+
 // SubmissionQueue is the buffered channel for processing submissions
-// Capacity 5000: Covers Max Users (50) * Max Submissions (100) = 100% Guaranteed Non-Blocking
+// Capacity: config limits.queue_size (default 5000 = max users (50) * max submissions (100), never blocks)
 var SubmissionQueue chan int
 
-func InitQueue() {
-	SubmissionQueue = make(chan int, 5000)
+func InitQueue(size int) {
+	SubmissionQueue = make(chan int, size)
+	RunQueue = make(chan *RunRequest, 50) // Custom invocations (see invocation.go)
+}
+
+// Submissions in SubmissionQueue or being judged, so the sweep never queues one twice
+var (
+	queued    = make(map[int]bool)
+	queuedMux sync.Mutex
+)
+
+// Enqueue queues a submission for judging; false if the queue is full
+func Enqueue(id int) bool {
+	queuedMux.Lock()
+	defer queuedMux.Unlock()
+	if queued[id] {
+		return true
+	}
+	select {
+	case SubmissionQueue <- id:
+		queued[id] = true
+		return true
+	default:
+		return false
+	}
+}
+
+// judged is called by the worker once a submission's verdict is written
+func judged(id int) {
+	queuedMux.Lock()
+	delete(queued, id)
+	queuedMux.Unlock()
+}
+
+// StartPendingSweep periodically queues PENDING submissions the server does not
+// know about: leftovers of a crash (the queue lives in memory) and rejudges
+// requested with the "rejudge" command, which runs in another process. It also
+// rebuilds the scoreboard after a CLI wipe or user/problem change.
+func (j *Judge) StartPendingSweep(interval time.Duration) {
+	go func() {
+		for {
+			j.sweepPending()
+			select {
+			case <-j.worker.stopping: // StopWorker: nothing would judge them
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
+
+func (j *Judge) sweepPending() {
+	if j.invalidateIfChanged() {
+		Publish(Event{Type: EventScoreboard})
+	}
+
+	found, err := j.stores.Submissions.PendingSubmissions()
+	if err != nil {
+		log.Printf("SWEEP ERROR: %v", err)
+		return
+	}
+
+	for _, p := range found {
+		queuedMux.Lock()
+		known := queued[p.ID]
+		queuedMux.Unlock()
+		if known {
+			continue
+		}
+		if !Enqueue(p.ID) {
+			log.Printf("SWEEP: Queue full, submission %d stays pending", p.ID)
+			return
+		}
+		log.Printf("SWEEP: Queued pending submission %d", p.ID)
+		j.PublishVerdict(p.UserID, p.ID, "PENDING")
+	}
+}
+
+// Sandbox limits passed to isolate (config [sandbox])
+var sandbox = config.Default().Sandbox
+
+func SetSandbox(s config.Sandbox) {
+	sandbox = s
+}
+
+// SandboxLimits reports the limits every run gets
+func SandboxLimits() config.Sandbox {
+	return sandbox
 }
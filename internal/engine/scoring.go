@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/ifuaslaerl/Judge/internal/config"
+)
+
+// ScoringRules configures how the scoreboard ranks contestants (config [scoring])
+type ScoringRules config.Scoring
+
+// DefaultScoringRules reproduces the classic ICPC rules used so far:
+// 20 minutes per attempt, CE ignored, ties broken by penalty only.
+func DefaultScoringRules() ScoringRules {
+	return ScoringRules(config.Default().Scoring)
+}
+
+// tieBreakers compares two rows; negative means a ranks above b.
+var tieBreakers = map[string]func(a, b *RankRow) int{
+	// Less penalty = better
+	"penalty": func(a, b *RankRow) int { return a.Penalty - b.Penalty },
+	// Earlier last accepted submission = better (ICPC rule)
+	"last_ac": func(a, b *RankRow) int { return a.LastAC - b.LastAC },
+}
+
+var (
+	rules    = DefaultScoringRules()
+	rulesMux sync.RWMutex
+)
+
+// Validate reports configuration mistakes before they reach the scoreboard
+func (r ScoringRules) Validate() error {
+	if r.PenaltyMinutes < 0 {
+		return errors.New("penalty_minutes must not be negative")
+	}
+	if r.FirstSolveBonus < 0 {
+		return errors.New("first_solve_bonus_minutes must not be negative")
+	}
+	for _, v := range r.AttemptVerdicts {
+		switch v {
+		case "WA", "TLE", "RTE", "CE", "IE":
+		default:
+			return fmt.Errorf("unknown verdict %q in attempt_verdicts (want WA, TLE, RTE, CE or IE)", v)
+		}
+	}
+	for _, t := range r.TieBreakers {
+		if _, ok := tieBreakers[t]; !ok {
+			return fmt.Errorf("unknown tie breaker %q (want penalty or last_ac)", t)
+		}
+	}
+	return nil
+}
+
+// ConfigureScoring validates the [scoring] config and makes it the active rules
+func ConfigureScoring(s config.Scoring) error {
+	r := ScoringRules(s)
+	if err := r.Validate(); err != nil {
+		return fmt.Errorf("scoring: %v", err)
+	}
+
+	SetScoringRules(r)
+	log.Printf("SCOREBOARD: Scoring rules: penalty %d, attempts %v, tie breakers %v, first-solve bonus %d",
+		r.PenaltyMinutes, r.AttemptVerdicts, r.TieBreakers, r.FirstSolveBonus)
+	return nil
+}
+
+// ActiveScoringRules returns the rules scoreboards are built with
+func ActiveScoringRules() ScoringRules {
+	rulesMux.RLock()
+	defer rulesMux.RUnlock()
+	return rules
+}
+
+// SetScoringRules replaces the active rules. A scoreboard already built keeps
+// its rules until its next full rebuild (InvalidateScoreboard).
+func SetScoringRules(r ScoringRules) {
+	rulesMux.Lock()
+	rules = r
+	rulesMux.Unlock()
+}
+
+// CountsAsAttempt reports whether a final status ("WA on test 3") is a penalized attempt
+func (r ScoringRules) CountsAsAttempt(status string) bool {
+	verdict, _, _ := strings.Cut(status, " ")
+	for _, v := range r.AttemptVerdicts {
+		if v == verdict {
+			return true
+		}
+	}
+	return false
+}
+
+// compare orders two rows: most solved first, then each tie breaker in turn.
+// Returns 0 when the rows are fully tied (they share a rank).
+func (r ScoringRules) compare(a, b *RankRow) int {
+	if a.Solved != b.Solved {
+		return b.Solved - a.Solved // More solved = better
+	}
+	for _, name := range r.TieBreakers {
+		if c := tieBreakers[name](a, b); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
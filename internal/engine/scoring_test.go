@@ -0,0 +1,179 @@
+package engine
+
+import (
+	"testing"
+)
+
+func TestScoringRulesValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(r *ScoringRules)
+		ok     bool
+	}{
+		{"defaults", func(r *ScoringRules) {}, true},
+		{"every verdict and tie breaker", func(r *ScoringRules) {
+			r.AttemptVerdicts = []string{"WA", "TLE", "RTE", "CE", "IE"}
+			r.TieBreakers = []string{"last_ac", "penalty"}
+		}, true},
+		{"nothing penalized, no tie breakers", func(r *ScoringRules) {
+			r.PenaltyMinutes, r.AttemptVerdicts, r.TieBreakers = 0, nil, nil
+		}, true},
+		{"negative penalty", func(r *ScoringRules) { r.PenaltyMinutes = -1 }, false},
+		{"negative bonus", func(r *ScoringRules) { r.FirstSolveBonus = -5 }, false},
+		{"AC as an attempt", func(r *ScoringRules) { r.AttemptVerdicts = []string{"WA", "AC"} }, false},
+		{"lowercase verdict", func(r *ScoringRules) { r.AttemptVerdicts = []string{"wa"} }, false},
+		{"unknown tie breaker", func(r *ScoringRules) { r.TieBreakers = []string{"penalty", "first_ac"} }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := DefaultScoringRules()
+			tt.change(&r)
+			if err := r.Validate(); (err == nil) != tt.ok {
+				t.Errorf("Validate() = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCountsAsAttempt(t *testing.T) {
+	withCE := DefaultScoringRules()
+	withCE.AttemptVerdicts = append(withCE.AttemptVerdicts, "CE")
+
+	tests := []struct {
+		rules  ScoringRules
+		status string
+		want   bool
+	}{
+		{DefaultScoringRules(), "WA on test 3", true},
+		{DefaultScoringRules(), "TLE on test 1", true},
+		{DefaultScoringRules(), "RTE", true},
+		{DefaultScoringRules(), "IE", true},
+		{DefaultScoringRules(), "CE", false},
+		{withCE, "CE", true},
+		{DefaultScoringRules(), "AC", false},
+		{DefaultScoringRules(), "PENDING", false},
+		{DefaultScoringRules(), "", false},
+		{DefaultScoringRules(), "WAX on test 2", false},
+		{ScoringRules{}, "WA on test 1", false},
+	}
+	for _, tt := range tests {
+		if got := tt.rules.CountsAsAttempt(tt.status); got != tt.want {
+			t.Errorf("CountsAsAttempt(%q) with %v = %v, want %v", tt.status, tt.rules.AttemptVerdicts, got, tt.want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	row := func(solved, penalty, lastAC int) *RankRow {
+		return &RankRow{Solved: solved, Penalty: penalty, LastAC: lastAC}
+	}
+	tests := []struct {
+		name     string
+		breakers []string
+		a, b     *RankRow
+		want     int // Sign only
+	}{
+		{"more solved wins over penalty", []string{"penalty"}, row(3, 500, 90), row(2, 10, 10), -1},
+		{"fewer solved loses", []string{"penalty"}, row(1, 0, 0), row(2, 99, 99), 1},
+		{"penalty", []string{"penalty"}, row(2, 40, 90), row(2, 50, 30), -1},
+		{"penalty ignores last AC", []string{"penalty"}, row(2, 40, 90), row(2, 40, 30), 0},
+		{"last_ac", []string{"last_ac"}, row(2, 40, 90), row(2, 50, 30), 1},
+		{"last_ac breaks a penalty tie", []string{"penalty", "last_ac"}, row(2, 40, 90), row(2, 40, 30), 1},
+		{"penalty decides before last_ac", []string{"penalty", "last_ac"}, row(2, 40, 90), row(2, 50, 30), -1},
+		{"last_ac decides before penalty", []string{"last_ac", "penalty"}, row(2, 40, 90), row(2, 50, 30), 1},
+		{"no tie breakers", nil, row(2, 40, 90), row(2, 50, 30), 0},
+		{"fully tied", []string{"penalty", "last_ac"}, row(2, 40, 30), row(2, 40, 30), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := ScoringRules{TieBreakers: tt.breakers}
+			if got := sign(r.compare(tt.a, tt.b)); got != tt.want {
+				t.Errorf("compare = %d, want %d", got, tt.want)
+			}
+			if got := sign(r.compare(tt.b, tt.a)); got != -tt.want {
+				t.Errorf("reversed compare = %d, want %d", got, -tt.want)
+			}
+		})
+	}
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// testBoard builds a board state for problems 1 (A) and 2 (B). Each user maps
+// a problem ID to its submissions; submission IDs follow the order of subs.
+func testBoard(rules ScoringRules, users []string, subs map[string]map[int][]subRecord) *boardState {
+	st := &boardState{
+		problems: []ProblemMeta{{ID: 1, Letter: "A"}, {ID: 2, Letter: "B"}},
+		letters:  map[int]string{1: "A", 2: "B"},
+		users:    make(map[int]*userState),
+		rules:    rules,
+	}
+	for i, name := range users {
+		u := &userState{name: name, cells: make(map[int]*cellState)}
+		for _, p := range st.problems {
+			cs := &cellState{subs: subs[name][p.ID]}
+			cs.evaluate(rules)
+			u.cells[p.ID] = cs
+		}
+		st.users[i+1] = u
+		st.order = append(st.order, i+1)
+	}
+	return st
+}
+
+func TestSnapshotRanks(t *testing.T) {
+	rules := DefaultScoringRules()
+	rules.FirstSolveBonus = 15
+
+	// Minutes are small here; the bonus may exceed a user's whole penalty
+	st := testBoard(rules, []string{"Ann", "Ben", "Cid", "Dee"}, map[string]map[int][]subRecord{
+		"Ann": {1: {{id: 1, status: "AC", mins: 5}}},                                                   // First on A: 5-15, clamped to 0
+		"Ben": {1: {{id: 2, status: "WA on test 1", mins: 3}, {id: 4, status: "AC", mins: 10}}},        // 10+20
+		"Cid": {1: {{id: 3, status: "CE", mins: 4}, {id: 5, status: "AC", mins: 30}}},                  // CE is free: 30
+		"Dee": {2: {{id: 6, status: "AC", mins: 40}}, 1: {{id: 7, status: "TLE on test 2", mins: 50}}}, // First on B: 40-15
+	})
+	board := st.snapshot()
+
+	want := []struct {
+		name    string
+		rank    int
+		penalty int
+	}{
+		{"Ann", 1, 0},
+		{"Dee", 2, 25},
+		{"Ben", 3, 30},
+		{"Cid", 3, 30},
+	}
+	if len(board.Rows) != len(want) {
+		t.Fatalf("%d rows, want %d", len(board.Rows), len(want))
+	}
+	for i, w := range want {
+		r := board.Rows[i]
+		if r.DisplayName != w.name || r.Rank != w.rank || r.Penalty != w.penalty {
+			t.Errorf("row %d = %s rank %d penalty %d, want %s rank %d penalty %d",
+				i, r.DisplayName, r.Rank, r.Penalty, w.name, w.rank, w.penalty)
+		}
+	}
+
+	if !board.Rows[0].Cells["A"].FirstSolve || board.Rows[2].Cells["A"].FirstSolve {
+		t.Error("first solve of A not marked on Ann only")
+	}
+	if c := board.Rows[1].Cells["A"]; c.Solved || c.Attempts != 1 {
+		t.Errorf("Dee's unsolved A = %+v", c)
+	}
+
+	// last_ac separates Ben (AC at 10) from Cid (AC at 30)
+	st.rules.TieBreakers = []string{"penalty", "last_ac"}
+	board = st.snapshot()
+	if r := board.Rows[2]; r.DisplayName != "Ben" || r.Rank != 3 || board.Rows[3].Rank != 4 {
+		t.Errorf("with last_ac: rows 3-4 = %+v, %+v", board.Rows[2], board.Rows[3])
+	}
+}
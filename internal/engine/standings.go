@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"log"
 	"sort"
 	"sync"
 	"time"
@@ -9,176 +10,379 @@ import (
 
 // --- Structs ---
 
+// JSON tags define the /api/v1/standings payload.
+
 type ProblemMeta struct {
-	ID     int
-	Letter string
+	ID     int    `json:"id"`
+	Letter string `json:"letter"`
 }
 
 type Cell struct {
-	Solved    bool
-	Attempts  int    // Number of WAs before AC (or total tries if not solved)
-	Time      string // Formatted time of AC (or blank)
-	IsPending bool   // Visual cue
+	Solved     bool   `json:"solved"`
+	Attempts   int    `json:"attempts"`    // Number of WAs before AC (or total tries if not solved)
+	Time       string `json:"time"`        // Formatted time of AC (or blank)
+	IsPending  bool   `json:"pending"`     // Visual cue
+	FirstSolve bool   `json:"first_solve"` // First AC on this problem across all users
 }
 
 type RankRow struct {
-	Rank        int
-	DisplayName string
-	Solved      int
-	Penalty     int // In minutes
-	Cells       map[string]Cell
+	Rank        int             `json:"rank"`
+	DisplayName string          `json:"display_name"`
+	Solved      int             `json:"solved"`
+	Penalty     int             `json:"penalty"` // In minutes
+	LastAC      int             `json:"-"`       // Minute of the latest AC (tie breaker)
+	Cells       map[string]Cell `json:"cells"`
 }
 
 type Scoreboard struct {
-	Problems []ProblemMeta
-	Rows     []RankRow
-	LastUpd  time.Time
+	Problems []ProblemMeta `json:"problems"`
+	Rows     []RankRow     `json:"rows"`
+	LastUpd  time.Time     `json:"updated_at"`
 }
 
-// --- Caching ---
+// --- Incremental State ---
+// The scoreboard lives in memory and is patched whenever the worker writes a
+// verdict (ApplyVerdict). Each (user, problem) cell keeps its own submission
+// history, so a verdict only re-evaluates one cell; reads total and re-sort
+// the rows only when something changed since the last snapshot.
+// A full rebuild from the stores happens on startup, after InvalidateScoreboard
+// (rejudge, wipe) or when a verdict refers to a user/problem not yet loaded.
 
-var (
-	cache      *Scoreboard
-	cacheMutex sync.RWMutex
-	cacheTTL   = 30 * time.Second
-)
+type subRecord struct {
+	id     int
+	status string
+	mins   int
+}
+
+type cellState struct {
+	subs    []subRecord // Sorted by submission ID (chronological)
+	cell    Cell
+	penalty int // Contribution to the row penalty (0 if unsolved)
+	acID    int // Submission ID of the AC (first-solve detection)
+	acMins  int
+}
+
+type userState struct {
+	name  string
+	cells map[int]*cellState // Problem ID -> cell
+}
+
+type boardState struct {
+	problems []ProblemMeta
+	letters  map[int]string // Problem ID -> Letter
+	users    map[int]*userState
+	excluded map[int]bool // Known users the board leaves out (admins)
+	order    []int        // User IDs in DB order (stable tie order)
+	rules    ScoringRules // Active when the state was built
+}
+
+type boardCache struct {
+	mu       sync.RWMutex
+	state    *boardState // nil: needs a full rebuild
+	snapshot *Scoreboard // nil: state changed since the last read
+}
 
 // --- Logic ---
 
-func GetScoreboard() (*Scoreboard, error) {
-	cacheMutex.RLock()
-	if cache != nil && time.Since(cache.LastUpd) < cacheTTL {
-		defer cacheMutex.RUnlock()
-		return cache, nil
+func (j *Judge) GetScoreboard() (*Scoreboard, error) {
+	b := &j.board
+	b.mu.RLock()
+	if b.snapshot != nil {
+		defer b.mu.RUnlock()
+		return b.snapshot, nil
+	}
+	b.mu.RUnlock() // Release read lock to acquire write lock
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Double-check inside lock: another reader may have built it already
+	if b.snapshot != nil {
+		return b.snapshot, nil
+	}
+	if b.state == nil {
+		if err := j.rebuildLocked(); err != nil {
+			return nil, err
+		}
+	}
+	b.snapshot = b.state.snapshot()
+	return b.snapshot, nil
+}
+
+// RebuildScoreboard reloads the full state from the stores (startup)
+func (j *Judge) RebuildScoreboard() error {
+	j.board.mu.Lock()
+	defer j.board.mu.Unlock()
+	return j.rebuildLocked()
+}
+
+// InvalidateScoreboard forces a full rebuild on the next read (rejudge, wipe)
+func (j *Judge) InvalidateScoreboard() {
+	j.board.mu.Lock()
+	j.board.state = nil
+	j.board.snapshot = nil
+	j.board.mu.Unlock()
+}
+
+// ApplyVerdict patches the in-memory board after a submission is created or judged
+func (j *Judge) ApplyVerdict(submissionID int, status string) {
+	sub, err := j.stores.Submissions.Submission(submissionID)
+	if err != nil {
+		log.Printf("SCOREBOARD WARNING: Could not load submission %d: %v", submissionID, err)
+		j.InvalidateScoreboard()
+		return
+	}
+
+	b := &j.board
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == nil {
+		return // Next read rebuilds from the stores, which already have this verdict
+	}
+
+	userID, problemID := sub.UserID, sub.ProblemID
+	mins := int(sub.CreatedAt.Unix() / 60)
+	user, uExists := b.state.users[userID]
+	_, pExists := b.state.letters[problemID]
+	if !uExists && j.excludedLocked(userID) {
+		return // Admins test the judge without showing up in the standings
+	}
+	if !uExists || !pExists {
+		// Users/problems added since the last rebuild (e.g. via CLI or sqlite3)
+		b.state = nil
+		b.snapshot = nil
+		return
+	}
+
+	cs := user.cells[problemID]
+	i := sort.Search(len(cs.subs), func(i int) bool { return cs.subs[i].id >= submissionID })
+	if i < len(cs.subs) && cs.subs[i].id == submissionID {
+		cs.subs[i].status = status
+	} else {
+		cs.subs = append(cs.subs, subRecord{})
+		copy(cs.subs[i+1:], cs.subs[i:])
+		cs.subs[i] = subRecord{id: submissionID, status: status, mins: mins}
+	}
+
+	cs.evaluate(b.state.rules)
+	b.snapshot = nil
+}
+
+// invalidateIfChanged drops the board when its users or problems no longer
+// match the stores: CLI commands (wipe, user add/delete, problem import) run in
+// another process and cannot tell the server. The pending sweep calls it.
+func (j *Judge) invalidateIfChanged() bool {
+	users, err := j.stores.Users.Contestants()
+	if err != nil {
+		return false
+	}
+	problems, err := j.stores.Problems.Problems()
+	if err != nil {
+		return false
 	}
-	cacheMutex.RUnlock() // Release read lock to acquire write lock
 
-	return generateScoreboard()
+	j.board.mu.RLock()
+	st := j.board.state
+	same := st == nil || st.matches(users, problems)
+	j.board.mu.RUnlock()
+	if same {
+		return false
+	}
+	log.Println("SCOREBOARD: Users or problems changed outside the server, rebuilding")
+	j.InvalidateScoreboard()
+	return true
 }
 
-func generateScoreboard() (*Scoreboard, error) {
-	cacheMutex.Lock()
-	defer cacheMutex.Unlock()
+func (st *boardState) matches(users []data.User, problems []data.Problem) bool {
+	if len(users) != len(st.order) || len(problems) != len(st.problems) {
+		return false
+	}
+	for i, u := range users {
+		if st.order[i] != u.ID || st.users[u.ID].name != u.DisplayName {
+			return false
+		}
+	}
+	for i, p := range problems {
+		if st.problems[i] != (ProblemMeta{ID: p.ID, Letter: p.Letter}) {
+			return false
+		}
+	}
+	return true
+}
 
-	// Double-check cache inside lock to prevent race condition
-	if cache != nil && time.Since(cache.LastUpd) < cacheTTL {
-		return cache, nil
+// excludedLocked reports whether userID is a user the board leaves out on
+// purpose, remembering the answer until the next rebuild
+func (j *Judge) excludedLocked(userID int) bool {
+	st := j.board.state
+	if st.excluded[userID] {
+		return true
+	}
+	u, err := j.stores.Users.User(userID)
+	if err != nil || !u.IsAdmin {
+		return false
 	}
+	st.excluded[userID] = true
+	return true
+}
+
+func (j *Judge) rebuildLocked() error {
+	start := time.Now()
+	j.board.snapshot = nil
 
 	// 1. Fetch Problems
-	pRows, err := data.DB.Query("SELECT id, letter_code FROM problems ORDER BY letter_code")
+	problems, err := j.stores.Problems.Problems()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer pRows.Close()
 
-	var problems []ProblemMeta
-	probMap := make(map[int]string) // ID -> Letter
-	for pRows.Next() {
-		var p ProblemMeta
-		if err := pRows.Scan(&p.ID, &p.Letter); err != nil { continue }
-		problems = append(problems, p)
-		probMap[p.ID] = p.Letter
+	st := &boardState{
+		letters:  make(map[int]string),
+		users:    make(map[int]*userState),
+		excluded: make(map[int]bool),
+		rules:    ActiveScoringRules(),
+	}
+	for _, p := range problems {
+		st.problems = append(st.problems, ProblemMeta{ID: p.ID, Letter: p.Letter})
+		st.letters[p.ID] = p.Letter
 	}
 
-	// 2. Fetch Users
-	uRows, err := data.DB.Query("SELECT id, display_name FROM users")
+	// 2. Fetch Users (admins are not ranked)
+	users, err := j.stores.Users.Contestants()
 	if err != nil {
-		return nil, err
-	}
-	defer uRows.Close()
-
-	// Map: UserID -> Row Pointer
-	userMap := make(map[int]*RankRow)
-	var rows []*RankRow
-
-	for uRows.Next() {
-		var uid int
-		var name string
-		if err := uRows.Scan(&uid, &name); err != nil { continue }
-		
-		r := &RankRow{
-			DisplayName: name,
-			Cells:       make(map[string]Cell),
-		}
-		// Initialize empty cells for all problems
-		for _, p := range problems {
-			r.Cells[p.Letter] = Cell{Solved: false, Attempts: 0}
+		return err
+	}
+	for _, c := range users {
+		u := &userState{name: c.DisplayName, cells: make(map[int]*cellState)}
+		for _, p := range st.problems {
+			u.cells[p.ID] = &cellState{}
 		}
-		
-		userMap[uid] = r
-		rows = append(rows, r)
+		st.users[c.ID] = u
+		st.order = append(st.order, c.ID)
 	}
 
-	// 3. Fetch Submissions (Ordered by time to process logic chronologically)
-	// We only care about: Who, What, Status, Time
-	sRows, err := data.DB.Query(`
-		SELECT user_id, problem_id, status, 
-		(strftime('%s', created_at) / 60) as minutes -- simplified relative time
-		FROM submissions ORDER BY id ASC
-	`)
+	// 3. Fetch Submissions (newest first: walk backwards so each cell's history is chronological)
+	subs, err := j.stores.Submissions.ListSubmissions(data.SubmissionFilter{})
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer sRows.Close()
 
-	for sRows.Next() {
-		var uid, pid, mins int
-		var status string
-		if err := sRows.Scan(&uid, &pid, &status, &mins); err != nil { continue }
+	count := 0
+	for i := len(subs) - 1; i >= 0; i-- {
+		s := &subs[i]
+		u, uExists := st.users[s.UserID]
+		if !uExists {
+			continue
+		}
+		cs, pExists := u.cells[s.ProblemID]
+		if !pExists {
+			continue
+		}
 
-		row, uExists := userMap[uid]
-		letter, pExists := probMap[pid]
+		cs.subs = append(cs.subs, subRecord{id: s.ID, status: s.Status, mins: int(s.CreatedAt.Unix() / 60)})
+		count++
+	}
 
-		if !uExists || !pExists { continue }
+	// 4. Evaluate every cell once
+	for _, u := range st.users {
+		for _, cs := range u.cells {
+			cs.evaluate(st.rules)
+		}
+	}
 
-		cell := row.Cells[letter]
+	j.board.state = st
+	scoreboardRebuild.Observe(time.Since(start).Seconds())
+	log.Printf("SCOREBOARD: Rebuilt from %d submissions in %v", count, time.Since(start))
+	return nil
+}
 
+// evaluate replays a cell's history in order under rules
+func (cs *cellState) evaluate(rules ScoringRules) {
+	cell := Cell{}
+	cs.penalty, cs.acID, cs.acMins = 0, 0, 0
+
+	for _, s := range cs.subs {
 		// If already solved, ignore future submissions
 		if cell.Solved {
-			continue
+			break
 		}
 
-		if status == "AC" {
+		if s.status == "AC" {
 			cell.Solved = true
-			cell.Time = formatTime(mins) // e.g. "120"
-			
-			// Update Row Stats
-			row.Solved++
-			// Penalty = Time + (20 * Previous Wrong Attempts)
-			row.Penalty += mins + (20 * cell.Attempts)
-		} else if status == "PENDING" {
+			cell.Time = formatTime(s.mins) // e.g. "120"
+			// Penalty = Time + (PenaltyMinutes * Previous Counted Attempts)
+			cs.penalty = s.mins + (rules.PenaltyMinutes * cell.Attempts)
+			cs.acID, cs.acMins = s.id, s.mins
+		} else if s.status == "PENDING" {
 			cell.IsPending = true
-		} else if status != "CE" { // Ignore Compile Errors for penalty? Standard rules usually ignore CE.
-			cell.Attempts++ // WA, TLE, RTE count as penalty attempt
+		} else if rules.CountsAsAttempt(s.status) {
+			cell.Attempts++ // By default WA, TLE, RTE, IE count; CE does not
+		}
+	}
+	cs.cell = cell
+}
+
+// snapshot totals and sorts the rows into an immutable Scoreboard for readers
+func (st *boardState) snapshot() *Scoreboard {
+	rules := st.rules
+
+	// First solver per problem: the lowest AC submission ID across all users
+	firstAC := make(map[int]int)
+	for _, u := range st.users {
+		for pid, cs := range u.cells {
+			if cs.acID != 0 && (firstAC[pid] == 0 || cs.acID < firstAC[pid]) {
+				firstAC[pid] = cs.acID
+			}
 		}
-		
-		row.Cells[letter] = cell
 	}
 
-	// 4. Sort Rows
-	sort.Slice(rows, func(i, j int) bool {
-		if rows[i].Solved != rows[j].Solved {
-			return rows[i].Solved > rows[j].Solved // More solved = better
+	rows := make([]RankRow, 0, len(st.order))
+	for _, uid := range st.order {
+		u := st.users[uid]
+		r := RankRow{
+			DisplayName: u.name,
+			Cells:       make(map[string]Cell, len(st.problems)),
 		}
-		return rows[i].Penalty < rows[j].Penalty // Less penalty = better
+		for _, p := range st.problems {
+			cs := u.cells[p.ID]
+			cell := cs.cell
+			cell.FirstSolve = cell.Solved && cs.acID == firstAC[p.ID]
+			r.Cells[p.Letter] = cell
+			if !cell.Solved {
+				continue
+			}
+			r.Solved++
+			r.Penalty += cs.penalty
+			if cell.FirstSolve {
+				r.Penalty -= rules.FirstSolveBonus
+			}
+			if cs.acMins > r.LastAC {
+				r.LastAC = cs.acMins
+			}
+		}
+		r.Penalty = max(r.Penalty, 0) // The first-solve bonus never earns time
+		rows = append(rows, r)
+	}
+
+	// Sort Rows (table-driven: most solved, then configured tie breakers)
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rules.compare(&rows[i], &rows[j]) < 0
 	})
 
-	// 5. Assign Ranks & Convert to Value Slice
-	finalRows := make([]RankRow, len(rows))
-	for i, r := range rows {
-		r.Rank = i + 1
-		finalRows[i] = *r
+	// Assign Ranks (fully tied rows share a rank)
+	for i := range rows {
+		if i > 0 && rules.compare(&rows[i-1], &rows[i]) == 0 {
+			rows[i].Rank = rows[i-1].Rank
+		} else {
+			rows[i].Rank = i + 1
+		}
 	}
 
-	newBoard := &Scoreboard{
-		Problems: problems,
-		Rows:     finalRows,
+	return &Scoreboard{
+		Problems: st.problems,
+		Rows:     rows,
 		LastUpd:  time.Now(),
 	}
-
-	cache = newBoard
-	return newBoard, nil
 }
 
 // formatTime converts raw minutes (from epoch) to something readable if needed.
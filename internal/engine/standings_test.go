@@ -0,0 +1,151 @@
+package engine
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ifuaslaerl/Judge/internal/data"
+)
+
+func TestApplyVerdictKeepsStateForAdmins(t *testing.T) {
+	stores, m := data.NewMemoryStores()
+	m.AddUser(data.User{ID: 1, Username: "alice", DisplayName: "Alice"})
+	m.AddUser(data.User{ID: 2, Username: "judge", IsAdmin: true})
+	m.AddProblem(data.Problem{ID: 1, Letter: "A"})
+	j := NewJudge(stores)
+	if err := j.RebuildScoreboard(); err != nil {
+		t.Fatal(err)
+	}
+	state := j.board.state
+
+	id, _ := stores.Submissions.CreateSubmission(2, 1)
+	j.ApplyVerdict(id, "PENDING")
+	stores.Submissions.SetVerdict(id, "AC", "")
+	j.ApplyVerdict(id, "AC")
+	if j.board.state != state {
+		t.Fatal("an admin's submission forced a full rebuild")
+	}
+
+	// Users the board does not know yet still trigger one
+	m.AddUser(data.User{ID: 3, Username: "carol", DisplayName: "Carol"})
+	id, _ = stores.Submissions.CreateSubmission(3, 1)
+	j.ApplyVerdict(id, "PENDING")
+	if j.board.state != nil {
+		t.Fatal("a new contestant did not invalidate the board")
+	}
+	board, err := j.GetScoreboard()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(board.Rows) != 2 || !board.Rows[1].Cells["A"].IsPending {
+		t.Errorf("rows after rebuild = %+v", board.Rows)
+	}
+}
+
+func TestInvalidateIfChanged(t *testing.T) {
+	stores, m := data.NewMemoryStores()
+	m.AddUser(data.User{ID: 1, Username: "alice", DisplayName: "Alice"})
+	m.AddProblem(data.Problem{ID: 1, Letter: "A"})
+	j := NewJudge(stores)
+	if err := j.RebuildScoreboard(); err != nil {
+		t.Fatal(err)
+	}
+	if j.invalidateIfChanged() {
+		t.Fatal("an unchanged database invalidated the board")
+	}
+
+	// What another process ("user add", "problem import") would write
+	for _, change := range []func(){
+		func() { m.AddUser(data.User{ID: 2, Username: "bob", DisplayName: "Bob"}) },
+		func() { m.AddUser(data.User{ID: 1, Username: "alice", DisplayName: "Alice B."}) },
+		func() { m.AddProblem(data.Problem{ID: 2, Letter: "B"}) },
+	} {
+		change()
+		if !j.invalidateIfChanged() || j.board.state != nil {
+			t.Fatal("a change made outside the server kept the old board")
+		}
+		if err := j.RebuildScoreboard(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// seedBenchDB fills a fresh SQLite database with a contest of users x
+// problems and subs submissions spread over five hours
+func seedBenchDB(b *testing.B, users, problems, subs int) *data.Stores {
+	b.Helper()
+	data.OpenDB(filepath.Join(b.TempDir(), "judge.sqlite"))
+	b.Cleanup(func() { data.DB.Close() })
+	if _, err := data.Migrate(); err != nil {
+		b.Fatal(err)
+	}
+
+	tx, err := data.DB.Begin()
+	if err != nil {
+		b.Fatal(err)
+	}
+	for u := 1; u <= users; u++ {
+		tx.Exec("INSERT INTO users (id, username, display_name, password_hash) VALUES (?, ?, ?, '')", u, fmt.Sprintf("user%d", u), fmt.Sprintf("Team %d", u))
+	}
+	for p := 1; p <= problems; p++ {
+		tx.Exec("INSERT INTO problems (id, letter_code, time_limit, pdf_path) VALUES (?, ?, 1000, '')", p, string(rune('A'+p-1)))
+	}
+	statuses := []string{"AC", "WA on test 2", "TLE on test 5", "CE", "RTE on test 1", "WA on test 7"}
+	start := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := 1; i <= subs; i++ {
+		at := start.Add(time.Duration(i) * 5 * time.Hour / time.Duration(subs))
+		_, err := tx.Exec("INSERT INTO submissions (id, user_id, problem_id, status, file_path, created_at) VALUES (?, ?, ?, ?, 'x.cpp', ?)",
+			i, 1+rng.IntN(users), 1+rng.IntN(problems), statuses[rng.IntN(len(statuses))], at.Format("2006-01-02 15:04:05"))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		b.Fatal(err)
+	}
+	return data.NewSQLiteStores(data.DB)
+}
+
+const (
+	benchUsers       = 300
+	benchProblems    = 13
+	benchSubmissions = 30000
+)
+
+// BenchmarkRebuildScoreboard is the full rebuild done at startup and after a rejudge
+func BenchmarkRebuildScoreboard(b *testing.B) {
+	j := NewJudge(seedBenchDB(b, benchUsers, benchProblems, benchSubmissions))
+	b.ResetTimer()
+	for b.Loop() {
+		j.InvalidateScoreboard()
+		if _, err := j.GetScoreboard(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkApplyVerdict is what one verdict costs once the board is built: the
+// incremental patch plus the next read re-totalling and sorting the rows
+func BenchmarkApplyVerdict(b *testing.B) {
+	j := NewJudge(seedBenchDB(b, benchUsers, benchProblems, benchSubmissions))
+	if _, err := j.GetScoreboard(); err != nil {
+		b.Fatal(err)
+	}
+	state := j.board.state
+	rng := rand.New(rand.NewPCG(3, 4))
+	b.ResetTimer()
+	for b.Loop() {
+		j.ApplyVerdict(1+rng.IntN(benchSubmissions), "AC")
+		if _, err := j.GetScoreboard(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	if j.board.state != state {
+		b.Fatal("a verdict forced a full rebuild")
+	}
+}
@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// --- Test Layout ---
+// storage/problems/[id]/samples/*.in + *.out  Public, shown on the problem page, judged first
+// storage/problems/[id]/tests/*.in + *.out    Hidden
+// Samples take the first test numbers, so "WA on test 1" can be a sample.
+
+// Samples larger than this are not rendered on the problem page
+const maxSampleSize = 16 * 1024
+
+type TestCase struct {
+	Number     int
+	InputPath  string
+	OutputPath string
+	Sample     bool
+}
+
+type Sample struct {
+	Number int
+	Input  string
+	Output string
+}
+
+func problemDir(problemID int) string {
+	return filepath.Join("storage", "problems", strconv.Itoa(problemID))
+}
+
+// LoadTests lists all tests of a problem in judging order: samples, then hidden tests
+func LoadTests(problemID int) []TestCase {
+	var tests []TestCase
+	for _, dir := range []string{"samples", "tests"} {
+		for _, inPath := range sortedInputs(filepath.Join(problemDir(problemID), dir)) {
+			tests = append(tests, TestCase{
+				Number:     len(tests) + 1,
+				InputPath:  inPath,
+				OutputPath: strings.TrimSuffix(inPath, ".in") + ".out",
+				Sample:     dir == "samples",
+			})
+		}
+	}
+	return tests
+}
+
+// LoadSamples reads the public samples for display. Oversized files are skipped.
+func LoadSamples(problemID int) []Sample {
+	var samples []Sample
+	for _, t := range LoadTests(problemID) {
+		if !t.Sample {
+			break
+		}
+		in, err1 := readSmall(t.InputPath)
+		out, err2 := readSmall(t.OutputPath)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		samples = append(samples, Sample{Number: t.Number, Input: in, Output: out})
+	}
+	return samples
+}
+
+func readSmall(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.Size() > maxSampleSize {
+		return "", os.ErrInvalid
+	}
+	b, err := os.ReadFile(path)
+	return string(b), err
+}
+
+// sortedInputs returns dir/*.in sorted numerically when the names are numbers
+// (2.in before 10.in), falling back to string order otherwise
+func sortedInputs(dir string) []string {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.in"))
+	sort.SliceStable(files, func(i, j int) bool {
+		a, errA := strconv.Atoi(strings.TrimSuffix(filepath.Base(files[i]), ".in"))
+		b, errB := strconv.Atoi(strings.TrimSuffix(filepath.Base(files[j]), ".in"))
+		if errA == nil && errB == nil {
+			return a < b
+		}
+		return files[i] < files[j]
+	})
+	return files
+}
@@ -1,42 +1,114 @@
 package engine
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 	"github.com/ifuaslaerl/Judge/internal/data"
 )
 
-func StartWorker() {
+// Worker lifecycle (see StopWorker)
+type workerState struct {
+	stopping chan struct{} // Closed: take no new jobs
+	stopOnce sync.Once
+	abortJob atomic.Bool   // Set: abandon the current submission before its next test
+	done     chan struct{} // Closed when StartWorker returns
+}
+
+// StartWorker judges submissions. Custom invocations (RunQueue) are only
+// picked up when no submission is waiting, so they never delay judging.
+func (j *Judge) StartWorker() {
 	log.Println("WORKER: Started. Waiting for submissions...")
-	for submissionID := range SubmissionQueue {
-		log.Printf("WORKER: Processing Submission %d", submissionID)
-		processSubmission(submissionID)
+	j.setWorkerRunning(true)
+	defer close(j.worker.done)
+	defer j.setWorkerRunning(false)
+	for {
+		// Priority pass: stop requests, then submissions
+		select {
+		case <-j.worker.stopping:
+			log.Println("WORKER: Stopped.")
+			return
+		default:
+		}
+		select {
+		case submissionID := <-SubmissionQueue:
+			j.judge(submissionID)
+			continue
+		default:
+		}
+
+		select {
+		case <-j.worker.stopping:
+		case submissionID := <-SubmissionQueue:
+			j.judge(submissionID)
+		case req := <-RunQueue:
+			processRun(req)
+		}
 	}
 }
 
-func processSubmission(id int) {
+func (j *Judge) judge(id int) {
+	log.Printf("WORKER: Processing Submission %d", id)
+	j.setCurrent(id)
+	j.processSubmission(id)
+	j.setCurrent(0)
+	judged(id)
+}
+
+// StopWorker stops taking jobs and waits for the submission being judged.
+// After timeout the worker abandons it before its next test: it stays PENDING
+// and the pending sweep judges it again after the restart, like everything
+// still queued.
+func (j *Judge) StopWorker(timeout time.Duration) {
+	j.worker.stopOnce.Do(func() { close(j.worker.stopping) })
+	select {
+	case <-j.worker.done:
+		return
+	case <-time.After(timeout):
+	}
+	log.Printf("WORKER: Judging did not finish within %s, abandoning the current submission", timeout)
+	j.worker.abortJob.Store(true)
+	<-j.worker.done
+}
+
+// abandoned reports whether StopWorker gave up waiting for submission id
+func (j *Judge) abandoned(id int) bool {
+	if !j.worker.abortJob.Load() {
+		return false
+	}
+	log.Printf("WORKER [Sub %d]: Abandoned for shutdown, left PENDING", id)
+	return true
+}
+
+func (j *Judge) processSubmission(id int) {
+	started := time.Now()
+
 	// 1. Fetch File Path AND Info
-	var srcPath string
-	var timeLimitMs, problemID int
-	
-	query := `
-		SELECT s.file_path, p.time_limit, p.id 
-		FROM submissions s
-		JOIN problems p ON s.problem_id = p.id
-		WHERE s.id = ?
-	`
-	err := data.DB.QueryRow(query, id).Scan(&srcPath, &timeLimitMs, &problemID)
+	sub, err := j.stores.Submissions.Submission(id)
 	if err != nil {
 		log.Printf("WORKER ERROR [Sub %d]: Could not fetch data: %v", id, err)
 		return
 	}
+	problem, err := j.stores.Problems.Problem(sub.ProblemID)
+	if err != nil {
+		log.Printf("WORKER ERROR [Sub %d]: Could not fetch problem: %v", id, err)
+		return
+	}
+	srcPath, timeLimitMs, problemID, userID := sub.FilePath, problem.TimeLimit, problem.ID, sub.UserID
+
+	// Drop results of a previous run (rejudge)
+	if err := j.stores.Submissions.ResetResults(id); err != nil {
+		log.Printf("WORKER WARNING [Sub %d]: Could not reset previous results: %v", id, err)
+	}
 
 	// 2. Compilation / Prep
 	ext := filepath.Ext(srcPath)
@@ -48,21 +120,32 @@ func processSubmission(id int) {
 		timeLimitMs = timeLimitMs * 2 // 2x Multiplier for Python
 	} else {
 		binPath = strings.Replace(srcPath, ".cpp", ".exe", 1)
-		cmd := exec.Command("g++", "-O2", "-std=c++17", srcPath, "-o", binPath)
-		if _, err := cmd.CombinedOutput(); err != nil {
-			data.DB.Exec("UPDATE submissions SET status = 'CE' WHERE id = ?", id)
+		if out, err := compileCpp(srcPath, binPath); err != nil {
+			compileOut, _ := truncate(out)
+			j.setVerdict(sub, "CE", compileOut, started)
+			j.PublishVerdict(userID, id, "CE")
 			return
 		}
 		defer os.Remove(binPath)
 	}
 
+	if j.abandoned(id) {
+		return
+	}
+
 	// 3. Identify Tests
-	// Look for storage/problems/[id]/tests/*.in
-	testDir := filepath.Join("storage", "problems", strconv.Itoa(problemID), "tests")
-	tests, _ := filepath.Glob(filepath.Join(testDir, "*.in"))
-	
-	// Sort tests numerically if possible, or string sort otherwise
-	sort.Strings(tests) 
+	// Samples (storage/problems/[id]/samples) first, then hidden tests (.../tests)
+	tests := LoadTests(problemID)
+
+	// Custom checker (storage/problems/[id]/checker), nil = token comparison
+	checker, err := LoadChecker(problemID)
+	if err != nil {
+		log.Printf("WORKER ERROR [Sub %d]: %v", id, err)
+		j.recordInternalError(id, sub.Problem, 0, err)
+		j.setVerdict(sub, "IE", "", started)
+		j.PublishVerdict(userID, id, "IE")
+		return
+	}
 
 	finalVerdict := "AC" // Optimistic default
 
@@ -71,59 +154,152 @@ func processSubmission(id int) {
 		// BLIND MODE: No tests defined. Run once.
 		// If it doesn't crash, we give AC.
 		log.Printf("WORKER [Sub %d]: No tests found. Running Blind Mode.", id)
-		verdict, _ := runSecurely(id, binPath, isPython, timeLimitMs, "", "")
-		if verdict != "AC" {
-			finalVerdict = verdict // RTE or TLE
+		res, err := runSecurely(id%100, binPath, isPython, timeLimitMs, "", "", "")
+		if res.Verdict == "IE" {
+			j.recordInternalError(id, sub.Problem, 0, sandboxError(err))
+		}
+		if res.Verdict != "AC" {
+			finalVerdict = res.Verdict // RTE or TLE
 		}
 	} else {
 		// TEST MODE: Iterate over files
-		for i, inPath := range tests {
-			// Derive expected output path: 1.in -> 1.out
-			outPath := strings.TrimSuffix(inPath, ".in") + ".out"
-			
-			// Temp file for user output
-			userOutPath := fmt.Sprintf("/tmp/sub_%d_test_%d.out", id, i+1)
+		for _, t := range tests {
+			if j.abandoned(id) {
+				return
+			}
+
+			// Temp files for user output
+			userOutPath := fmt.Sprintf("/tmp/sub_%d_test_%d.out", id, t.Number)
+			userErrPath := ""
+			if t.Sample {
+				userErrPath = fmt.Sprintf("/tmp/sub_%d_test_%d.err", id, t.Number)
+			}
 
-			verdict, err := runSecurely(id, binPath, isPython, timeLimitMs, inPath, userOutPath)
+			res, err := runSecurely(id%100, binPath, isPython, timeLimitMs, t.InputPath, userOutPath, userErrPath)
 			
 			// 1. Runtime/Time Check
-			if verdict != "AC" {
-				finalVerdict = fmt.Sprintf("%s on test %d", verdict, i+1)
+			if res.Verdict != "AC" {
+				if res.Verdict == "IE" {
+					j.recordInternalError(id, sub.Problem, t.Number, sandboxError(err))
+				}
+				j.recordTest(id, t, res, sampleOutput(t, userOutPath, userErrPath))
+				finalVerdict = fmt.Sprintf("%s on test %d", res.Verdict, t.Number)
+				cleanupOutputs(userOutPath, userErrPath)
 				break
 			} else if err != nil {
 				// System error
+				j.recordInternalError(id, sub.Problem, t.Number, err)
+				res.Verdict = "IE"
+				j.recordTest(id, t, res, "")
 				finalVerdict = "IE"
+				cleanupOutputs(userOutPath, userErrPath)
 				break
 			}
 
-			// 2. Correctness Check (Comparator)
-			match, err := CompareFiles(userOutPath, outPath)
-			os.Remove(userOutPath) // Cleanup
+			// 2. Correctness Check (Checker or Comparator)
+			var match bool
+			if checker != nil {
+				match, err = checker.Check(t.InputPath, userOutPath, t.OutputPath)
+			} else {
+				match, err = CompareFiles(userOutPath, t.OutputPath)
+			}
 
 			if err != nil {
-				log.Printf("Comparator Error: %v", err) // Missing .out file?
+				log.Printf("Comparator Error: %v", err) // Missing .out file or broken checker?
+				j.recordInternalError(id, sub.Problem, t.Number, fmt.Errorf("checker: %v", err))
+				res.Verdict = "IE"
+				j.recordTest(id, t, res, "")
 				finalVerdict = "IE"
+				cleanupOutputs(userOutPath, userErrPath)
 				break
 			}
 			
 			if !match {
-				finalVerdict = fmt.Sprintf("WA on test %d", i+1)
+				res.Verdict = "WA"
+				j.recordTest(id, t, res, sampleOutput(t, userOutPath, userErrPath))
+				finalVerdict = fmt.Sprintf("WA on test %d", t.Number)
+				cleanupOutputs(userOutPath, userErrPath)
 				break
 			}
+			j.recordTest(id, t, res, "")
+			cleanupOutputs(userOutPath, userErrPath)
 		}
 	}
 
 	// 5. Update DB
 	log.Printf("WORKER [Sub %d]: Final Verdict -> %s", id, finalVerdict)
-	data.DB.Exec("UPDATE submissions SET status = ? WHERE id = ?", finalVerdict, id)
+	j.setVerdict(sub, finalVerdict, "", started)
+	j.PublishVerdict(userID, id, finalVerdict)
 }
 
-// runSecurely executes the binary.
+// RunResult is the outcome of a single sandboxed execution
+type RunResult struct {
+	Verdict  string
+	TimeMs   int
+	MemoryKB int
+}
+
+// recordTest stores the outcome of one test for the submission detail views.
+// output is only kept for failed samples (hidden test data never leaks).
+func (j *Judge) recordTest(submissionID int, t TestCase, res RunResult, output string) {
+	err := j.stores.Submissions.RecordTest(submissionID, data.TestResult{
+		Number:   t.Number,
+		Verdict:  res.Verdict,
+		TimeMs:   res.TimeMs,
+		MemoryKB: res.MemoryKB,
+		Sample:   t.Sample,
+		Output:   output,
+	})
+	if err != nil {
+		log.Printf("WORKER WARNING [Sub %d]: Could not record test %d: %v", submissionID, t.Number, err)
+	}
+}
+
+func (j *Judge) setVerdict(sub *data.Submission, verdict, compileOutput string, started time.Time) {
+	if err := j.stores.Submissions.SetVerdict(sub.ID, verdict, compileOutput); err != nil {
+		log.Printf("WORKER ERROR [Sub %d]: Could not store verdict %s: %v", sub.ID, verdict, err)
+		return
+	}
+	observeVerdict(sub.FilePath, sub.Problem, verdict, started, sub.CreatedAt)
+}
+
+// compileCpp builds a C++ source (submissions and custom runs alike)
+func compileCpp(srcPath, binPath string) ([]byte, error) {
+	defer func(start time.Time) {
+		compileDuration.Observe(time.Since(start).Seconds())
+	}(time.Now())
+	return exec.Command("g++", "-O2", "-std=c++17", srcPath, "-o", binPath).CombinedOutput()
+}
+
+// sampleOutput collects what the contestant's program printed on a failed sample
+func sampleOutput(t TestCase, outPath, errPath string) string {
+	if !t.Sample {
+		return ""
+	}
+	stdout, cut := readTruncated(outPath)
+	if cut {
+		stdout += "\n[output truncated]"
+	}
+	if stderr, _ := readTruncated(errPath); stderr != "" {
+		stdout += "\n--- stderr ---\n" + stderr
+	}
+	return stdout
+}
+
+func cleanupOutputs(paths ...string) {
+	for _, p := range paths {
+		if p != "" {
+			os.Remove(p)
+		}
+	}
+}
+
+// runSecurely executes the binary in isolate box boxID.
 // If inputPath is empty, it runs without input redirection.
-// If outputPath is provided, it redirects user stdout there.
-func runSecurely(submissionID int, hostBinPath string, isPython bool, timeLimitMs int, inputPath, outputPath string) (string, error) {
-	boxID := submissionID % 100
+// If outputPath/errorPath are provided, user stdout/stderr are copied there.
+func runSecurely(boxID int, hostBinPath string, isPython bool, timeLimitMs int, inputPath, outputPath, errorPath string) (RunResult, error) {
 	metaFile := fmt.Sprintf("/tmp/isolate_meta_%d.txt", boxID)
+	usedBoxes.Store(boxID, true)
 
 	// A. Init
 	exec.Command("isolate", "--cleanup", fmt.Sprintf("--box-id=%d", boxID)).Run()
@@ -148,8 +324,8 @@ func runSecurely(submissionID int, hostBinPath string, isPython bool, timeLimitM
 		fmt.Sprintf("--box-id=%d", boxID),
 		fmt.Sprintf("--meta=%s", metaFile),
 		fmt.Sprintf("--time=%.2f", float64(timeLimitMs)/1000.0),
-		"--mem=256000",
-		"--processes=10",
+		fmt.Sprintf("--mem=%d", sandbox.MemoryKB),
+		fmt.Sprintf("--processes=%d", sandbox.Processes),
 		"--run",
 	}
 
@@ -170,6 +346,9 @@ func runSecurely(submissionID int, hostBinPath string, isPython bool, timeLimitM
 	if outputPath != "" {
 		isolateArgs = append(isolateArgs, "--stdout=std.out")
 	}
+	if errorPath != "" {
+		isolateArgs = append(isolateArgs, "--stderr=std.err")
+	}
 
 	// Command
 	isolateArgs = append(isolateArgs, "--", "/bin/sh", "-c", runCommand)
@@ -183,19 +362,71 @@ func runSecurely(submissionID int, hostBinPath string, isPython bool, timeLimitM
 		// Copy back to host
 		copyFile(boxOut, outputPath)
 	}
+	if errorPath != "" {
+		copyFile(filepath.Join(boxPath, "box", "std.err"), errorPath)
+	}
 
 	return parseMetaFile(metaFile)
 }
 
-func parseMetaFile(path string) (string, error) {
+// Boxes initialized since startup; a box is only cleaned by its next run
+var usedBoxes sync.Map
+
+// CleanupSandboxes removes the isolate boxes and temporary files left by
+// judging and custom runs (shutdown, after StopWorker)
+func CleanupSandboxes() {
+	n := 0
+	usedBoxes.Range(func(key, _ interface{}) bool {
+		boxID := key.(int)
+		exec.Command("isolate", "--cleanup", fmt.Sprintf("--box-id=%d", boxID)).Run()
+		os.Remove(fmt.Sprintf("/tmp/isolate_meta_%d.txt", boxID))
+		usedBoxes.Delete(boxID)
+		n++
+		return true
+	})
+	// Outputs left behind by a crash
+	leftovers, _ := filepath.Glob("/tmp/sub_*_test_*")
+	for _, f := range leftovers {
+		os.Remove(f)
+	}
+	log.Printf("WORKER: Cleaned up %d sandbox boxes", n)
+}
+
+// sandboxError is the cause of an IE from runSecurely: a missing meta file
+// (isolate did not run) or isolate's own "XX" status
+func sandboxError(err error) error {
+	if err != nil {
+		return fmt.Errorf("sandbox: %v", err)
+	}
+	return errors.New("sandbox: isolate reported an internal error (status XX)")
+}
+
+func parseMetaFile(path string) (RunResult, error) {
 	data, err := os.ReadFile(path)
-	if err != nil { return "IE", err }
+	if err != nil { return RunResult{Verdict: "IE"}, err }
 	content := string(data)
 
-	if strings.Contains(content, "status:TO") { return "TLE", nil }
-	if strings.Contains(content, "status:RE") || strings.Contains(content, "status:SG") { return "RTE", nil }
-	if strings.Contains(content, "status:XX") { return "IE", nil }
-	return "AC", nil
+	res := RunResult{Verdict: "AC"}
+	for _, line := range strings.Split(content, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok { continue }
+		switch key {
+		case "time":
+			if secs, err := strconv.ParseFloat(value, 64); err == nil {
+				res.TimeMs = int(secs * 1000)
+			}
+		case "max-rss", "cg-mem":
+			// Prefer whichever is larger; cg-mem is only present with cgroups
+			if kb, err := strconv.Atoi(value); err == nil && kb > res.MemoryKB {
+				res.MemoryKB = kb
+			}
+		}
+	}
+
+	if strings.Contains(content, "status:TO") { res.Verdict = "TLE"; return res, nil }
+	if strings.Contains(content, "status:RE") || strings.Contains(content, "status:SG") { res.Verdict = "RTE"; return res, nil }
+	if strings.Contains(content, "status:XX") { res.Verdict = "IE"; return res, nil }
+	return res, nil
 }
 
 func copyFile(src, dst string) error {
@@ -0,0 +1,357 @@
+// Package export renders contest data into formats consumed by external tools.
+package export
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ifuaslaerl/Judge/internal/data"
+	"github.com/ifuaslaerl/Judge/internal/engine"
+)
+
+// --- CLICS Contest API event feed (2023-06) ---
+// Produces the NDJSON event feed read by the ICPC Resolver and broadcast tools:
+// one {"type": ..., "id": ..., "data": ..., "token": ...} object per line.
+
+// FeedOptions describes the contest window, which is not stored in the DB
+type FeedOptions struct {
+	ContestID string
+	Name      string
+	Start     time.Time     // Zero: use the first submission time
+	Duration  time.Duration // Contest length
+	Freeze    time.Duration // Scoreboard freeze before the end (0 = none)
+	Penalty   int           // Minutes per rejected attempt
+}
+
+// DefaultFeedOptions: 5h contest, 1h freeze, penalty from the active scoring rules
+func DefaultFeedOptions() FeedOptions {
+	return FeedOptions{
+		ContestID: "judge",
+		Name:      "Judge Contest",
+		Duration:  5 * time.Hour,
+		Freeze:    time.Hour,
+		Penalty:   engine.ActiveScoringRules().PenaltyMinutes,
+	}
+}
+
+type feedEvent struct {
+	Type  string      `json:"type"`
+	ID    *string     `json:"id"`
+	Data  interface{} `json:"data"`
+	Token string      `json:"token"`
+}
+
+// judgementTypes lists every verdict the worker can produce, mapped to CLICS ids.
+// Whether each one is penalized follows the active scoring rules.
+func judgementTypes() []map[string]interface{} {
+	r := engine.ActiveScoringRules()
+	return []map[string]interface{}{
+		{"id": "AC", "name": "correct", "penalty": false, "solved": true},
+		{"id": "WA", "name": "wrong answer", "penalty": r.CountsAsAttempt("WA"), "solved": false},
+		{"id": "TLE", "name": "time limit exceeded", "penalty": r.CountsAsAttempt("TLE"), "solved": false},
+		{"id": "RTE", "name": "run-time error", "penalty": r.CountsAsAttempt("RTE"), "solved": false},
+		{"id": "CE", "name": "compiler error", "penalty": r.CountsAsAttempt("CE"), "solved": false},
+		{"id": "JE", "name": "judging error", "penalty": r.CountsAsAttempt("IE"), "solved": false},
+	}
+}
+
+// judgementType maps a stored status ("WA on test 3", "IE", ...) to a CLICS id
+func judgementType(status string) string {
+	fields := strings.Fields(status)
+	if len(fields) == 0 {
+		return "JE"
+	}
+	switch fields[0] {
+	case "AC", "WA", "TLE", "RTE", "CE":
+		return fields[0]
+	default:
+		return "JE"
+	}
+}
+
+func languageID(filePath string) string {
+	if filepath.Ext(filePath) == ".py" {
+		return "python3"
+	}
+	return "cpp"
+}
+
+// clicsTime formats an absolute timestamp: 2024-01-01T10:00:00.000+00:00
+func clicsTime(t time.Time) string {
+	return t.Format("2006-01-02T15:04:05.000Z07:00")
+}
+
+// clicsRelTime formats a duration as (-)h:mm:ss.uuu
+func clicsRelTime(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%s%d:%02d:%02d.%03d", sign, ms/3600000, (ms/60000)%60, (ms/1000)%60, ms%1000)
+}
+
+type feedWriter struct {
+	enc   *json.Encoder
+	token int
+}
+
+func (f *feedWriter) emit(typ string, id string, v interface{}) error {
+	f.token++
+	e := feedEvent{Type: typ, Data: v, Token: strconv.Itoa(f.token)}
+	if id != "" {
+		e.ID = &id
+	}
+	return f.enc.Encode(e)
+}
+
+type feedSubmission struct {
+	id        int
+	userID    int
+	problemID int
+	status    string
+	filePath  string
+	created   time.Time
+	judged    sql.NullTime
+}
+
+type feedRun struct {
+	test    int
+	verdict string
+	timeMs  int
+}
+
+// WriteEventFeed writes the full event feed built from the current database
+func WriteEventFeed(w io.Writer, opts FeedOptions) error {
+	if opts.ContestID == "" {
+		opts.ContestID = "judge"
+	}
+	if opts.Name == "" {
+		opts.Name = "Judge Contest"
+	}
+
+	// 1. Load submissions first: they determine the default start time
+	sRows, err := data.DB.Query(`SELECT id, user_id, problem_id, status, file_path, created_at, judged_at FROM submissions ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	var subs []feedSubmission
+	for sRows.Next() {
+		var s feedSubmission
+		if err := sRows.Scan(&s.id, &s.userID, &s.problemID, &s.status, &s.filePath, &s.created, &s.judged); err != nil {
+			sRows.Close()
+			return err
+		}
+		subs = append(subs, s)
+	}
+	sRows.Close()
+
+	if opts.Start.IsZero() {
+		opts.Start = time.Now().UTC().Truncate(time.Minute)
+		if len(subs) > 0 {
+			opts.Start = subs[0].created.UTC().Truncate(time.Minute)
+		}
+	}
+	if opts.Freeze > opts.Duration {
+		opts.Freeze = opts.Duration
+	}
+	end := opts.Start.Add(opts.Duration)
+
+	bw := bufio.NewWriter(w)
+	f := &feedWriter{enc: json.NewEncoder(bw)}
+
+	// 2. Contest, judgement types, languages
+	contest := map[string]interface{}{
+		"id":           opts.ContestID,
+		"name":         opts.Name,
+		"formal_name":  opts.Name,
+		"start_time":   clicsTime(opts.Start),
+		"duration":     clicsRelTime(opts.Duration),
+		"penalty_time": clicsRelTime(time.Duration(opts.Penalty) * time.Minute),
+	}
+	if opts.Freeze > 0 {
+		contest["scoreboard_freeze_duration"] = clicsRelTime(opts.Freeze)
+	}
+	if err := f.emit("contest", "", contest); err != nil {
+		return err
+	}
+	for _, jt := range judgementTypes() {
+		if err := f.emit("judgement-types", jt["id"].(string), jt); err != nil {
+			return err
+		}
+	}
+	languages := []map[string]interface{}{
+		{"id": "cpp", "name": "C++", "entry_point_required": false, "extensions": []string{"cpp"}},
+		{"id": "python3", "name": "Python 3", "entry_point_required": false, "extensions": []string{"py"}},
+	}
+	for _, l := range languages {
+		if err := f.emit("languages", l["id"].(string), l); err != nil {
+			return err
+		}
+	}
+
+	// 3. Problems
+	pRows, err := data.DB.Query(`SELECT id, letter_code, time_limit, name FROM problems ORDER BY letter_code`)
+	if err != nil {
+		return err
+	}
+	ordinal := 0
+	for pRows.Next() {
+		var id, limit int
+		var letter, name string
+		if err := pRows.Scan(&id, &letter, &limit, &name); err != nil {
+			pRows.Close()
+			return err
+		}
+		if name == "" {
+			name = "Problem " + letter
+		}
+		err := f.emit("problems", strconv.Itoa(id), map[string]interface{}{
+			"id":         strconv.Itoa(id),
+			"label":      letter,
+			"name":       name,
+			"ordinal":    ordinal,
+			"time_limit": float64(limit) / 1000,
+		})
+		if err != nil {
+			pRows.Close()
+			return err
+		}
+		ordinal++
+	}
+	pRows.Close()
+
+	// 4. Teams (admins are exported hidden so they never appear in the reveal)
+	uRows, err := data.DB.Query(`SELECT id, username, display_name, is_admin FROM users ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	for uRows.Next() {
+		var id int
+		var username, display string
+		var isAdmin bool
+		if err := uRows.Scan(&id, &username, &display, &isAdmin); err != nil {
+			uRows.Close()
+			return err
+		}
+		if display == "" {
+			display = username
+		}
+		err := f.emit("teams", strconv.Itoa(id), map[string]interface{}{
+			"id":        strconv.Itoa(id),
+			"label":     strconv.Itoa(id),
+			"name":      display,
+			"group_ids": []string{},
+			"hidden":    isAdmin,
+		})
+		if err != nil {
+			uRows.Close()
+			return err
+		}
+	}
+	uRows.Close()
+
+	// 5. Per-test runs
+	runs := make(map[int][]feedRun)
+	rRows, err := data.DB.Query(`SELECT submission_id, test_number, verdict, time_ms FROM submission_tests ORDER BY submission_id, test_number`)
+	if err != nil {
+		return err
+	}
+	for rRows.Next() {
+		var subID int
+		var r feedRun
+		if err := rRows.Scan(&subID, &r.test, &r.verdict, &r.timeMs); err != nil {
+			rRows.Close()
+			return err
+		}
+		runs[subID] = append(runs[subID], r)
+	}
+	rRows.Close()
+
+	// 6. Submissions, judgements and runs
+	for _, s := range subs {
+		sid := strconv.Itoa(s.id)
+		err := f.emit("submissions", sid, map[string]interface{}{
+			"id":           sid,
+			"language_id":  languageID(s.filePath),
+			"problem_id":   strconv.Itoa(s.problemID),
+			"team_id":      strconv.Itoa(s.userID),
+			"time":         clicsTime(s.created),
+			"contest_time": clicsRelTime(s.created.Sub(opts.Start)),
+			"files":        []interface{}{},
+		})
+		if err != nil {
+			return err
+		}
+
+		if s.status == "PENDING" {
+			continue
+		}
+
+		judgedAt := s.created
+		if s.judged.Valid {
+			judgedAt = s.judged.Time
+		}
+
+		maxRun := 0
+		for _, r := range runs[s.id] {
+			if r.timeMs > maxRun {
+				maxRun = r.timeMs
+			}
+		}
+
+		err = f.emit("judgements", sid, map[string]interface{}{
+			"id":                 sid,
+			"submission_id":      sid,
+			"judgement_type_id":  judgementType(s.status),
+			"start_time":         clicsTime(s.created),
+			"start_contest_time": clicsRelTime(s.created.Sub(opts.Start)),
+			"end_time":           clicsTime(judgedAt),
+			"end_contest_time":   clicsRelTime(judgedAt.Sub(opts.Start)),
+			"max_run_time":       float64(maxRun) / 1000,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, r := range runs[s.id] {
+			rid := fmt.Sprintf("%d-%d", s.id, r.test)
+			err := f.emit("runs", rid, map[string]interface{}{
+				"id":                rid,
+				"judgement_id":      sid,
+				"ordinal":           r.test,
+				"judgement_type_id": judgementType(r.verdict),
+				"time":              clicsTime(judgedAt),
+				"contest_time":      clicsRelTime(judgedAt.Sub(opts.Start)),
+				"run_time":          float64(r.timeMs) / 1000,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// 7. Contest state: finalized once the contest window has passed
+	state := map[string]interface{}{"started": clicsTime(opts.Start)}
+	if freezeAt := end.Add(-opts.Freeze); opts.Freeze > 0 && time.Now().After(freezeAt) {
+		state["frozen"] = clicsTime(freezeAt)
+	}
+	if time.Now().After(end) {
+		state["ended"] = clicsTime(end)
+		state["finalized"] = clicsTime(end)
+		state["end_of_updates"] = clicsTime(end)
+	}
+	if err := f.emit("state", "", state); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,89 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"strconv"
+
+	"github.com/ifuaslaerl/Judge/internal/engine"
+)
+
+// Standings archive formats
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+	FormatHTML = "html"
+)
+
+// ContentType returns the MIME type used when serving a standings export
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatJSON:
+		return "application/json"
+	default:
+		return "text/html; charset=utf-8"
+	}
+}
+
+// WriteStandings renders a scoreboard in the requested format
+func WriteStandings(w io.Writer, board *engine.Scoreboard, format string) error {
+	switch format {
+	case FormatCSV:
+		return writeStandingsCSV(w, board)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(board)
+	case FormatHTML:
+		return writeStandingsHTML(w, board)
+	default:
+		return fmt.Errorf("unknown format %q (want csv, json or html)", format)
+	}
+}
+
+// writeStandingsCSV emits one row per user with solved/attempts/time per problem
+func writeStandingsCSV(w io.Writer, board *engine.Scoreboard) error {
+	cw := csv.NewWriter(w)
+
+	header := []string{"Rank", "User", "Solved", "Penalty"}
+	for _, p := range board.Problems {
+		header = append(header, p.Letter+" Solved", p.Letter+" Attempts", p.Letter+" Time", p.Letter+" First Solve")
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, row := range board.Rows {
+		record := []string{
+			strconv.Itoa(row.Rank),
+			row.DisplayName,
+			strconv.Itoa(row.Solved),
+			strconv.Itoa(row.Penalty),
+		}
+		for _, p := range board.Problems {
+			c := row.Cells[p.Letter]
+			record = append(record, strconv.FormatBool(c.Solved), strconv.Itoa(c.Attempts), c.Time, strconv.FormatBool(c.FirstSolve))
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeStandingsHTML renders a self-contained page (inline CSS, no links or scripts)
+func writeStandingsHTML(w io.Writer, board *engine.Scoreboard) error {
+	t, err := template.ParseFiles(filepath.Join("templates", "standings_export.html"))
+	if err != nil {
+		return err
+	}
+	return t.Execute(w, board)
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ifuaslaerl/Judge/internal/audit"
+	"github.com/ifuaslaerl/Judge/internal/data"
+	"github.com/ifuaslaerl/Judge/internal/middleware"
+)
+
+type accountPage struct {
+	Username    string
+	DisplayName string
+	Tokens      []data.APIToken
+	NewToken    string // Shown exactly once, right after generation
+}
+
+// GET /account
+func (h *Handler) HandleAccount(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int)
+	h.renderAccount(w, userID, "")
+}
+
+// POST /account/tokens         (generate)
+// POST /account/tokens/revoke  (form field: id)
+func (h *Handler) HandleAccountTokens(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID := r.Context().Value(middleware.UserIDKey).(int)
+
+	if strings.TrimSuffix(r.URL.Path, "/") == "/account/tokens/revoke" {
+		id, err := strconv.Atoi(r.FormValue("id"))
+		if err != nil {
+			http.Error(w, "Invalid token ID", http.StatusBadRequest)
+			return
+		}
+		if err := h.auth.RevokeAPIToken(userID, id); err != nil {
+			log.Printf("Token Revoke Error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		h.audit.LogUser(userID, audit.TokenRevoke, fmt.Sprintf("token %d", id), "")
+		http.Redirect(w, r, "/account", http.StatusSeeOther)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	token, err := h.auth.CreateAPIToken(userID, name)
+	if err != nil {
+		log.Printf("Token Create Error: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	h.audit.LogUser(userID, audit.TokenCreate, fmt.Sprintf("token %q", name), "from "+clientIP(r))
+
+	// Render directly (no redirect) so the plaintext token never hits a URL or log
+	h.renderAccount(w, userID, token)
+}
+
+func (h *Handler) renderAccount(w http.ResponseWriter, userID int, newToken string) {
+	page := accountPage{NewToken: newToken}
+	u, err := h.stores.Users.User(userID)
+	if err != nil {
+		http.Error(w, "DB Error", http.StatusInternalServerError)
+		return
+	}
+	page.Username, page.DisplayName = u.Username, u.DisplayName
+
+	page.Tokens, err = h.auth.ListAPITokens(userID)
+	if err != nil {
+		log.Printf("Token List Error: %v", err)
+		http.Error(w, "DB Error", http.StatusInternalServerError)
+		return
+	}
+
+	renderTemplate(w, "account.html", page)
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ifuaslaerl/Judge/internal/audit"
+	"github.com/ifuaslaerl/Judge/internal/data"
+	"github.com/ifuaslaerl/Judge/internal/engine"
+	"github.com/ifuaslaerl/Judge/internal/middleware"
+)
+
+// --- Admin (Judge) Pages ---
+// All routes here are wrapped with middleware.AdminMiddleware.
+
+// GET /admin/balloons
+// Lists each team's first AC per problem; undelivered balloons first.
+func (h *Handler) HandleBalloons(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		h.processBalloonDelivery(w, r)
+		return
+	}
+
+	balloons, err := h.stores.Balloons.Balloons()
+	if err != nil {
+		log.Printf("Balloon Query Error: %v", err)
+		http.Error(w, "DB Error", http.StatusInternalServerError)
+		return
+	}
+
+	renderTemplate(w, "balloons.html", balloons)
+}
+
+// POST /admin/balloons (form fields: submission_id, delivered)
+func (h *Handler) processBalloonDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.FormValue("submission_id"))
+	if err != nil {
+		http.Error(w, "Invalid submission ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.stores.Balloons.SetDelivered(id, r.FormValue("delivered") == "1"); err != nil {
+		log.Printf("Balloon Update Error: %v", err)
+		http.Error(w, "DB Error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/balloons", http.StatusSeeOther)
+}
+
+// GET /admin/audit?actor=NAME&action=ACTION&since=24h|2006-01-02&limit=N
+// The filters match the audit-log command's.
+func (h *Handler) HandleAuditLog(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := data.AuditFilter{Actor: q.Get("actor"), Action: q.Get("action")}
+	f.Limit, _ = strconv.Atoi(q.Get("limit"))
+	if f.Limit <= 0 || f.Limit > 1000 {
+		f.Limit = 200
+	}
+	since := strings.TrimSpace(q.Get("since"))
+	if since != "" {
+		var err error
+		if f.Since, err = audit.ParseSince(since); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries, err := h.stores.Audit.AuditEntries(f)
+	if err != nil {
+		log.Printf("Audit Query Error: %v", err)
+		http.Error(w, "DB Error", http.StatusInternalServerError)
+		return
+	}
+
+	renderTemplate(w, "audit.html", struct {
+		Entries []data.AuditEntry
+		Filter  data.AuditFilter
+		Since   string
+		Actions []string
+	}{entries, f, since, audit.Actions})
+}
+
+// Verdicts a judge can set by hand, and the ones the browser filters on
+var (
+	overrideVerdicts = []string{"AC", "WA", "TLE", "RTE", "CE", "IE"}
+	filterVerdicts   = append([]string{"PENDING"}, overrideVerdicts...)
+)
+
+const (
+	adminPageSize = 50
+	// datetime-local inputs; times are shown and compared in UTC like the rest of the pages
+	formTime = "2006-01-02T15:04"
+)
+
+// GET /admin/submissions?user=NAME&problem=ID&verdict=WA&from=TIME&to=TIME&page=N
+// Every user's submissions, newest first; details and the override form are on
+// /submission/[id].
+func (h *Handler) HandleAdminSubmissions(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		h.processVerdictOverride(w, r)
+		return
+	}
+
+	q := r.URL.Query()
+	var filter data.SubmissionFilter
+	var problems []data.Problem
+	var errs []string
+	if name := strings.TrimSpace(q.Get("user")); name != "" {
+		if u, err := h.stores.Users.UserByUsername(name); err == nil {
+			filter.UserID = u.ID
+		} else {
+			errs = append(errs, fmt.Sprintf("No user %q", name))
+		}
+	}
+	filter.ProblemID, _ = strconv.Atoi(q.Get("problem"))
+	if v := q.Get("verdict"); slices.Contains(filterVerdicts, v) {
+		filter.Verdict = v
+	}
+	for _, t := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		if v := q.Get(t.name); v != "" {
+			parsed, err := time.Parse(formTime, v)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("Invalid %s time %q", t.name, v))
+				continue
+			}
+			*t.dst = parsed
+		}
+	}
+
+	page, _ := strconv.Atoi(q.Get("page"))
+	page = max(page, 1)
+	var total int
+	var list []data.Submission
+	var err error
+	if len(errs) == 0 {
+		total, err = h.stores.Submissions.CountSubmissions(filter)
+		if err == nil {
+			filter.Limit, filter.Offset = adminPageSize, (page-1)*adminPageSize
+			list, err = h.stores.Submissions.ListSubmissions(filter)
+		}
+	}
+	if err == nil {
+		problems, err = h.stores.Problems.Problems()
+	}
+	if err != nil {
+		log.Printf("Admin Submissions DB Error: %v", err)
+		http.Error(w, "DB Error", http.StatusInternalServerError)
+		return
+	}
+
+	type row struct {
+		ID       int
+		User     string
+		Username string
+		Problem  string
+		Status   string
+		Time     string
+		Judged   string
+	}
+	users := make(map[int]*data.User)
+	var rows []row
+	for _, s := range list {
+		u, ok := users[s.UserID]
+		if !ok {
+			u, _ = h.stores.Users.User(s.UserID) // nil if deleted meanwhile
+			users[s.UserID] = u
+		}
+		rw := row{ID: s.ID, Problem: s.Problem, Status: s.Status, Time: s.CreatedAt.Format("2006-01-02 15:04:05")}
+		if u != nil {
+			rw.User, rw.Username = u.DisplayName, u.Username
+		}
+		if !s.JudgedAt.IsZero() {
+			rw.Judged = s.JudgedAt.Format("15:04:05")
+		}
+		rows = append(rows, rw)
+	}
+
+	// Paging links keep the filters
+	link := func(p int) string {
+		v := url.Values{}
+		for _, k := range []string{"user", "problem", "verdict", "from", "to"} {
+			if q.Get(k) != "" {
+				v.Set(k, q.Get(k))
+			}
+		}
+		v.Set("page", strconv.Itoa(p))
+		return "/admin/submissions?" + v.Encode()
+	}
+	pages := max((total+adminPageSize-1)/adminPageSize, 1)
+	var prev, next string
+	if page > 1 {
+		prev = link(page - 1)
+	}
+	if page < pages {
+		next = link(page + 1)
+	}
+
+	renderTemplate(w, "admin_submissions.html", struct {
+		Submissions []row
+		Problems    []data.Problem
+		Verdicts    []string
+		Errors      []string
+		User        string
+		ProblemID   int
+		Verdict     string
+		From, To    string
+		Page, Pages int
+		Total       int
+		Prev, Next  string
+	}{rows, problems, filterVerdicts, errs, q.Get("user"), filter.ProblemID, filter.Verdict,
+		q.Get("from"), q.Get("to"), page, pages, total, prev, next})
+}
+
+// POST /admin/submissions/[id]/verdict (form fields: verdict, reason)
+// Replaces the verdict of a judged submission, keeping its test results and
+// compiler output; the change is audit-logged and the scoreboard rebuilt.
+func (h *Handler) processVerdictOverride(w http.ResponseWriter, r *http.Request) {
+	adminID := r.Context().Value(middleware.UserIDKey).(int)
+
+	rest, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/admin/submissions/"), "/verdict")
+	id, err := strconv.Atoi(rest)
+	if !ok || err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	verdict := r.FormValue("verdict")
+	if !slices.Contains(overrideVerdicts, verdict) {
+		http.Error(w, "Invalid verdict", http.StatusBadRequest)
+		return
+	}
+	reason := strings.TrimSpace(r.FormValue("reason"))
+
+	sub, err := h.stores.Submissions.Submission(id)
+	if err == data.ErrNotFound {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Printf("Verdict Override DB Error: %v", err)
+		http.Error(w, "DB Error", http.StatusInternalServerError)
+		return
+	}
+	if sub.Status == "PENDING" {
+		http.Error(w, "The submission is still being judged", http.StatusConflict)
+		return
+	}
+
+	if sub.Status != verdict {
+		if err := h.stores.Submissions.SetVerdict(id, verdict, sub.CompileOutput); err != nil {
+			log.Printf("Verdict Override DB Error: %v", err)
+			http.Error(w, "DB Error", http.StatusInternalServerError)
+			return
+		}
+		details := fmt.Sprintf("%s -> %s", sub.Status, verdict)
+		if reason != "" {
+			details += ": " + reason
+		}
+		h.audit.LogUser(adminID, audit.VerdictOverride, fmt.Sprintf("submission %d", id), details)
+		log.Printf("ADMIN: Submission %d verdict overridden (%s)", id, details)
+
+		h.judge.InvalidateScoreboard()
+		engine.Publish(engine.Event{Type: engine.EventVerdict, UserID: sub.UserID, SubmissionID: id, Status: verdict})
+		engine.Publish(engine.Event{Type: engine.EventScoreboard})
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/submission/%d", id), http.StatusSeeOther)
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ifuaslaerl/Judge/internal/audit"
+	"github.com/ifuaslaerl/Judge/internal/data"
+)
+
+func TestVerdictOverride(t *testing.T) {
+	h, stores, judge := newTestHandler(t)
+
+	judged, _ := stores.Submissions.CreateSubmission(1, 1)
+	stores.Submissions.SetVerdict(judged, "WA on test 2", "warning: x")
+	pending, _ := stores.Submissions.CreateSubmission(2, 1)
+	if board, err := judge.GetScoreboard(); err != nil || board.Rows[0].Solved != 0 {
+		t.Fatalf("standings before override = %+v, %v", board, err)
+	}
+
+	override := func(id, verdict string) int {
+		form := url.Values{"verdict": {verdict}, "reason": {"checker bug"}}
+		r := httptest.NewRequest(http.MethodPost, "/admin/submissions/"+id+"/verdict", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return serveAs(t, h.HandleAdminSubmissions, 2, r, nil)
+	}
+	for _, tc := range []struct {
+		id, verdict string
+		code        int
+	}{
+		{"1", "OK", http.StatusBadRequest},
+		{"1", "PENDING", http.StatusBadRequest},
+		{"9", "AC", http.StatusNotFound},
+		{"x", "AC", http.StatusNotFound},
+		{"2", "AC", http.StatusConflict}, // Still being judged
+		{"1", "AC", http.StatusSeeOther},
+	} {
+		if code := override(tc.id, tc.verdict); code != tc.code {
+			t.Errorf("override %s to %s: status %d, want %d", tc.id, tc.verdict, code, tc.code)
+		}
+	}
+
+	s, _ := stores.Submissions.Submission(judged)
+	if s.Status != "AC" || s.CompileOutput != "warning: x" {
+		t.Errorf("after override: %+v", s)
+	}
+	if s, _ := stores.Submissions.Submission(pending); s.Status != "PENDING" {
+		t.Errorf("pending submission changed to %q", s.Status)
+	}
+
+	entries, err := stores.Audit.AuditEntries(data.AuditFilter{Action: audit.VerdictOverride})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Actor != "bob" || entries[0].Target != "submission 1" || entries[0].Details != "WA on test 2 -> AC: checker bug" {
+		t.Errorf("audit entries = %+v", entries)
+	}
+
+	board, err := judge.GetScoreboard()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if board.Rows[0].DisplayName != "Alice" || board.Rows[0].Solved != 1 {
+		t.Errorf("standings after override = %+v", board.Rows)
+	}
+}
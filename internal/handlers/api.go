@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ifuaslaerl/Judge/internal/audit"
+	"github.com/ifuaslaerl/Judge/internal/data"
+	"github.com/ifuaslaerl/Judge/internal/middleware"
+)
+
+// --- JSON REST API (v1) ---
+// All routes live under /api/v1. Everything except /api/v1/login is wrapped
+// with middleware.APITokenMiddleware.
+
+type apiProblem struct {
+	ID        int    `json:"id"`
+	Letter    string `json:"letter"`
+	TimeLimit int    `json:"time_limit_ms"`
+	PDFURL    string `json:"pdf_url"`
+}
+
+type apiTestResult struct {
+	Test     int    `json:"test"`
+	Verdict  string `json:"verdict"`
+	TimeMs   int    `json:"time_ms"`
+	MemoryKB int    `json:"memory_kb"`
+	Sample   bool   `json:"sample"`
+	Output   string `json:"output,omitempty"` // Only for failed samples
+}
+
+type apiSubmission struct {
+	ID        int             `json:"id"`
+	ProblemID int             `json:"problem_id"`
+	Problem   string          `json:"problem"`
+	Status    string          `json:"status"`
+	Pending   bool            `json:"pending"`
+	CreatedAt time.Time       `json:"created_at"`
+	Compile   string          `json:"compile_output,omitempty"` // Compiler errors (CE only)
+	Tests     []apiTestResult `json:"tests,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("API Encode Error: %v", err)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// apiPathID extracts the numeric ID following prefix, e.g. /api/v1/problems/[id]
+func apiPathID(path, prefix string) (int, bool) {
+	rest := strings.Trim(strings.TrimPrefix(path, prefix), "/")
+	if rest == "" || strings.Contains(rest, "/") {
+		return 0, false
+	}
+	id, err := strconv.Atoi(rest)
+	return id, err == nil
+}
+
+// POST /api/v1/login (form or JSON: username, password, name)
+// Exchanges credentials for a new API token named name (default "api-login"),
+// replacing the user's earlier token of that name. This route is not
+// token-protected; failed attempts count toward the login form's limit.
+func (h *Handler) HandleAPILogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Name     string `json:"name"`
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+	} else {
+		req.Username = r.FormValue("username")
+		req.Password = r.FormValue("password")
+		req.Name = r.FormValue("name")
+	}
+
+	if loginBlocked(clientIP(r)) {
+		writeAPIError(w, http.StatusTooManyRequests, "too many failed logins, try again in a few minutes")
+		return
+	}
+	userID, err := h.checkCredentials(req.Username, req.Password)
+	if err == errInvalidCredentials {
+		recordLoginFailure(clientIP(r))
+		h.audit.Log(req.Username, audit.LoginFailed, "api", "from "+clientIP(r))
+		writeAPIError(w, http.StatusUnauthorized, "invalid credentials")
+		return
+	} else if err != nil {
+		log.Printf("API Login Error: %v", err)
+		writeAPIError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	if req.Name == "" {
+		req.Name = "api-login"
+	}
+	if len(req.Name) > 64 {
+		req.Name = req.Name[:64]
+	}
+	// One token per client name: logging in again replaces it instead of piling up
+	token, err := h.auth.ReplaceAPIToken(userID, req.Name)
+	if err != nil {
+		log.Printf("Token Create Error: %v", err)
+		writeAPIError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	h.audit.LogUser(userID, audit.Login, "api", fmt.Sprintf("token %q from %s", req.Name, clientIP(r)))
+	writeJSON(w, http.StatusCreated, map[string]string{"token": token})
+}
+
+// GET /api/v1/problems
+// GET /api/v1/problems/[id]
+func (h *Handler) HandleAPIProblems(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/problems"), "/") != "" {
+		id, ok := apiPathID(r.URL.Path, "/api/v1/problems")
+		if !ok {
+			writeAPIError(w, http.StatusNotFound, "not found")
+			return
+		}
+
+		p, err := h.stores.Problems.Problem(id)
+		if err == data.ErrNotFound {
+			writeAPIError(w, http.StatusNotFound, "problem not found")
+			return
+		} else if err != nil {
+			log.Printf("API DB Error: %v", err)
+			writeAPIError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		writeJSON(w, http.StatusOK, newAPIProblem(*p))
+		return
+	}
+
+	all, err := h.stores.Problems.Problems()
+	if err != nil {
+		log.Printf("API DB Error: %v", err)
+		writeAPIError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	problems := []apiProblem{}
+	for _, p := range all {
+		problems = append(problems, newAPIProblem(p))
+	}
+	writeJSON(w, http.StatusOK, problems)
+}
+
+func newAPIProblem(p data.Problem) apiProblem {
+	return apiProblem{
+		ID:        p.ID,
+		Letter:    p.Letter,
+		TimeLimit: p.TimeLimit,
+		PDFURL:    "/problems/" + strconv.Itoa(p.ID) + "/pdf",
+	}
+}
+
+// GET  /api/v1/submissions       (own submissions, newest first; ?problem_id= filters)
+// POST /api/v1/submissions       (multipart: problem_id + code file)
+// GET  /api/v1/submissions/[id]  (status with per-test details)
+func (h *Handler) HandleAPISubmissions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int)
+
+	if strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/submissions"), "/") != "" {
+		if r.Method != http.MethodGet {
+			writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		id, ok := apiPathID(r.URL.Path, "/api/v1/submissions")
+		if !ok {
+			writeAPIError(w, http.StatusNotFound, "not found")
+			return
+		}
+		h.apiGetSubmission(w, userID, id)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.apiListSubmissions(w, r, userID)
+	case http.MethodPost:
+		h.apiCreateSubmission(w, r, userID)
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (h *Handler) apiListSubmissions(w http.ResponseWriter, r *http.Request, userID int) {
+	filter := data.SubmissionFilter{UserID: userID, Limit: 100}
+	if pid := r.URL.Query().Get("problem_id"); pid != "" {
+		id, err := strconv.Atoi(pid)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid problem_id")
+			return
+		}
+		filter.ProblemID = id
+	}
+
+	list, err := h.stores.Submissions.ListSubmissions(filter)
+	if err != nil {
+		log.Printf("API DB Error: %v", err)
+		writeAPIError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	subs := []apiSubmission{}
+	for _, s := range list {
+		subs = append(subs, newAPISubmission(s))
+	}
+	writeJSON(w, http.StatusOK, subs)
+}
+
+func newAPISubmission(s data.Submission) apiSubmission {
+	return apiSubmission{
+		ID:        s.ID,
+		ProblemID: s.ProblemID,
+		Problem:   s.Problem,
+		Status:    s.Status,
+		Pending:   s.Status == "PENDING",
+		CreatedAt: s.CreatedAt,
+	}
+}
+
+func (h *Handler) apiGetSubmission(w http.ResponseWriter, userID, id int) {
+	sub, err := h.stores.Submissions.Submission(id)
+	if err == data.ErrNotFound || (err == nil && sub.UserID != userID) {
+		// Other users' submissions are indistinguishable from missing ones
+		writeAPIError(w, http.StatusNotFound, "submission not found")
+		return
+	} else if err != nil {
+		log.Printf("API DB Error: %v", err)
+		writeAPIError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	s := newAPISubmission(*sub)
+	s.Compile = sub.CompileOutput
+
+	tests, err := h.stores.Submissions.Tests(id)
+	if err != nil {
+		log.Printf("API DB Error: %v", err)
+		writeAPIError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	for _, t := range tests {
+		s.Tests = append(s.Tests, apiTestResult{
+			Test:     t.Number,
+			Verdict:  t.Verdict,
+			TimeMs:   t.TimeMs,
+			MemoryKB: t.MemoryKB,
+			Sample:   t.Sample,
+			Output:   t.Output,
+		})
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+func (h *Handler) apiCreateSubmission(w http.ResponseWriter, r *http.Request, userID int) {
+	r.Body = http.MaxBytesReader(w, r.Body, limits.MaxUploadBytes())
+	if err := r.ParseMultipartForm(limits.MaxUploadBytes()); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("expected multipart form (max %dKB)", limits.MaxUploadKB))
+		return
+	}
+
+	problemID, err := strconv.Atoi(r.FormValue("problem_id"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid problem_id")
+		return
+	}
+
+	file, header, err := r.FormFile("code")
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "missing 'code' file")
+		return
+	}
+	defer file.Close()
+
+	id, err := h.createSubmission(userID, problemID, header.Filename, file)
+	switch {
+	case err == nil:
+	case errors.Is(err, errUnknownProblem):
+		writeAPIError(w, http.StatusNotFound, "problem not found")
+		return
+	case errors.Is(err, errSubmissionCap):
+		writeAPIError(w, http.StatusForbidden, "submission limit reached")
+		return
+	case errors.Is(err, errBadExtension):
+		writeAPIError(w, http.StatusBadRequest, "only .cpp and .py files are allowed")
+		return
+	case errors.Is(err, errQueueFull):
+		writeAPIError(w, http.StatusServiceUnavailable, "system overloaded")
+		return
+	default:
+		writeAPIError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/submissions/"+strconv.Itoa(id))
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"id": id, "status": "PENDING"})
+}
+
+// GET /api/v1/standings
+func (h *Handler) HandleAPIStandings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	board, err := h.judge.GetScoreboard()
+	if err != nil {
+		log.Printf("Scoreboard Error: %v", err)
+		writeAPIError(w, http.StatusInternalServerError, "failed to calculate standings")
+		return
+	}
+	writeJSON(w, http.StatusOK, board)
+}
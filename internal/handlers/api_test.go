@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ifuaslaerl/Judge/internal/audit"
+	"github.com/ifuaslaerl/Judge/internal/auth"
+	"github.com/ifuaslaerl/Judge/internal/data"
+	"github.com/ifuaslaerl/Judge/internal/engine"
+	"github.com/ifuaslaerl/Judge/internal/middleware"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTestHandler serves from in-memory stores with alice (1), bob (2) and
+// problem A (1); uploads go to a temp storage/ directory
+func newTestHandler(t *testing.T) (*Handler, *data.Stores, *engine.Judge) {
+	t.Helper()
+	stores, m := data.NewMemoryStores()
+	m.AddUser(data.User{ID: 1, Username: "alice", DisplayName: "Alice"})
+	m.AddUser(data.User{ID: 2, Username: "bob", DisplayName: "Bob"})
+	m.AddProblem(data.Problem{ID: 1, Letter: "A", TimeLimit: 1000})
+
+	t.Chdir(t.TempDir())
+	if err := os.MkdirAll("storage/submissions", 0755); err != nil {
+		t.Fatal(err)
+	}
+	engine.InitQueue(10)
+	judge := engine.NewJudge(stores)
+	return New(stores, judge, auth.NewService(stores)), stores, judge
+}
+
+// serveAs calls handler as userID (as APITokenMiddleware would) and decodes the JSON reply into v
+func serveAs(t *testing.T, handler http.HandlerFunc, userID int, r *http.Request, v interface{}) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler(w, r.WithContext(context.WithValue(r.Context(), middleware.UserIDKey, userID)))
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s %s: invalid JSON %q: %v", r.Method, r.URL, w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+func submitRequest(t *testing.T, problemID int, filename, source string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("problem_id", strconv.Itoa(problemID))
+	fw, err := mw.CreateFormFile("code", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte(source))
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/submissions", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func TestAPISubmissionsWithMemoryStores(t *testing.T) {
+	h, stores, judge := newTestHandler(t)
+
+	var created struct {
+		ID     int    `json:"id"`
+		Status string `json:"status"`
+	}
+	if code := serveAs(t, h.HandleAPISubmissions, 1, submitRequest(t, 1, "a.cpp", "int main() {}"), &created); code != http.StatusCreated {
+		t.Fatalf("POST submission: status %d", code)
+	}
+	if created.Status != "PENDING" {
+		t.Errorf("created status = %q", created.Status)
+	}
+	if code := serveAs(t, h.HandleAPISubmissions, 1, submitRequest(t, 1, "a.java", "class A {}"), nil); code != http.StatusBadRequest {
+		t.Errorf("POST .java: status %d, want 400", code)
+	}
+	if code := serveAs(t, h.HandleAPISubmissions, 1, submitRequest(t, 7, "a.cpp", ""), nil); code != http.StatusNotFound {
+		t.Errorf("POST unknown problem: status %d, want 404", code)
+	}
+
+	// Stored and queued
+	sub, err := stores.Submissions.Submission(created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src, err := os.ReadFile(sub.FilePath); err != nil || string(src) != "int main() {}" {
+		t.Errorf("stored source = %q, %v", src, err)
+	}
+	if got := <-engine.SubmissionQueue; got != created.ID {
+		t.Errorf("queued %d, want %d", got, created.ID)
+	}
+
+	// Listing is scoped to the owner
+	var list []apiSubmission
+	serveAs(t, h.HandleAPISubmissions, 1, httptest.NewRequest(http.MethodGet, "/api/v1/submissions", nil), &list)
+	if len(list) != 1 || list[0].ID != created.ID || !list[0].Pending || list[0].Problem != "A" {
+		t.Errorf("alice's submissions = %+v", list)
+	}
+	serveAs(t, h.HandleAPISubmissions, 2, httptest.NewRequest(http.MethodGet, "/api/v1/submissions", nil), &list)
+	if len(list) != 0 {
+		t.Errorf("bob sees %+v", list)
+	}
+	detail := httptest.NewRequest(http.MethodGet, "/api/v1/submissions/"+strconv.Itoa(created.ID), nil)
+	if code := serveAs(t, h.HandleAPISubmissions, 2, detail, nil); code != http.StatusNotFound {
+		t.Errorf("bob reading alice's submission: status %d, want 404", code)
+	}
+
+	// A verdict written by the worker reaches the standings
+	var board engine.Scoreboard
+	serveAs(t, h.HandleAPIStandings, 2, httptest.NewRequest(http.MethodGet, "/api/v1/standings", nil), &board)
+	if len(board.Rows) != 2 || board.Rows[0].DisplayName != "Alice" || !board.Rows[0].Cells["A"].IsPending {
+		t.Errorf("standings before the verdict = %+v", board.Rows)
+	}
+
+	stores.Submissions.SetVerdict(created.ID, "AC", "")
+	judge.PublishVerdict(1, created.ID, "AC")
+	serveAs(t, h.HandleAPIStandings, 2, httptest.NewRequest(http.MethodGet, "/api/v1/standings", nil), &board)
+	if len(board.Rows) != 2 || board.Rows[0].DisplayName != "Alice" || board.Rows[0].Solved != 1 || board.Rows[0].Rank != 1 {
+		t.Errorf("standings after AC = %+v", board.Rows)
+	}
+
+	var got apiSubmission
+	serveAs(t, h.HandleAPISubmissions, 1, detail, &got)
+	if got.Status != "AC" || got.Pending {
+		t.Errorf("alice's submission after the verdict = %+v", got)
+	}
+}
+
+func TestAPILogin(t *testing.T) {
+	stores, m := data.NewMemoryStores()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	m.AddUser(data.User{ID: 1, Username: "alice", PasswordHash: string(hash)})
+	h := New(stores, nil, auth.NewService(stores))
+	t.Cleanup(func() { loginFailures = make(map[string][]time.Time) })
+
+	login := func(ip, password, name string) int {
+		form := url.Values{"username": {"alice"}, "password": {password}, "name": {name}}
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/login", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		h.HandleAPILogin(w, r)
+		return w.Code
+	}
+
+	// Logging in again from the same client replaces its token
+	for _, name := range []string{"laptop", "laptop", "ci", ""} {
+		if code := login("10.0.0.1", "secret", name); code != http.StatusCreated {
+			t.Fatalf("login as %q: status %d", name, code)
+		}
+	}
+	tokens, _ := stores.Tokens.Tokens(1)
+	var names []string
+	for _, tok := range tokens {
+		names = append(names, tok.Name)
+	}
+	if strings.Join(names, ",") != "api-login,ci,laptop" {
+		t.Errorf("tokens after four logins = %v, want api-login, ci and laptop", names)
+	}
+
+	for i := 0; i < loginFailLimit; i++ {
+		if code := login("10.0.0.2", "wrong", "x"); code != http.StatusUnauthorized {
+			t.Fatalf("failed login %d: status %d", i+1, code)
+		}
+	}
+	if code := login("10.0.0.2", "secret", "x"); code != http.StatusTooManyRequests {
+		t.Errorf("login after %d failures: status %d, want 429", loginFailLimit, code)
+	}
+	if code := login("10.0.0.1", "secret", "laptop"); code != http.StatusCreated {
+		t.Errorf("login from another address: status %d", code)
+	}
+
+	failed, _ := stores.Audit.AuditEntries(data.AuditFilter{Action: audit.LoginFailed})
+	logins, _ := stores.Audit.AuditEntries(data.AuditFilter{Actor: "alice", Action: audit.Login})
+	if len(failed) != loginFailLimit || failed[0].ActorID != 0 || len(logins) != 5 || logins[0].ActorID != 1 {
+		t.Errorf("audited %d failed logins (%+v) and %d logins (%+v)", len(failed), failed, len(logins), logins)
+	}
+}
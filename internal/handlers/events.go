@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ifuaslaerl/Judge/internal/engine"
+	"github.com/ifuaslaerl/Judge/internal/middleware"
+)
+
+// Keep-alive interval; proxies (Cloudflare) drop idle streams after ~100s
+const sseHeartbeat = 25 * time.Second
+
+// Closed by CloseEventStreams; streams never end on their own, so the server
+// could not shut down while a browser has one open
+var (
+	streamsClosed = make(chan struct{})
+	closeStreams  sync.Once
+)
+
+// CloseEventStreams ends every /events stream (register with http.Server.RegisterOnShutdown)
+func CloseEventStreams() {
+	closeStreams.Do(func() { close(streamsClosed) })
+}
+
+// GET /events (Server-Sent Events)
+// Streams "verdict" events for the current user's submissions and
+// "scoreboard" events for everyone.
+func (h *Handler) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	events, cancel := engine.Subscribe()
+	defer cancel()
+
+	// Tell EventSource how long to wait before reconnecting
+	fmt.Fprint(w, "retry: 5000\n\n")
+	flusher.Flush()
+
+	ticker := time.NewTicker(sseHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-streamsClosed:
+			return // Shutting down; EventSource reconnects after the retry delay
+
+		case <-ticker.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+
+		case e := <-events:
+			if e.Type == engine.EventVerdict && e.UserID != userID {
+				continue
+			}
+			payload, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, payload)
+			flusher.Flush()
+		}
+	}
+}
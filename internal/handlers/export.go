@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ifuaslaerl/Judge/internal/export"
+)
+
+// GET /api/v1/admin/event-feed (admin only, NDJSON)
+// Optional query: start (RFC3339), duration, freeze (e.g. "5h", "1h"), penalty, name
+func (h *Handler) HandleEventFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	opts := export.DefaultFeedOptions()
+	q := r.URL.Query()
+
+	if v := q.Get("start"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid start (want RFC3339)")
+			return
+		}
+		opts.Start = t
+	}
+	for key, dst := range map[string]*time.Duration{"duration": &opts.Duration, "freeze": &opts.Freeze} {
+		if v := q.Get(key); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				writeAPIError(w, http.StatusBadRequest, "invalid "+key)
+				return
+			}
+			*dst = d
+		}
+	}
+	if v := q.Get("penalty"); v != "" {
+		p, err := strconv.Atoi(v)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid penalty")
+			return
+		}
+		opts.Penalty = p
+	}
+	if v := q.Get("name"); v != "" {
+		opts.Name = v
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="event-feed.ndjson"`)
+	if err := export.WriteEventFeed(w, opts); err != nil {
+		// Headers are already sent; the truncated feed is the best we can do
+		log.Printf("Event Feed Error: %v", err)
+	}
+}
+
+// GET /admin/standings/export?format=csv|json|html (admin only, download)
+func (h *Handler) HandleStandingsExport(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = export.FormatHTML
+	}
+	if format != export.FormatCSV && format != export.FormatJSON && format != export.FormatHTML {
+		http.Error(w, "Unknown format (want csv, json or html)", http.StatusBadRequest)
+		return
+	}
+
+	board, err := h.judge.GetScoreboard()
+	if err != nil {
+		log.Printf("Scoreboard Error: %v", err)
+		http.Error(w, "Failed to calculate standings", http.StatusInternalServerError)
+		return
+	}
+
+	// Render to memory first so a template error still yields a clean 500
+	var buf bytes.Buffer
+	if err := export.WriteStandings(&buf, board, format); err != nil {
+		log.Printf("Standings Export Error: %v", err)
+		http.Error(w, "Export failed", http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("standings-%s.%s", board.LastUpd.Format("20060102-1504"), format)
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Write(buf.Bytes())
+}
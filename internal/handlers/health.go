@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ifuaslaerl/Judge/internal/data"
+	"github.com/ifuaslaerl/Judge/internal/engine"
+)
+
+type healthPage struct {
+	Now         time.Time
+	QueueLength int
+	QueueCap    int
+	RunQueue    int
+	Worker      engine.WorkerStatus
+	JudgingFor  string // How long the current submission has been judged
+	Isolate     string // Version, or why it cannot run
+	IsolateOK   bool
+	StorageSize string
+	DiskFree    string
+	DBSize      string
+	WALSize     string
+	Pending     int
+	OldestID    int // Oldest PENDING submission, 0 = none
+	OldestAge   string
+	Errors      []engine.InternalError
+	Warnings    []string // Problems worth a look, shown at the top
+}
+
+// GET /admin/health
+func (h *Handler) HandleHealth(w http.ResponseWriter, r *http.Request) {
+	p := healthPage{
+		Now:         time.Now(),
+		QueueLength: len(engine.SubmissionQueue),
+		QueueCap:    cap(engine.SubmissionQueue),
+		RunQueue:    len(engine.RunQueue),
+		Worker:      h.judge.Worker(),
+		Errors:      h.judge.RecentInternalErrors(),
+	}
+
+	if p.Worker.Current != 0 {
+		p.JudgingFor = time.Since(p.Worker.Since).Round(time.Second).String()
+	}
+	if !p.Worker.Running {
+		p.Warnings = append(p.Warnings, "The worker is not running: nothing is being judged.")
+	}
+
+	if version, err := engine.IsolateVersion(); err != nil {
+		p.Isolate = err.Error()
+		p.Warnings = append(p.Warnings, "isolate cannot run: every submission gets IE.")
+	} else {
+		p.Isolate, p.IsolateOK = version, true
+	}
+
+	if size, err := h.storageSize.get("storage"); err != nil {
+		p.StorageSize = err.Error()
+	} else {
+		p.StorageSize = formatBytes(size)
+	}
+	var st syscall.Statfs_t
+	if err := syscall.Statfs("storage", &st); err == nil {
+		free := int64(st.Bavail) * int64(st.Bsize)
+		p.DiskFree = formatBytes(free)
+		if free < 1<<30 {
+			p.Warnings = append(p.Warnings, "Less than 1 GB of disk space left.")
+		}
+	}
+	p.DBSize = fileSize(data.Path)
+	p.WALSize = fileSize(data.Path + "-wal")
+
+	pending, err := h.stores.Submissions.PendingSubmissions()
+	if err != nil {
+		log.Printf("Health DB Error: %v", err)
+		http.Error(w, "DB Error", http.StatusInternalServerError)
+		return
+	}
+	p.Pending = len(pending)
+	if len(pending) > 0 {
+		p.OldestID = pending[0].ID
+		age := time.Since(pending[0].CreatedAt).Round(time.Second)
+		p.OldestAge = age.String()
+		if age > 5*time.Minute {
+			p.Warnings = append(p.Warnings, fmt.Sprintf("Submission %d has been pending for %s.", p.OldestID, p.OldestAge))
+		}
+	}
+
+	renderTemplate(w, "health.html", p)
+}
+
+// Walking storage/ reads every submission and test file, so the page (which
+// refreshes every 10 seconds) reuses the total for a minute
+const sizeCacheTTL = time.Minute
+
+type sizeCache struct {
+	mu   sync.Mutex
+	at   time.Time
+	size int64
+	err  error
+}
+
+func (c *sizeCache) get(dir string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.at.IsZero() || time.Since(c.at) > sizeCacheTTL {
+		c.size, c.err = dirSize(dir)
+		c.at = time.Now()
+	}
+	return c.size, c.err
+}
+
+// dirSize adds up the files under dir
+func dirSize(dir string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total, err
+}
+
+func fileSize(path string) string {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return "none"
+	} else if err != nil {
+		return err.Error()
+	}
+	return formatBytes(info.Size())
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ifuaslaerl/Judge/internal/engine"
+	"github.com/ifuaslaerl/Judge/internal/middleware"
+)
+
+// Custom invocation limits (per user)
+const (
+	runRateWindow = time.Minute
+	runRateLimit  = 5                // Runs per window
+	runWaitLimit  = 60 * time.Second // Max time spent queued + running
+	maxRunSource  = 64 * 1024
+	maxRunStdin   = 1024 * 1024
+)
+
+var (
+	runHistory = make(map[int][]time.Time) // UserID -> recent run timestamps
+	runMutex   sync.Mutex
+)
+
+// allowRun applies a sliding-window rate limit
+func allowRun(userID int) bool {
+	runMutex.Lock()
+	defer runMutex.Unlock()
+
+	now := time.Now()
+	recent := runHistory[userID][:0]
+	for _, t := range runHistory[userID] {
+		if now.Sub(t) < runRateWindow {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= runRateLimit {
+		runHistory[userID] = recent
+		return false
+	}
+	runHistory[userID] = append(recent, now)
+	return true
+}
+
+// POST /run/[problem_id] (form fields: language = cpp|py, source, stdin)
+// Runs code in the sandbox with the problem's limits. Not a submission.
+// Responds with JSON (engine.RunOutput) or an error JSON object.
+func (h *Handler) HandleRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	userID := r.Context().Value(middleware.UserIDKey).(int)
+
+	problemID, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(r.URL.Path, "/run/"), "/"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid problem id")
+		return
+	}
+
+	problem, err := h.stores.Problems.Problem(problemID)
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, "problem not found")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRunSource+maxRunStdin+4096)
+	if err := r.ParseMultipartForm(maxRunSource + maxRunStdin + 4096); err != nil && err != http.ErrNotMultipart {
+		writeAPIError(w, http.StatusBadRequest, "request too large")
+		return
+	}
+
+	var ext string
+	switch r.FormValue("language") {
+	case "cpp":
+		ext = ".cpp"
+	case "py":
+		ext = ".py"
+	default:
+		writeAPIError(w, http.StatusBadRequest, "language must be cpp or py")
+		return
+	}
+
+	source := r.FormValue("source")
+	stdin := r.FormValue("stdin")
+	if source == "" {
+		writeAPIError(w, http.StatusBadRequest, "source is empty")
+		return
+	}
+	if len(source) > maxRunSource || len(stdin) > maxRunStdin {
+		writeAPIError(w, http.StatusBadRequest, "source (64KB) or input (1MB) too large")
+		return
+	}
+
+	if !allowRun(userID) {
+		writeAPIError(w, http.StatusTooManyRequests, "too many runs, wait a minute")
+		return
+	}
+
+	req := engine.NewRunRequest(r.Context(), []byte(source), ext, []byte(stdin), problem.TimeLimit)
+	select {
+	case engine.RunQueue <- req:
+	default:
+		writeAPIError(w, http.StatusServiceUnavailable, "run queue is full, try again later")
+		return
+	}
+
+	select {
+	case out := <-req.Result:
+		writeJSON(w, http.StatusOK, out)
+	case <-time.After(runWaitLimit):
+		writeAPIError(w, http.StatusServiceUnavailable, "judge is busy, try again later")
+	case <-r.Context().Done():
+	}
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ifuaslaerl/Judge/internal/data"
+	"github.com/ifuaslaerl/Judge/internal/highlight"
+	"github.com/ifuaslaerl/Judge/internal/middleware"
+)
+
+// Sources larger than this are offered as a download only
+const maxShownSource = 256 * 1024
+
+type submissionDetail struct {
+	ID            int
+	Problem       string
+	ProblemID     int
+	ProblemName   string
+	Language      string
+	Status        string
+	Created       string
+	Judged        string
+	CompileOutput string
+	Source        template.HTML // Highlighted
+	SourceTooBig  bool
+	FailedTest    *data.TestResult
+	MaxTimeMs     int
+	MaxMemoryKB   int
+	Tests         []data.TestResult
+
+	// Admins only: who sent it and the override form
+	Admin    bool
+	User     string
+	Verdicts []string
+}
+
+func languageName(filePath string) string {
+	switch filepath.Ext(filePath) {
+	case ".cpp":
+		return "C++17"
+	case ".py":
+		return "Python 3"
+	default:
+		return filepath.Ext(filePath)
+	}
+}
+
+// GET /submission/[id]          Detail page
+// GET /submission/[id]/source   Source download
+// Only the owner (or an admin) can see a submission; others get 404.
+func (h *Handler) HandleSubmissionDetail(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int)
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/submission/"), "/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || len(parts) > 2 || (len(parts) == 2 && parts[1] != "source") {
+		http.NotFound(w, r)
+		return
+	}
+
+	admin := h.auth.IsAdmin(userID)
+	sub, err := h.stores.Submissions.Submission(id)
+	if err == data.ErrNotFound || (err == nil && sub.UserID != userID && !admin) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Printf("Submission DB Error: %v", err)
+		http.Error(w, "DB Error", http.StatusInternalServerError)
+		return
+	}
+	filePath := sub.FilePath
+	d := submissionDetail{
+		ID:            sub.ID,
+		Problem:       sub.Problem,
+		ProblemID:     sub.ProblemID,
+		Status:        sub.Status,
+		CompileOutput: sub.CompileOutput,
+	}
+	if p, err := h.stores.Problems.Problem(sub.ProblemID); err == nil {
+		d.ProblemName = p.Name
+	}
+
+	// Download
+	if len(parts) == 2 {
+		if _, err := os.Stat(filePath); err != nil {
+			http.Error(w, "Source file missing from storage", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%d%s"`, d.ID, filepath.Ext(filePath)))
+		http.ServeFile(w, r, filePath)
+		return
+	}
+
+	d.Language = languageName(filePath)
+	d.Created = sub.CreatedAt.Format("2006-01-02 15:04:05")
+	if !sub.JudgedAt.IsZero() {
+		d.Judged = sub.JudgedAt.Format("2006-01-02 15:04:05")
+	}
+
+	if info, err := os.Stat(filePath); err == nil && info.Size() > maxShownSource {
+		d.SourceTooBig = true
+	} else if src, err := os.ReadFile(filePath); err == nil {
+		d.Source = highlight.Source(string(src), filepath.Ext(filePath))
+	}
+
+	if admin {
+		d.Admin, d.Verdicts = true, overrideVerdicts
+		if u, err := h.stores.Users.User(sub.UserID); err == nil {
+			d.User = fmt.Sprintf("%s (%s)", u.DisplayName, u.Username)
+		}
+	}
+
+	tests, err := h.stores.Submissions.Tests(id)
+	if err != nil {
+		log.Printf("Submission DB Error: %v", err)
+		http.Error(w, "DB Error", http.StatusInternalServerError)
+		return
+	}
+	for _, t := range tests {
+		d.MaxTimeMs = max(d.MaxTimeMs, t.TimeMs)
+		d.MaxMemoryKB = max(d.MaxMemoryKB, t.MemoryKB)
+		d.Tests = append(d.Tests, t)
+	}
+	for i := range d.Tests {
+		if d.Tests[i].Verdict != "AC" {
+			d.FailedTest = &d.Tests[i]
+		}
+	}
+
+	renderTemplate(w, "submission.html", d)
+}
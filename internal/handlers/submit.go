@@ -1,7 +1,14 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"github.com/ifuaslaerl/Judge/internal/audit"
+	"github.com/ifuaslaerl/Judge/internal/auth"
+	"github.com/ifuaslaerl/Judge/internal/config"
+	"github.com/ifuaslaerl/Judge/internal/data"
+	"github.com/ifuaslaerl/Judge/internal/engine"
+	"github.com/ifuaslaerl/Judge/internal/middleware"
 	"io"
 	"log"
 	"net/http"
@@ -9,13 +16,42 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
-	"github.com/ifuaslaerl/Judge/internal/data"
-	"github.com/ifuaslaerl/Judge/internal/engine"
-	"github.com/ifuaslaerl/Judge/internal/middleware"
+)
+
+// Submission cap and upload size (config [limits])
+var limits = config.Default().Limits
+
+// Configure sets the limits enforced by the handlers; call before serving
+func Configure(l config.Limits) {
+	limits = l
+}
+
+// Handler serves the web pages and the API from its stores (data.NewSQLiteStores
+// in production), submitting to and reading the scoreboard of its judge
+type Handler struct {
+	stores *data.Stores
+	judge  *engine.Judge
+	auth   *auth.Service
+	audit  *audit.Logger
+
+	storageSize sizeCache // storage/ on the health page
+}
+
+func New(s *data.Stores, j *engine.Judge, a *auth.Service) *Handler {
+	return &Handler{stores: s, judge: j, auth: a, audit: audit.New(s)}
+}
+
+// Submission pipeline failures, mapped to HTTP responses by each caller
+var (
+	errUnknownProblem = errors.New("problem not found")
+	errSubmissionCap  = errors.New("submission limit reached")
+	errBadExtension   = errors.New("unsupported file extension")
+	errStorageFailure = errors.New("storage failure")
+	errQueueFull      = errors.New("queue full")
 )
 
 // HandleSubmission processes the upload: POST /submit/[problem_id]
-func HandleSubmission(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) HandleSubmission(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -29,7 +65,7 @@ func HandleSubmission(w http.ResponseWriter, r *http.Request) {
 
 	cleanPath := strings.TrimSuffix(r.URL.Path, "/")
 	parts := strings.Split(cleanPath, "/")
-	
+
 	if len(parts) == 0 {
 		http.Error(w, "Invalid URL", http.StatusBadRequest)
 		return
@@ -40,23 +76,9 @@ func HandleSubmission(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Enforce Submission Cap
-	var count int
-	err = data.DB.QueryRow("SELECT COUNT(*) FROM submissions WHERE user_id = ?", userID).Scan(&count)
-	if err != nil {
-		log.Printf("DB Error: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
-	if count >= 100 {
-		http.Error(w, "Submission limit reached (Max 100). Contact Admin.", http.StatusForbidden)
-		return
-	}
-
-	const maxUploadSize = (1024 * 1024) + 4096 
-	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
-	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
-		http.Error(w, "File too large (Max 1MB)", http.StatusBadRequest)
+	r.Body = http.MaxBytesReader(w, r.Body, limits.MaxUploadBytes())
+	if err := r.ParseMultipartForm(limits.MaxUploadBytes()); err != nil {
+		http.Error(w, fmt.Sprintf("File too large (Max %dKB)", limits.MaxUploadKB), http.StatusBadRequest)
 		return
 	}
 
@@ -67,63 +89,95 @@ func HandleSubmission(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-    // --- PHASE 8 UPDATE: Detect Extension ---
-    ext := strings.ToLower(filepath.Ext(header.Filename))
-    if ext != ".cpp" && ext != ".py" {
-        http.Error(w, "Only .cpp and .py files are allowed", http.StatusBadRequest)
-        return
-    }
-
-	res, err := data.DB.Exec(`INSERT INTO submissions (user_id, problem_id, status, file_path) VALUES (?, ?, 'PENDING', '')`, userID, problemID)
-	if err != nil {
-		log.Printf("DB Insert Failed: %v", err)
+	_, err = h.createSubmission(userID, problemID, header.Filename, file)
+	switch {
+	case err == nil:
+	case errors.Is(err, errUnknownProblem):
+		http.Error(w, "Problem not found", http.StatusNotFound)
+		return
+	case errors.Is(err, errSubmissionCap):
+		http.Error(w, fmt.Sprintf("Submission limit reached (Max %d). Contact Admin.", limits.MaxSubmissions), http.StatusForbidden)
+		return
+	case errors.Is(err, errBadExtension):
+		http.Error(w, "Only .cpp and .py files are allowed", http.StatusBadRequest)
+		return
+	case errors.Is(err, errQueueFull):
+		http.Error(w, "System overloaded", http.StatusServiceUnavailable)
+		return
+	case errors.Is(err, errStorageFailure):
+		http.Error(w, "Storage failure", http.StatusInternalServerError)
+		return
+	default:
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
-	
-	submissionID, err := res.LastInsertId()
+
+	http.Redirect(w, r, "/status", http.StatusSeeOther)
+}
+
+// createSubmission runs the shared pipeline: cap check, DB insert (PENDING),
+// disk write with rollback, then enqueue. Used by the web form and the API.
+func (h *Handler) createSubmission(userID, problemID int, filename string, src io.Reader) (int, error) {
+	if _, err := h.stores.Problems.Problem(problemID); err == data.ErrNotFound {
+		return 0, errUnknownProblem
+	} else if err != nil {
+		log.Printf("DB Error: %v", err)
+		return 0, err
+	}
+
+	// Enforce Submission Cap
+	count, err := h.stores.Submissions.CountSubmissions(data.SubmissionFilter{UserID: userID})
 	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
-		return
+		log.Printf("DB Error: %v", err)
+		return 0, err
+	}
+	if count >= limits.MaxSubmissions {
+		return 0, errSubmissionCap
 	}
 
-    // --- PHASE 8 UPDATE: Use detected extension in filename ---
-	filename := fmt.Sprintf("%d%s", submissionID, ext)
-	filePath := filepath.Join("storage", "submissions", filename)
+	// --- PHASE 8 UPDATE: Detect Extension ---
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext != ".cpp" && ext != ".py" {
+		return 0, errBadExtension
+	}
+
+	submissionID, err := h.stores.Submissions.CreateSubmission(userID, problemID)
+	if err != nil {
+		log.Printf("DB Insert Failed: %v", err)
+		return 0, err
+	}
+
+	// --- PHASE 8 UPDATE: Use detected extension in filename ---
+	filePath := filepath.Join("storage", "submissions", fmt.Sprintf("%d%s", submissionID, ext))
 
 	dst, err := os.Create(filePath)
 	if err != nil {
 		log.Printf("Disk Write Error: %v. Rolling back submission %d", err, submissionID)
-		data.DB.Exec("DELETE FROM submissions WHERE id = ?", submissionID)
-		http.Error(w, "Storage failure", http.StatusInternalServerError)
-		return
+		h.stores.Submissions.DeleteSubmission(submissionID)
+		return 0, errStorageFailure
 	}
 
-	if _, err := io.Copy(dst, file); err != nil {
+	if _, err := io.Copy(dst, src); err != nil {
 		dst.Close()
 		log.Printf("File Copy Error: %v. Rolling back submission %d", err, submissionID)
 		os.Remove(filePath)
-		data.DB.Exec("DELETE FROM submissions WHERE id = ?", submissionID)
-		http.Error(w, "Storage failure", http.StatusInternalServerError)
-		return
+		h.stores.Submissions.DeleteSubmission(submissionID)
+		return 0, errStorageFailure
 	}
 	dst.Close()
 
-	if _, err := data.DB.Exec("UPDATE submissions SET file_path = ? WHERE id = ?", filePath, submissionID); err != nil {
+	if err := h.stores.Submissions.SetSubmissionFile(submissionID, filePath); err != nil {
 		log.Printf("CRITICAL: Failed to link file path. Rolling back submission %d. Error: %v", submissionID, err)
 		os.Remove(filePath)
-		data.DB.Exec("DELETE FROM submissions WHERE id = ?", submissionID)
-		http.Error(w, "System error during finalization", http.StatusInternalServerError)
-		return
+		h.stores.Submissions.DeleteSubmission(submissionID)
+		return 0, errStorageFailure
 	}
 
-	select {
-	case engine.SubmissionQueue <- int(submissionID):
-	default:
+	if !engine.Enqueue(submissionID) {
 		log.Printf("CRITICAL: Queue full! Submission %d dropped.", submissionID)
-		http.Error(w, "System overloaded", http.StatusServiceUnavailable)
-		return
+		return 0, errQueueFull
 	}
 
-	http.Redirect(w, r, "/status", http.StatusSeeOther)
+	h.judge.PublishVerdict(userID, submissionID, "PENDING")
+	return submissionID, nil
 }
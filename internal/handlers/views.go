@@ -1,20 +1,24 @@
 package handlers
 
 import (
-	"database/sql"
+	"errors"
 	"html/template"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
-	"github.com/ifuaslaerl/Judge/internal/auth"
+	"github.com/ifuaslaerl/Judge/internal/audit"
         "github.com/ifuaslaerl/Judge/internal/data"
 	"github.com/ifuaslaerl/Judge/internal/middleware"
 	"github.com/ifuaslaerl/Judge/internal/engine"
+	"github.com/ifuaslaerl/Judge/internal/statement"
 )
 
 // --- Templates ---
@@ -35,25 +39,29 @@ func renderTemplate(w http.ResponseWriter, tmplName string, data interface{}) {
 // --- Handlers ---
 
 // GET /login
-func HandleLogin(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) HandleLogin(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodPost {
-		processLogin(w, r)
+		h.processLogin(w, r)
 		return
 	}
 	renderTemplate(w, "login.html", nil)
 }
 
 // POST /login logic
-func processLogin(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) processLogin(w http.ResponseWriter, r *http.Request) {
 	username := r.FormValue("username")
 	password := r.FormValue("password")
 
-	var id int
-	var hash string
+	if loginBlocked(clientIP(r)) {
+		http.Error(w, "Too many failed logins, try again in a few minutes", http.StatusTooManyRequests)
+		return
+	}
 
-	// 1. Check User
-	err := data.DB.QueryRow("SELECT id, password_hash FROM users WHERE username = ?", username).Scan(&id, &hash)
-	if err == sql.ErrNoRows {
+	// 1-2. Check User & Compare Hash
+	id, err := h.checkCredentials(username, password)
+	if err == errInvalidCredentials {
+		recordLoginFailure(clientIP(r))
+		h.audit.Log(username, audit.LoginFailed, "", "from "+clientIP(r))
 		http.Error(w, "Invalid Credentials", http.StatusUnauthorized)
 		return
 	} else if err != nil {
@@ -61,102 +69,188 @@ func processLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 2. Compare Hash
-	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
-		http.Error(w, "Invalid Credentials", http.StatusUnauthorized)
-		return
-	}
-
 	// 3. Create Session
-	token, err := auth.CreateSession(id)
+	token, err := h.auth.CreateSession(id)
 	if err != nil {
 		http.Error(w, "Session Creation Failed", http.StatusInternalServerError)
 		return
 	}
 
-	// 4. Set Cookie (CORRECTED)
+	h.audit.LogUser(id, audit.Login, "", "from "+clientIP(r))
+
+	// 4. Set Cookie (Secure unless the server runs on plain HTTP without a TLS proxy)
 	http.SetCookie(w, &http.Cookie{
 		Name:     "session_token",
 		Value:    token,
 		Path:     "/",
 		HttpOnly: true,
-		Secure:   true,      // Required for your HTTPS setup
+		Secure:   middleware.IsHTTPS(r),
 		MaxAge:   315360000, // 10 years in seconds (Indefinite persistence)
 	})
 
 	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
 }
 
-// GET /dashboard
-func HandleDashboard(w http.ResponseWriter, r *http.Request) {
-	// Fetch Problems
-	rows, err := data.DB.Query("SELECT id, letter_code, time_limit, pdf_path FROM problems ORDER BY letter_code")
+var errInvalidCredentials = errors.New("invalid credentials")
+
+// Failed logins per client IP, for the login form and POST /api/v1/login
+const (
+	loginFailWindow = 5 * time.Minute
+	loginFailLimit  = 10 // Failures per window before logins are refused
+)
+
+var (
+	loginFailures = make(map[string][]time.Time) // Client IP -> recent failures
+	loginMutex    sync.Mutex
+)
+
+// loginBlocked reports whether ip used up its failed logins (sliding window,
+// like allowRun). Successful logins are never counted.
+func loginBlocked(ip string) bool {
+	loginMutex.Lock()
+	defer loginMutex.Unlock()
+	return len(recentLoginFailures(ip, time.Now())) >= loginFailLimit
+}
+
+func recordLoginFailure(ip string) {
+	loginMutex.Lock()
+	defer loginMutex.Unlock()
+	now := time.Now()
+	for other := range loginFailures { // Forget clients that stopped trying
+		recentLoginFailures(other, now)
+	}
+	loginFailures[ip] = append(loginFailures[ip], now)
+}
+
+// recentLoginFailures drops the failures of ip older than the window (loginMutex held)
+func recentLoginFailures(ip string, now time.Time) []time.Time {
+	recent := loginFailures[ip][:0]
+	for _, t := range loginFailures[ip] {
+		if now.Sub(t) < loginFailWindow {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) == 0 {
+		delete(loginFailures, ip)
+		return nil
+	}
+	loginFailures[ip] = recent
+	return recent
+}
+
+// clientIP is the remote address of the request, without the port (the client's
+// address from X-Forwarded-For behind a trusted proxy, see middleware.ProxyHeaders)
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
-		http.Error(w, "DB Error", http.StatusInternalServerError)
-		return
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// checkCredentials verifies a username/password pair and returns the user ID
+func (h *Handler) checkCredentials(username, password string) (int, error) {
+	u, err := h.stores.Users.UserByUsername(username)
+	if err == data.ErrNotFound {
+		return 0, errInvalidCredentials
+	} else if err != nil {
+		return 0, err
 	}
-	defer rows.Close()
 
-	type Problem struct {
-		ID        int
-		Letter    string
-		TimeLimit int
-		PDF       string
+	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil {
+		return 0, errInvalidCredentials
 	}
+	return u.ID, nil
+}
 
-	var problems []Problem
-	for rows.Next() {
-		var p Problem
-		if err := rows.Scan(&p.ID, &p.Letter, &p.TimeLimit, &p.PDF); err != nil {
-			continue
-		}
-		problems = append(problems, p)
+// GET /dashboard
+func (h *Handler) HandleDashboard(w http.ResponseWriter, r *http.Request) {
+	// Fetch Problems
+	problems, err := h.stores.Problems.Problems()
+	if err != nil {
+		http.Error(w, "DB Error", http.StatusInternalServerError)
+		return
 	}
 
 	renderTemplate(w, "dashboard.html", problems)
 }
 
-// GET /status
-func HandleStatus(w http.ResponseWriter, r *http.Request) {
+const statusPageSize = 20
+
+// GET /status?page=N&problem=ID
+// Full submission history of the user, newest first, optionally for one problem
+func (h *Handler) HandleStatus(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(middleware.UserIDKey).(int)
 
-	// Fetch recent submissions for this user
-	rows, err := data.DB.Query(`
-		SELECT s.id, p.letter_code, s.status, s.created_at 
-		FROM submissions s 
-		JOIN problems p ON s.problem_id = p.id 
-		WHERE s.user_id = ? 
-		ORDER BY s.id DESC LIMIT 20`, userID)
-	
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	problemID, _ := strconv.Atoi(r.URL.Query().Get("problem")) // 0 = all problems
+
+	filter := data.SubmissionFilter{UserID: userID, ProblemID: problemID}
+	total, err := h.stores.Submissions.CountSubmissions(filter)
+	if err != nil {
+		http.Error(w, "DB Error", http.StatusInternalServerError)
+		return
+	}
+
+	filter.Limit, filter.Offset = statusPageSize, (page-1)*statusPageSize
+	list, err := h.stores.Submissions.ListSubmissions(filter)
 	if err != nil {
 		http.Error(w, "DB Error", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
 	type Submission struct {
-		ID        int
-		Problem   string
-		Status    string
-		Time      string
+		ID           int
+		Problem      string
+		Status       string
+		Time         string
+		FailedSample int    // Test number of the failed sample (0 = none)
+		SampleOutput string // What the program printed on it
 	}
 
 	var subs []Submission
-	for rows.Next() {
-		var s Submission
-		var t time.Time
-		if err := rows.Scan(&s.ID, &s.Problem, &s.Status, &t); err != nil {
-			continue
+	for _, ls := range list {
+		s := Submission{
+			ID:      ls.ID,
+			Problem: ls.Problem,
+			Status:  ls.Status,
+			Time:    ls.CreatedAt.Format("2006-01-02 15:04:05"),
+		}
+		if s.Status != "AC" && s.Status != "PENDING" {
+			tests, _ := h.stores.Submissions.Tests(s.ID)
+			for _, t := range tests {
+				if t.Sample && t.Verdict != "AC" {
+					s.FailedSample, s.SampleOutput = t.Number, t.Output
+				}
+			}
 		}
-		s.Time = t.Format("15:04:05")
 		subs = append(subs, s)
 	}
 
-	renderTemplate(w, "status.html", subs)
+	// Problem filter options
+	problems, _ := h.stores.Problems.Problems()
+
+	pages := max((total+statusPageSize-1)/statusPageSize, 1)
+	next := 0 // 0 = no such page
+	if page < pages {
+		next = page + 1
+	}
+	renderTemplate(w, "status.html", struct {
+		Submissions []Submission
+		Problems    []data.Problem
+		ProblemID   int
+		Page        int
+		Pages       int
+		Prev, Next  int
+		Total       int
+	}{subs, problems, problemID, page, pages, page - 1, next, total})
 }
 
-// GET /problems/[id]/pdf
-func HandlePDF(w http.ResponseWriter, r *http.Request) {
+// GET /problems/[id]/pdf (also /problems/[id]/statement/[file], see handleStatementAsset)
+func (h *Handler) HandlePDF(w http.ResponseWriter, r *http.Request) {
 	// 1. Parse ID from URL
 	// Path format: /problems/[id]/pdf
 	parts := strings.Split(r.URL.Path, "/")
@@ -168,14 +262,19 @@ func HandlePDF(w http.ResponseWriter, r *http.Request) {
 	// The ID should be the second to last part (index 2 in /problems/1/pdf)
 	// We might need to adjust based on strict parsing, but let's assume standard routing
 	idStr := parts[2]
+	if len(parts) >= 5 && parts[3] == "statement" {
+		handleStatementAsset(w, r, idStr, strings.Join(parts[4:], "/"))
+		return
+	}
 
 	// 2. Query DB for file path
-	var pdfPath string
-	err := data.DB.QueryRow("SELECT pdf_path FROM problems WHERE id = ?", idStr).Scan(&pdfPath)
+	id, _ := strconv.Atoi(idStr)
+	p, err := h.stores.Problems.Problem(id)
 	if err != nil {
 		http.Error(w, "Problem or PDF not found", http.StatusNotFound)
 		return
 	}
+	pdfPath := p.PDFPath
 
 	// 3. Serve the file
 	// Verify file exists on disk first
@@ -188,55 +287,109 @@ func HandlePDF(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, pdfPath)
 }
 
-// Add to internal/handlers/views.go
+// Images and other files referenced by a Markdown/HTML statement
+func handleStatementAsset(w http.ResponseWriter, r *http.Request, idStr, name string) {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid URL", http.StatusBadRequest)
+		return
+	}
+	path, err := statement.AssetPath(id, name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFile(w, r, path)
+}
+
+// problemPage is a problem as shown on its page and in the problem book
+type problemPage struct {
+	ID        int
+	Letter    string
+	Name      string
+	TimeLimit int
+	HasPDF    bool
+	Statement template.HTML // Rendered Markdown/HTML statement, empty if none
+	Samples   []engine.Sample
+}
+
+// newProblemPage loads the statement; rendering errors are shown to the reader
+func newProblemPage(pr data.Problem) problemPage {
+	p := problemPage{ID: pr.ID, Letter: pr.Letter, Name: pr.Name, TimeLimit: pr.TimeLimit, HasPDF: pr.PDFPath != ""}
+	html, err := statement.Render(p.ID)
+	if err != nil && err != statement.ErrNoStatement {
+		log.Printf("Statement Error [Problem %d]: %v", p.ID, err)
+		html = template.HTML("<p><b>Statement could not be rendered.</b></p>")
+	}
+	p.Statement = html
+	p.Samples = engine.LoadSamples(p.ID)
+	return p
+}
 
 // GET /problems/all
-func HandleManualBook(w http.ResponseWriter, r *http.Request) {
-    // Serves the static manual book
+func (h *Handler) HandleManualBook(w http.ResponseWriter, r *http.Request) {
+    // A hand-made book in storage/all_problems.pdf takes precedence
     path := "storage/all_problems.pdf"
-    if _, err := os.Stat(path); os.IsNotExist(err) {
-        http.Error(w, "Manual book not found (storage/all_problems.pdf missing)", http.StatusNotFound)
+    if _, err := os.Stat(path); err == nil {
+        w.Header().Set("Content-Type", "application/pdf")
+        http.ServeFile(w, r, path)
+        return
+    }
+
+    // Otherwise the book is generated from the problem set (print it to get a PDF)
+    all, err := h.stores.Problems.Problems()
+    if err != nil {
+        http.Error(w, "DB Error", http.StatusInternalServerError)
         return
     }
-    w.Header().Set("Content-Type", "application/pdf")
-    http.ServeFile(w, r, path)
+
+    var problems []problemPage
+    for _, p := range all {
+        problems = append(problems, newProblemPage(p))
+    }
+    renderTemplate(w, "book.html", problems)
 }
 
 // GET /problems/[id]/view
-func HandleProblemView(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) HandleProblemView(w http.ResponseWriter, r *http.Request) {
     // 1. Parse ID
     parts := strings.Split(r.URL.Path, "/")
     if len(parts) < 4 { // /problems/1/view
         http.Error(w, "Invalid URL", http.StatusBadRequest)
         return
     }
-    idStr := parts[2]
+    id, _ := strconv.Atoi(parts[3])
 
     // 2. Query DB
-    var p struct {
-        ID        int
-        Letter    string
-        TimeLimit int
-    }
-    err := data.DB.QueryRow("SELECT id, letter_code, time_limit FROM problems WHERE id = ?", idStr).Scan(&p.ID, &p.Letter, &p.TimeLimit)
+    p, err := h.stores.Problems.Problem(id)
     if err != nil {
         http.Error(w, "Problem not found", http.StatusNotFound)
         return
     }
 
     // 3. Render Template
-    renderTemplate(w, "problem.html", p)
+    renderTemplate(w, "problem.html", newProblemPage(*p))
 }
 
 // Add to internal/handlers/views.go
 
 // GET /standings
-func HandleStandings(w http.ResponseWriter, r *http.Request) {
-    board, err := engine.GetScoreboard()
+func (h *Handler) HandleStandings(w http.ResponseWriter, r *http.Request) {
+    board, err := h.judge.GetScoreboard()
     if err != nil {
         log.Printf("Scoreboard Error: %v", err)
         http.Error(w, "Failed to calculate standings", http.StatusInternalServerError)
         return
     }
-    renderTemplate(w, "standings.html", board)
+
+    // Admins additionally get the archive download links
+    userID := r.Context().Value(middleware.UserIDKey).(int)
+    renderTemplate(w, "standings.html", struct {
+        *engine.Scoreboard
+        IsAdmin bool
+    }{board, h.auth.IsAdmin(userID)})
 }
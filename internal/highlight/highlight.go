@@ -0,0 +1,233 @@
+// Package highlight marks up submitted C++ and Python sources for the
+// submission pages: comments, strings, numbers, keywords, types and
+// preprocessor lines get a <span class="hl-..."> each (styled by the template).
+// It is a tokenizer, not a parser; anything it does not know stays plain text.
+package highlight
+
+import (
+	"html"
+	"html/template"
+	"strings"
+)
+
+// Token classes, used as CSS classes "hl-<class>"
+const (
+	comment = "com"
+	str     = "str"
+	number  = "num"
+	keyword = "kw"
+	typ     = "type"
+	pre     = "pre"
+)
+
+type language struct {
+	lineComment  string
+	blockComment [2]string // Empty: none
+	preprocessor bool      // # lines (C++)
+	stringPrefix string    // Letters allowed before a quote: r"", b"", f"" (Python)
+	triple       bool      // """ and ''' strings
+	rawStrings   bool      // R"delim(...)delim" (C++)
+	keywords     map[string]bool
+	types        map[string]bool
+}
+
+func words(s string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var cpp = &language{
+	lineComment:  "//",
+	blockComment: [2]string{"/*", "*/"},
+	preprocessor: true,
+	rawStrings:   true,
+	keywords: words(`alignas alignof and auto break case catch class const constexpr const_cast continue
+		decltype default delete do dynamic_cast else enum explicit export extern false for friend goto if
+		inline mutable namespace new noexcept not nullptr operator or private protected public register
+		reinterpret_cast return sizeof static static_assert static_cast struct switch template this
+		throw true try typedef typeid typename union using virtual volatile while xor`),
+	types: words(`bool char char16_t char32_t double float int long short signed unsigned void wchar_t
+		size_t int8_t int16_t int32_t int64_t uint8_t uint16_t uint32_t uint64_t
+		string vector map set multiset multimap unordered_map unordered_set pair tuple array deque
+		queue priority_queue stack bitset list std ll ull ld pii pll vi vll`),
+}
+
+var python = &language{
+	lineComment:  "#",
+	stringPrefix: "rRbBfFuU",
+	triple:       true,
+	keywords: words(`False None True and as assert async await break class continue def del elif else
+		except finally for from global if import in is lambda nonlocal not or pass raise return try
+		while with yield match case`),
+	types: words(`abs all any bin bool bytes chr dict divmod enumerate filter float format frozenset
+		hex input int isinstance iter len list map max min next object oct open ord pow print range
+		repr reversed round set slice sorted str sum super tuple type zip sys`),
+}
+
+// languages by file extension, as stored in storage/submissions
+var languages = map[string]*language{".cpp": cpp, ".py": python}
+
+// Source returns src as HTML with highlighting for ext (".cpp", ".py"); other
+// extensions are only escaped
+func Source(src, ext string) template.HTML {
+	lang, ok := languages[ext]
+	if !ok {
+		return template.HTML(html.EscapeString(src))
+	}
+
+	var b strings.Builder
+	emit := func(class, text string) {
+		if class == "" {
+			b.WriteString(html.EscapeString(text))
+			return
+		}
+		b.WriteString(`<span class="hl-` + class + `">` + html.EscapeString(text) + "</span>")
+	}
+
+	lineStart := true // Only whitespace so far on this line
+	for i := 0; i < len(src); {
+		rest := src[i:]
+		c := src[i]
+		n, class := 1, ""
+
+		switch {
+		case c == '\n':
+			lineStart = true
+			b.WriteByte('\n')
+			i++
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			// Plain
+		case strings.HasPrefix(rest, lang.lineComment):
+			n, class = lineEnd(rest), comment
+		case lang.blockComment[0] != "" && strings.HasPrefix(rest, lang.blockComment[0]):
+			n, class = closing(rest, len(lang.blockComment[0]), lang.blockComment[1]), comment
+		case lang.preprocessor && lineStart && c == '#':
+			n, class = preprocessorEnd(rest), pre
+		case lang.rawStrings && strings.HasPrefix(rest, `R"`):
+			n, class = rawString(rest), str
+		case c == '"' || c == '\'' || strings.IndexByte(lang.stringPrefix, c) >= 0 && stringAfterPrefix(rest, lang.stringPrefix):
+			n, class = quoted(rest, lang), str
+		case isDigit(c) || c == '.' && len(rest) > 1 && isDigit(rest[1]):
+			n, class = numberEnd(rest), number
+		case isIdentStart(c):
+			n = identEnd(rest)
+			if w := rest[:n]; lang.keywords[w] {
+				class = keyword
+			} else if lang.types[w] {
+				class = typ
+			}
+		}
+
+		emit(class, rest[:n])
+		if c != ' ' && c != '\t' && c != '\r' {
+			lineStart = false
+		}
+		i += n
+	}
+	return template.HTML(b.String())
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func identEnd(s string) int {
+	n := 1
+	for n < len(s) && (isIdentStart(s[n]) || isDigit(s[n])) {
+		n++
+	}
+	return n
+}
+
+// numberEnd covers 42, 3.14, 1e-9, 0x1F, 1'000'000, 10LL
+func numberEnd(s string) int {
+	n := 1
+	for n < len(s) {
+		c := s[n]
+		switch {
+		case isDigit(c) || c == '.' || c == '_' || isIdentStart(c) && c < 0x80:
+		case c == '\'' && n+1 < len(s) && isDigit(s[n+1]): // Digit separator
+		case (c == '+' || c == '-') && (s[n-1] == 'e' || s[n-1] == 'E') && !strings.HasPrefix(strings.ToLower(s), "0x"):
+		default:
+			return n
+		}
+		n++
+	}
+	return n
+}
+
+// lineEnd is the length up to (not including) the newline
+func lineEnd(s string) int {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return i
+	}
+	return len(s)
+}
+
+// closing is the length up to and including end, searched from skip
+func closing(s string, skip int, end string) int {
+	if i := strings.Index(s[skip:], end); i >= 0 {
+		return skip + i + len(end)
+	}
+	return len(s)
+}
+
+// preprocessorEnd follows backslash-continued lines
+func preprocessorEnd(s string) int {
+	n := 0
+	for {
+		n += lineEnd(s[n:])
+		if n == len(s) || n == 0 || s[n-1] != '\\' {
+			return n
+		}
+		n++ // Continued: include the newline
+	}
+}
+
+// rawString measures R"delim( ... )delim"
+func rawString(s string) int {
+	open := strings.IndexByte(s, '(')
+	if open < 0 || open > 18 || strings.ContainsAny(s[2:open], " \\)\n") {
+		return quoted(s[1:], cpp) + 1 // Not a raw string after all: R then "..."
+	}
+	return closing(s, open+1, ")"+s[2:open]+`"`)
+}
+
+// stringAfterPrefix reports whether s starts with prefix letters then a quote (f"...", rb'...')
+func stringAfterPrefix(s, prefixes string) bool {
+	n := 0
+	for n < len(s) && n < 2 && strings.IndexByte(prefixes, s[n]) >= 0 {
+		n++
+	}
+	return n > 0 && n < len(s) && (s[n] == '"' || s[n] == '\'')
+}
+
+// quoted measures a string or character literal (with its prefix), honoring
+// backslash escapes. Single-quoted strings end at the line end if unclosed.
+func quoted(s string, lang *language) int {
+	n := 0
+	for s[n] != '"' && s[n] != '\'' {
+		n++
+	}
+	q := s[n : n+1]
+	if lang.triple && strings.HasPrefix(s[n:], q+q+q) {
+		return closing(s, n+3, q+q+q)
+	}
+	for n++; n < len(s); n++ {
+		switch s[n] {
+		case '\\':
+			n++
+		case '\n':
+			return n
+		case q[0]:
+			return n + 1
+		}
+	}
+	return len(s)
+}
@@ -0,0 +1,33 @@
+package highlight
+
+import (
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		ext, src, want string
+	}{
+		{".cpp", "#include <bits/stdc++.h>\nint main() { return 0; }",
+			`<span class="hl-pre">#include &lt;bits/stdc++.h&gt;</span>` + "\n" +
+				`<span class="hl-type">int</span> main() { <span class="hl-kw">return</span> <span class="hl-num">0</span>; }`},
+		{".cpp", `long long x = 1'000'000LL; // big`,
+			`<span class="hl-type">long</span> <span class="hl-type">long</span> x = <span class="hl-num">1&#39;000&#39;000LL</span>; <span class="hl-com">// big</span>`},
+		{".cpp", `a = '\''; /* <b> */ s = "q\"</span>";`,
+			`a = <span class="hl-str">&#39;\&#39;&#39;</span>; <span class="hl-com">/* &lt;b&gt; */</span> s = <span class="hl-str">&#34;q\&#34;&lt;/span&gt;&#34;</span>;`},
+		{".cpp", `x = R"(a"b)"; y = 2 # 3`,
+			`x = <span class="hl-str">R&#34;(a&#34;b)&#34;</span>; y = <span class="hl-num">2</span> # <span class="hl-num">3</span>`},
+		{".cpp", "/* unclosed\n#define X", `<span class="hl-com">/* unclosed` + "\n" + `#define X</span>`},
+		{".py", "def f(n):  # doc\n    return f\"{n}\" + r'\\d' + '''a\n'b'''",
+			`<span class="hl-kw">def</span> f(n):  <span class="hl-com"># doc</span>` + "\n" +
+				`    <span class="hl-kw">return</span> <span class="hl-str">f&#34;{n}&#34;</span> + <span class="hl-str">r&#39;\d&#39;</span> + <span class="hl-str">&#39;&#39;&#39;a` + "\n" + `&#39;b&#39;&#39;&#39;</span>`},
+		{".py", `for x in range(1e-9): print(x)`,
+			`<span class="hl-kw">for</span> x <span class="hl-kw">in</span> <span class="hl-type">range</span>(<span class="hl-num">1e-9</span>): <span class="hl-type">print</span>(x)`},
+		{".java", `if (a < b) "x"`, `if (a &lt; b) &#34;x&#34;`},
+	}
+	for _, tt := range tests {
+		if got := string(Source(tt.src, tt.ext)); got != tt.want {
+			t.Errorf("Source(%q, %s)\n got %s\nwant %s", tt.src, tt.ext, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+// Package importer installs problem packages prepared with other tools
+// (Polygon, Kattis problem format) into the judge's storage layout.
+package importer
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ifuaslaerl/Judge/internal/data"
+	"github.com/ifuaslaerl/Judge/internal/engine"
+)
+
+// Package is a problem read from a foreign format, with paths into the
+// (extracted) package directory
+type Package struct {
+	Format        string // "polygon" or "kattis"
+	Name          string
+	Color         string   // Balloon color, "" = none
+	TimeLimitMs   int      // 0 = unknown
+	MemoryLimitMB int      // 0 = unknown (informational: the sandbox limit is fixed)
+	Statement     string   // PDF
+	Markdown      string   // Markdown statement (rendered in-page)
+	Assets        []string // Images referenced by the Markdown statement
+	Samples       []TestFile
+	Tests         []TestFile
+	Checker       *CheckerFiles // nil = default token comparison
+}
+
+type TestFile struct {
+	Input  string
+	Answer string
+}
+
+type CheckerFiles struct {
+	Protocol string   // engine.ProtocolTestlib or engine.ProtocolKattis
+	Files    []string // .cpp sources and headers, copied flat into checker/
+}
+
+// Options override values that are missing from (or wrong in) the package
+type Options struct {
+	Letter      string // Empty: next free letter
+	TimeLimitMs int    // 0: from the package
+	Color       string // Empty: from the package
+}
+
+// MaxExtractSize bounds extracted zip contents, here and in contest archives
+// (test data can be large)
+const MaxExtractSize = 2 << 30
+
+// Read detects the package format. path may be a zip or a directory.
+// The returned cleanup func removes any temporary extraction.
+func Read(path string) (*Package, func(), error) {
+	cleanup := func() {}
+	dir := path
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, cleanup, err
+	}
+	if !info.IsDir() {
+		tmp, err := os.MkdirTemp("", "import_")
+		if err != nil {
+			return nil, cleanup, err
+		}
+		cleanup = func() { os.RemoveAll(tmp) }
+		if err := extractZip(path, tmp); err != nil {
+			return nil, cleanup, err
+		}
+		dir = packageRoot(tmp)
+	}
+
+	var pkg *Package
+	switch {
+	case fileExists(filepath.Join(dir, "problem.xml")):
+		pkg, err = readPolygon(dir)
+	case fileExists(filepath.Join(dir, "problem.yaml")):
+		pkg, err = readKattis(dir)
+	default:
+		err = errors.New("not a problem package: expected problem.xml (Polygon) or problem.yaml (Kattis)")
+	}
+	return pkg, cleanup, err
+}
+
+// Install creates the problems row and copies statement, tests and checker
+// into storage/problems/[id]. On failure nothing is left behind.
+func Install(pkg *Package, opts Options) (int64, error) {
+	timeLimit := pkg.TimeLimitMs
+	if opts.TimeLimitMs > 0 {
+		timeLimit = opts.TimeLimitMs
+	}
+	if timeLimit <= 0 {
+		return 0, errors.New("package has no time limit, pass one explicitly")
+	}
+	if len(pkg.Samples)+len(pkg.Tests) == 0 {
+		return 0, errors.New("package contains no tests")
+	}
+
+	color := pkg.Color
+	if opts.Color != "" {
+		color = opts.Color
+	}
+	color, err := ParseColor(color)
+	if err != nil {
+		return 0, err
+	}
+
+	letter, err := ChooseLetter(opts.Letter)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := data.DB.Exec("INSERT INTO problems (letter_code, time_limit, pdf_path, name, color) VALUES (?, ?, '', ?, ?)",
+		letter, timeLimit, pkg.Name, color)
+	if err != nil {
+		return 0, err
+	}
+	id, _ := res.LastInsertId()
+	dir := filepath.Join("storage", "problems", strconv.FormatInt(id, 10))
+
+	rollback := func(cause error) (int64, error) {
+		os.RemoveAll(dir)
+		data.DB.Exec("DELETE FROM problems WHERE id = ?", id)
+		return 0, cause
+	}
+
+	if fileExists(dir) {
+		return rollback(fmt.Errorf("%s already exists", dir))
+	}
+
+	// 1. Tests: samples and hidden tests are numbered from 1 in their own directories
+	if err := copyTests(pkg.Samples, filepath.Join(dir, "samples")); err != nil {
+		return rollback(err)
+	}
+	if err := copyTests(pkg.Tests, filepath.Join(dir, "tests")); err != nil {
+		return rollback(err)
+	}
+
+	// 2. Statement
+	if pkg.Statement != "" {
+		pdfPath := filepath.Join(dir, "statement.pdf")
+		if err := copyFile(pkg.Statement, pdfPath); err != nil {
+			return rollback(err)
+		}
+		if _, err := data.DB.Exec("UPDATE problems SET pdf_path = ? WHERE id = ?", pdfPath, id); err != nil {
+			return rollback(err)
+		}
+	}
+
+	// Markdown statement and its images go to statement/ (see internal/statement)
+	if pkg.Markdown != "" {
+		sdir := filepath.Join(dir, "statement")
+		if err := copyFile(pkg.Markdown, filepath.Join(sdir, "statement.md")); err != nil {
+			return rollback(err)
+		}
+		for _, a := range pkg.Assets {
+			if err := copyFile(a, filepath.Join(sdir, filepath.Base(a))); err != nil {
+				return rollback(err)
+			}
+		}
+	}
+
+	// 3. Checker (compiled now so a broken checker fails the import, not the contest)
+	if pkg.Checker != nil {
+		cdir := filepath.Join(dir, "checker")
+		for _, f := range pkg.Checker.Files {
+			if err := copyFile(f, filepath.Join(cdir, filepath.Base(f))); err != nil {
+				return rollback(err)
+			}
+		}
+		if err := os.WriteFile(filepath.Join(cdir, "protocol"), []byte(pkg.Checker.Protocol+"\n"), 0644); err != nil {
+			return rollback(err)
+		}
+		if _, err := engine.LoadChecker(int(id)); err != nil {
+			return rollback(err)
+		}
+	}
+
+	if sandboxMB := engine.SandboxLimits().MemoryKB / 1000; pkg.MemoryLimitMB > 0 && pkg.MemoryLimitMB != sandboxMB {
+		log.Printf("WARNING: Package memory limit is %dMB; the sandbox always uses %dMB (config sandbox.memory_kb)", pkg.MemoryLimitMB, sandboxMB)
+	}
+	return id, nil
+}
+
+// ParseColor normalizes a balloon color: a CSS color name ("red") or a hex
+// code ("#f00", "#ff0000"; the # may be left out). Empty means no color.
+func ParseColor(color string) (string, error) {
+	color = strings.ToLower(strings.TrimSpace(color))
+	if color == "" {
+		return "", nil
+	}
+	hex := strings.TrimPrefix(color, "#")
+	if (len(hex) == 3 || len(hex) == 6) && strings.Trim(hex, "0123456789abcdef") == "" {
+		return "#" + hex, nil
+	}
+	if strings.Trim(color, "abcdefghijklmnopqrstuvwxyz") == "" {
+		return color, nil
+	}
+	return "", fmt.Errorf("invalid color %q: use a CSS color name or #rrggbb", color)
+}
+
+// ChooseLetter validates the requested letter or picks the one after the last problem
+// (empty letter)
+func ChooseLetter(letter string) (string, error) {
+	if letter == "" {
+		var last string
+		data.DB.QueryRow("SELECT COALESCE(MAX(letter_code), '') FROM problems").Scan(&last)
+		switch {
+		case last == "":
+			return "A", nil
+		case len(last) == 1 && last[0] >= 'A' && last[0] < 'Z':
+			return string(last[0] + 1), nil
+		default:
+			return "", fmt.Errorf("cannot pick a letter after %q, pass one explicitly", last)
+		}
+	}
+
+	var exists int
+	data.DB.QueryRow("SELECT COUNT(*) FROM problems WHERE letter_code = ?", letter).Scan(&exists)
+	if exists > 0 {
+		return "", fmt.Errorf("problem %s already exists", letter)
+	}
+	return letter, nil
+}
+
+func copyTests(tests []TestFile, dir string) error {
+	for i, t := range tests {
+		base := filepath.Join(dir, strconv.Itoa(i+1))
+		if err := copyFile(t.Input, base+".in"); err != nil {
+			return err
+		}
+		if err := copyFile(t.Answer, base+".out"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// extractZip unpacks an archive, rejecting entries that escape dest
+func extractZip(path, dest string) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	defer zr.Close()
+
+	var total int64
+	for _, f := range zr.File {
+		target := filepath.Join(dest, f.Name)
+		if !strings.HasPrefix(target, filepath.Clean(dest)+string(os.PathSeparator)) {
+			return fmt.Errorf("illegal path in archive: %s", f.Name)
+		}
+		if f.FileInfo().IsDir() {
+			os.MkdirAll(target, 0755)
+			continue
+		}
+
+		total += int64(f.UncompressedSize64)
+		if total > MaxExtractSize {
+			return errors.New("archive is too large")
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		out, err := os.Create(target)
+		if err != nil {
+			rc.Close()
+			return err
+		}
+		_, err = io.Copy(out, io.LimitReader(rc, MaxExtractSize))
+		rc.Close()
+		out.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// packageRoot descends into a single top-level directory (zips made with "zip -r pkg.zip pkg/")
+func packageRoot(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err == nil && len(entries) == 1 && entries[0].IsDir() {
+		return filepath.Join(dir, entries[0].Name())
+	}
+	return dir
+}
@@ -0,0 +1,64 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+		ok       bool
+	}{
+		{"", "", true},
+		{"Red", "red", true},
+		{"#FF8800", "#ff8800", true},
+		{"ff8800", "#ff8800", true},
+		{"#f80", "#f80", true},
+		{" lightblue ", "lightblue", true},
+		{"#ff88", "", false},
+		{"red; background: url(x)", "", false},
+		{"rgb(1,2,3)", "", false},
+	} {
+		got, err := ParseColor(tc.in)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("ParseColor(%q) = %q, %v; want %q (ok=%v)", tc.in, got, err, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestKattisColor(t *testing.T) {
+	for _, tc := range []struct {
+		yaml, want string
+	}{
+		{"name: Hello\nrgb: '#00FF00'\ncolor: red\n", "#00ff00"},
+		{"name: Hello\ncolor: red\n", "red"},
+		{"name: Hello\nrgb: not a color\ncolor: red\n", "red"},
+		{"name: Hello\n", ""},
+	} {
+		dir := t.TempDir()
+		files := map[string]string{
+			"problem.yaml":        tc.yaml,
+			"data/sample/1.in":    "1\n",
+			"data/sample/1.ans":   "1\n",
+			"data/secret/a/1.in":  "2\n",
+			"data/secret/a/1.ans": "2\n",
+		}
+		for name, content := range files {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			os.MkdirAll(filepath.Dir(path), 0755)
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		pkg, err := readKattis(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pkg.Color != tc.want || pkg.Name != "Hello" || len(pkg.Samples) != 1 || len(pkg.Tests) != 1 {
+			t.Errorf("%q: package = %+v, want color %q", tc.yaml, pkg, tc.want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package importer
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ifuaslaerl/Judge/internal/engine"
+)
+
+// --- Kattis problem format ---
+// problem.yaml, problem_statement/ (or statement/), data/sample, data/secret
+// (test groups are flattened in path order), output_validators/<name>/.
+
+func readKattis(dir string) (*Package, error) {
+	meta, err := readSimpleYAML(filepath.Join(dir, "problem.yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	pkg := &Package{Format: "kattis", Name: meta["name"]}
+	if pkg.Name == "" {
+		pkg.Name = meta["name.en"]
+	}
+	// Balloon color as in DOMjudge/contest problems.yaml: rgb: '#ff0000', color: red
+	for _, key := range []string{"rgb", "color"} {
+		if c, err := ParseColor(meta[key]); err == nil && c != "" {
+			pkg.Color = c
+			break
+		}
+	}
+
+	validation := meta["validation"]
+	if validation == "" {
+		validation = meta["type"] // 2023-07 format: "type: pass-fail" / "interactive"
+	}
+	if strings.Contains(validation, "interactive") {
+		return nil, fmt.Errorf("interactive problems are not supported")
+	}
+
+	// 1. Time limit: 2023-07 "limits.time_limit" (seconds), else problemtools' .timelimit
+	if tl := meta["limits.time_limit"]; tl != "" {
+		pkg.TimeLimitMs = secondsToMs(tl)
+	} else if raw, err := os.ReadFile(filepath.Join(dir, ".timelimit")); err == nil {
+		pkg.TimeLimitMs = secondsToMs(strings.TrimSpace(string(raw)))
+	}
+	if mem, err := strconv.Atoi(meta["limits.memory"]); err == nil {
+		pkg.MemoryLimitMB = mem
+	}
+
+	// 2. Statement: first PDF and Markdown file, English preferred
+	for _, sdir := range []string{"problem_statement", "statement"} {
+		pdfs, _ := filepath.Glob(filepath.Join(dir, sdir, "*.pdf"))
+		for _, p := range pdfs {
+			if pkg.Statement == "" || strings.HasSuffix(p, ".en.pdf") {
+				pkg.Statement = p
+			}
+		}
+		mds, _ := filepath.Glob(filepath.Join(dir, sdir, "*.md"))
+		for _, p := range mds {
+			if pkg.Markdown == "" || strings.HasSuffix(p, ".en.md") {
+				pkg.Markdown = p
+			}
+		}
+	}
+	if pkg.Markdown != "" {
+		for _, pattern := range []string{"*.png", "*.jpg", "*.jpeg", "*.gif", "*.svg"} {
+			m, _ := filepath.Glob(filepath.Join(filepath.Dir(pkg.Markdown), pattern))
+			pkg.Assets = append(pkg.Assets, m...)
+		}
+	}
+
+	// 3. Tests
+	if pkg.Samples, err = kattisTests(filepath.Join(dir, "data", "sample")); err != nil {
+		return nil, err
+	}
+	if pkg.Tests, err = kattisTests(filepath.Join(dir, "data", "secret")); err != nil {
+		return nil, err
+	}
+
+	// 4. Output validator (legacy: "validation: custom"; 2023-07: output_validator/ exists)
+	if strings.Contains(validation, "custom") || fileExists(filepath.Join(dir, "output_validator")) {
+		vdirs, _ := filepath.Glob(filepath.Join(dir, "output_validators", "*"))
+		if len(vdirs) == 0 {
+			vdirs, _ = filepath.Glob(filepath.Join(dir, "output_validator")) // 2023-07 format
+		}
+		if len(vdirs) == 0 {
+			return nil, fmt.Errorf("validation is custom but output_validators/ is empty")
+		}
+		var files []string
+		for _, pattern := range []string{"*.cpp", "*.h", "*.hpp"} {
+			m, _ := filepath.Glob(filepath.Join(vdirs[0], pattern))
+			files = append(files, m...)
+		}
+		if len(files) == 0 || filepath.Ext(files[0]) != ".cpp" {
+			return nil, fmt.Errorf("only C++ (.cpp) output validators are supported (%s)", vdirs[0])
+		}
+		pkg.Checker = &CheckerFiles{Protocol: engine.ProtocolKattis, Files: files}
+	}
+	return pkg, nil
+}
+
+// kattisTests collects *.in/*.ans pairs below dir, recursing into test groups
+func kattisTests(dir string) ([]TestFile, error) {
+	var tests []TestFile
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == dir {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".in" {
+			return nil
+		}
+		ans := strings.TrimSuffix(path, ".in") + ".ans"
+		if !fileExists(ans) {
+			return fmt.Errorf("%s has no .ans file", path)
+		}
+		tests = append(tests, TestFile{Input: path, Answer: ans})
+		return nil
+	})
+	sort.SliceStable(tests, func(i, j int) bool { return tests[i].Input < tests[j].Input })
+	return tests, err
+}
+
+func secondsToMs(s string) int {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f <= 0 {
+		return 0
+	}
+	return int(math.Round(f * 1000))
+}
+
+// readSimpleYAML reads the subset of YAML used by problem.yaml: nested maps of
+// scalars. Keys are flattened with dots ("limits.time_limit"); lists are skipped.
+func readSimpleYAML(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	type level struct {
+		indent int
+		key    string
+	}
+	var stack []level
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := sc.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "-") {
+			continue
+		}
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			continue
+		}
+
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		full := strings.TrimSpace(key)
+		if len(stack) > 0 {
+			full = stack[len(stack)-1].key + "." + full
+		}
+
+		value = strings.TrimSpace(value)
+		if i := strings.Index(value, " #"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+		if value == "" {
+			stack = append(stack, level{indent, full}) // Start of a nested map
+			continue
+		}
+		values[full] = strings.Trim(value, `"'`)
+	}
+	return values, sc.Err()
+}
@@ -0,0 +1,136 @@
+package importer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ifuaslaerl/Judge/internal/engine"
+)
+
+// --- Polygon ---
+// Reads a *full* Polygon package (problem.xml with generated tests/NN and
+// tests/NN.a). Standard packages only contain generator scripts.
+
+type polygonProblem struct {
+	Names []struct {
+		Language string `xml:"language,attr"`
+		Value    string `xml:"value,attr"`
+	} `xml:"names>name"`
+	Statements []struct {
+		Language string `xml:"language,attr"`
+		Path     string `xml:"path,attr"`
+		Type     string `xml:"type,attr"`
+	} `xml:"statements>statement"`
+	Testsets []struct {
+		Name          string `xml:"name,attr"`
+		TimeLimit     int    `xml:"time-limit"`
+		MemoryLimit   int64  `xml:"memory-limit"`
+		InputPattern  string `xml:"input-path-pattern"`
+		AnswerPattern string `xml:"answer-path-pattern"`
+		Tests         []struct {
+			Sample bool `xml:"sample,attr"`
+		} `xml:"tests>test"`
+	} `xml:"judging>testset"`
+	Resources []struct {
+		Path string `xml:"path,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"files>resources>file"`
+	Checker struct {
+		Type   string `xml:"type,attr"`
+		Source struct {
+			Path string `xml:"path,attr"`
+		} `xml:"source"`
+	} `xml:"assets>checker"`
+}
+
+func readPolygon(dir string) (*Package, error) {
+	raw, err := os.ReadFile(filepath.Join(dir, "problem.xml"))
+	if err != nil {
+		return nil, err
+	}
+	var p polygonProblem
+	if err := xml.Unmarshal(raw, &p); err != nil {
+		return nil, fmt.Errorf("problem.xml: %v", err)
+	}
+
+	pkg := &Package{Format: "polygon"}
+
+	// 1. Name and statement (English preferred)
+	for _, n := range p.Names {
+		if pkg.Name == "" || n.Language == "english" {
+			pkg.Name = n.Value
+		}
+	}
+	for _, s := range p.Statements {
+		if s.Type != "application/pdf" {
+			continue
+		}
+		if pkg.Statement == "" || s.Language == "english" {
+			pkg.Statement = filepath.Join(dir, filepath.FromSlash(s.Path))
+		}
+	}
+	if pkg.Statement != "" && !fileExists(pkg.Statement) {
+		pkg.Statement = ""
+	}
+
+	// 2. Tests: the "tests" testset is the one judged
+	if len(p.Testsets) == 0 {
+		return nil, fmt.Errorf("problem.xml has no testset")
+	}
+	ts := p.Testsets[0]
+	for _, t := range p.Testsets {
+		if t.Name == "tests" {
+			ts = t
+		}
+	}
+	pkg.TimeLimitMs = ts.TimeLimit
+	pkg.MemoryLimitMB = int(ts.MemoryLimit >> 20)
+
+	for i, t := range ts.Tests {
+		tf := TestFile{
+			Input:  filepath.Join(dir, filepath.FromSlash(fmt.Sprintf(ts.InputPattern, i+1))),
+			Answer: filepath.Join(dir, filepath.FromSlash(fmt.Sprintf(ts.AnswerPattern, i+1))),
+		}
+		if !fileExists(tf.Input) || !fileExists(tf.Answer) {
+			return nil, fmt.Errorf("test %d is missing (%s): use a full package with generated tests", i+1, tf.Input)
+		}
+		if t.Sample {
+			pkg.Samples = append(pkg.Samples, tf)
+		} else {
+			pkg.Tests = append(pkg.Tests, tf)
+		}
+	}
+
+	// 3. Checker (testlib), compiled with the package's headers (testlib.h)
+	if p.Checker.Source.Path != "" {
+		if p.Checker.Type != "" && p.Checker.Type != "testlib" {
+			return nil, fmt.Errorf("unsupported checker type %q", p.Checker.Type)
+		}
+		src := filepath.Join(dir, filepath.FromSlash(p.Checker.Source.Path))
+		if !fileExists(src) {
+			return nil, fmt.Errorf("checker source %s is missing", p.Checker.Source.Path)
+		}
+		files := []string{src}
+		for _, r := range p.Resources {
+			if ext := filepath.Ext(r.Path); ext == ".h" || ext == ".hpp" {
+				files = append(files, filepath.Join(dir, filepath.FromSlash(r.Path)))
+			}
+		}
+		if testlib := filepath.Join(filepath.Dir(src), "testlib.h"); fileExists(testlib) && !contains(files, testlib) {
+			files = append(files, testlib)
+		}
+		pkg.Checker = &CheckerFiles{Protocol: engine.ProtocolTestlib, Files: files}
+	}
+	return pkg, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
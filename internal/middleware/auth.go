@@ -3,6 +3,7 @@ package middleware
 import (
 	"context"
 	"net/http"
+	"strings"
 	"github.com/ifuaslaerl/Judge/internal/auth"
 )
 
@@ -11,9 +12,24 @@ type contextKey string
 
 const UserIDKey contextKey = "userID"
 
-// AuthMiddleware verifies the session cookie before allowing access
-func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+// Guard wraps routes that need a user, checking them with its auth.Service
+type Guard struct {
+	auth *auth.Service
+}
+
+func NewGuard(a *auth.Service) *Guard {
+	return &Guard{auth: a}
+}
+
+// AuthMiddleware verifies the session cookie before allowing access. Behind an
+// access proxy (config proxy.auth_header) its identity header is enough.
+func (g *Guard) AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if userID, ok := g.headerIdentity(r); ok {
+			next(w, r.WithContext(context.WithValue(r.Context(), UserIDKey, userID)))
+			return
+		}
+
 		// 1. Check for cookie
 		cookie, err := r.Cookie("session_token")
 		if err != nil {
@@ -22,10 +38,10 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		}
 
 		// 2. Validate token against DB
-		userID, ok := auth.GetUserFromSession(cookie.Value)
+		userID, ok := g.auth.GetUserFromSession(cookie.Value)
 		if !ok {
 			// Invalid/Expired token: Clear cookie and redirect
-			http.SetCookie(w, &http.Cookie{Name: "session_token", MaxAge: -1})
+			http.SetCookie(w, &http.Cookie{Name: "session_token", MaxAge: -1, Secure: IsHTTPS(r)})
 			http.Redirect(w, r, "/login", http.StatusSeeOther)
 			return
 		}
@@ -35,3 +51,50 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		next(w, r.WithContext(ctx))
 	}
 }
+
+// APITokenMiddleware verifies an "Authorization: Bearer <token>" header.
+// Failures are reported as JSON instead of redirecting to /login.
+func (g *Guard) APITokenMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			apiUnauthorized(w)
+			return
+		}
+
+		userID, ok := g.auth.GetUserFromAPIToken(strings.TrimSpace(token))
+		if !ok {
+			apiUnauthorized(w)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), UserIDKey, userID)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+func apiUnauthorized(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("WWW-Authenticate", `Bearer realm="judge"`)
+	w.WriteHeader(http.StatusUnauthorized)
+	w.Write([]byte(`{"error":"invalid or missing API token"}` + "\n"))
+}
+
+// AdminMiddleware restricts a route to admins. It must wrap a handler that
+// already passed AuthMiddleware or APITokenMiddleware (UserID in context).
+func (g *Guard) AdminMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(UserIDKey).(int)
+		if !ok || !g.auth.IsAdmin(userID) {
+			if strings.HasPrefix(r.URL.Path, "/api/") {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"error":"admin access required"}` + "\n"))
+				return
+			}
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/ifuaslaerl/Judge/internal/config"
+)
+
+// Reverse-proxy setup (config [proxy]), set with ConfigureProxy before serving
+var (
+	trustedNets []*net.IPNet
+	trustAll    bool // Unix socket: only local processes can connect
+	proxyCfg    config.Proxy
+)
+
+// ConfigureProxy sets which peers are trusted proxies; unixSocket trusts every peer
+func ConfigureProxy(p config.Proxy, unixSocket bool) error {
+	nets, err := p.Networks()
+	if err != nil {
+		return err
+	}
+	trustedNets, trustAll, proxyCfg = nets, unixSocket, p
+	return nil
+}
+
+const viaProxyKey contextKey = "viaProxy"
+
+type proxyInfo struct {
+	trusted bool // The peer is a trusted proxy
+	https   bool // The client used HTTPS (to us or to the proxy)
+}
+
+// isTrusted reports whether the peer at addr (RemoteAddr) is a trusted proxy
+func isTrusted(addr string) bool {
+	if trustAll {
+		return true
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return isTrustedIP(net.ParseIP(addr))
+}
+
+func isTrustedIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range trustedNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ProxyHeaders wraps the whole server. For requests from a trusted proxy it
+// replaces RemoteAddr with the client address from X-Forwarded-For (the last
+// hop not added by a trusted proxy) and honors X-Forwarded-Proto. Headers from
+// other peers are ignored, so clients cannot spoof their address.
+func ProxyHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := proxyInfo{https: r.TLS != nil}
+		if isTrusted(r.RemoteAddr) {
+			info.trusted = true
+			if client := forwardedFor(r.Header.Values("X-Forwarded-For")); client != "" {
+				r.RemoteAddr = client
+			}
+			if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+				info.https = strings.EqualFold(strings.TrimSpace(proto), "https")
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), viaProxyKey, info)))
+	})
+}
+
+// forwardedFor picks the client from X-Forwarded-For, walking from the right
+// past the trusted proxies (only the rightmost entries cannot be forged)
+func forwardedFor(values []string) string {
+	var hops []string
+	for _, v := range values {
+		for _, hop := range strings.Split(v, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(hops[i])
+		if ip == nil {
+			return "" // Garbage: keep the peer address
+		}
+		if i == 0 || !isTrustedIP(ip) {
+			return hops[i]
+		}
+	}
+	return ""
+}
+
+// IsHTTPS reports whether the client is on HTTPS, directly or through a trusted
+// proxy; session cookies are only marked Secure when it is
+func IsHTTPS(r *http.Request) bool {
+	if info, ok := r.Context().Value(viaProxyKey).(proxyInfo); ok {
+		return info.https
+	}
+	return r.TLS != nil
+}
+
+// headerIdentity resolves the user named by the trusted auth header (config
+// proxy.auth_header); false if the feature is off, the request did not come
+// through a trusted proxy or the value maps to no user
+func (g *Guard) headerIdentity(r *http.Request) (int, bool) {
+	if proxyCfg.AuthHeader == "" {
+		return 0, false
+	}
+	if info, ok := r.Context().Value(viaProxyKey).(proxyInfo); !ok || !info.trusted {
+		return 0, false
+	}
+	value := strings.TrimSpace(r.Header.Get(proxyCfg.AuthHeader))
+	if value == "" {
+		return 0, false
+	}
+	username := value
+	if mapped, ok := proxyCfg.Users[value]; ok {
+		username = mapped
+	}
+	return g.auth.LookupUser(username)
+}
@@ -0,0 +1,609 @@
+package statement
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// --- TeX to MathML ---
+// Statement math is converted on the server, so problem pages need no script
+// or network access (browsers render MathML natively). Only the subset used in
+// problem statements is understood: scripts, fractions, roots, \left/\right,
+// Greek letters and common symbols, \text, \mathbb and the cases/matrix/array
+// environments. Anything else shows up as an error box with its TeX source.
+
+// Statements come from problem packages, so a formula is bounded before it is
+// parsed: longer ones and deeper nesting show up as an error box
+const (
+	maxMathSize  = 4096 // Bytes of TeX in one formula
+	maxMathDepth = 50   // Nested groups, arguments, fences and environments
+)
+
+// mathML converts TeX to a <math> element; the source is kept as an annotation
+// (copy-paste, screen readers)
+func mathML(tex string, display bool) string {
+	var body strings.Builder
+	if len(tex) > maxMathSize {
+		body.WriteString(texError("formula too long"))
+	} else {
+		p := &texParser{src: tex}
+		body.WriteString(p.row(false))
+		for p.pos < len(p.src) { // A stray "}": skip it and go on
+			p.pos++
+			body.WriteString(p.row(false))
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("<math")
+	if display {
+		b.WriteString(` display="block"`)
+	}
+	b.WriteString("><semantics><mrow>")
+	b.WriteString(body.String())
+	b.WriteString(`</mrow><annotation encoding="application/x-tex">`)
+	b.WriteString(html.EscapeString(strings.TrimSpace(tex)))
+	b.WriteString("</annotation></semantics></math>")
+	return b.String()
+}
+
+var greek = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ", "varepsilon": "ε",
+	"zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ", "iota": "ι", "kappa": "κ",
+	"lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ", "pi": "π", "varpi": "ϖ", "rho": "ρ",
+	"sigma": "σ", "tau": "τ", "upsilon": "υ", "phi": "ϕ", "varphi": "φ", "chi": "χ",
+	"psi": "ψ", "omega": "ω",
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ", "Pi": "Π",
+	"Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+	// Not Greek, but identifiers too
+	"infty": "∞", "partial": "∂", "nabla": "∇", "emptyset": "∅", "varnothing": "∅",
+	"ell": "ℓ", "hbar": "ℏ",
+}
+
+var operators = map[string]string{
+	"le": "≤", "leq": "≤", "ge": "≥", "geq": "≥", "ne": "≠", "neq": "≠", "lt": "<", "gt": ">",
+	"ll": "≪", "gg": "≫", "approx": "≈", "equiv": "≡", "sim": "∼", "simeq": "≃", "cong": "≅",
+	"propto": "∝", "cdot": "⋅", "times": "×", "div": "÷", "pm": "±", "mp": "∓", "ast": "∗",
+	"star": "⋆", "circ": "∘", "bullet": "∙", "oplus": "⊕", "otimes": "⊗", "setminus": "∖",
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "gets": "←", "leftrightarrow": "↔",
+	"Rightarrow": "⇒", "Leftarrow": "⇐", "Leftrightarrow": "⇔", "implies": "⟹", "iff": "⟺",
+	"mapsto": "↦", "uparrow": "↑", "downarrow": "↓",
+	"in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂", "subseteq": "⊆", "supset": "⊃",
+	"supseteq": "⊇", "cup": "∪", "cap": "∩", "forall": "∀", "exists": "∃", "neg": "¬",
+	"lnot": "¬", "land": "∧", "wedge": "∧", "lor": "∨", "vee": "∨", "mid": "∣", "nmid": "∤",
+	"parallel": "∥", "perp": "⊥", "angle": "∠", "triangle": "△", "prime": "′",
+	"ldots": "…", "dots": "…", "cdots": "⋯", "vdots": "⋮", "ddots": "⋱",
+	"lfloor": "⌊", "rfloor": "⌋", "lceil": "⌈", "rceil": "⌉", "langle": "⟨", "rangle": "⟩",
+	"{": "{", "}": "}", "|": "‖", "vert": "|", "Vert": "‖", "lvert": "|", "rvert": "|",
+	"backslash": "∖", "colon": ":",
+	"%": "%", "#": "#", "$": "$", "&": "&", "_": "_",
+}
+
+// Operators whose scripts go above and below in display math
+var largeOperators = map[string]string{
+	"sum": "∑", "prod": "∏", "coprod": "∐", "bigcup": "⋃", "bigcap": "⋂",
+	"bigoplus": "⨁", "bigotimes": "⨂", "bigvee": "⋁", "bigwedge": "⋀",
+}
+
+// Upright function names; limits ones take scripts below like \sum
+var functions = map[string]bool{
+	"log": false, "ln": false, "lg": false, "exp": false, "sin": false, "cos": false,
+	"tan": false, "cot": false, "sec": false, "csc": false, "arcsin": false, "arccos": false,
+	"arctan": false, "sinh": false, "cosh": false, "tanh": false, "deg": false, "dim": false,
+	"gcd": true, "lcm": false, "det": true, "max": true, "min": true, "lim": true, "sup": true,
+	"inf": true, "argmax": true, "argmin": true, "arg": false, "hom": false, "ker": false,
+	"Pr": true,
+}
+
+var spaces = map[string]string{
+	",": "0.1667em", ":": "0.2222em", ">": "0.2222em", ";": "0.2778em", " ": "0.25em",
+	"quad": "1em", "qquad": "2em", "enspace": "0.5em", "thinspace": "0.1667em",
+}
+
+// Accents: the mark drawn over (or under) the argument
+var accents = map[string]struct {
+	mark  string
+	under bool
+}{
+	"overline": {"‾", false}, "bar": {"¯", false}, "hat": {"^", false}, "widehat": {"^", false},
+	"tilde": {"~", false}, "widetilde": {"~", false}, "vec": {"→", false},
+	"overrightarrow": {"→", false}, "dot": {"˙", false}, "ddot": {"¨", false},
+	"underline": {"_", true},
+}
+
+// Font commands on identifiers: mathvariant for single letters, or a style
+var fonts = map[string]string{
+	"mathrm": "normal", "mathup": "normal", "operatorname": "normal",
+	"mathbf": "bold", "boldsymbol": "bold", "mathit": "italic",
+	"mathbb": "double-struck", "mathcal": "script", "mathscr": "script",
+}
+
+// Environments: fences around the table and whether cells align left
+var environments = map[string]struct {
+	open, close string
+	left        bool
+}{
+	"matrix": {"", "", false}, "pmatrix": {"(", ")", false}, "bmatrix": {"[", "]", false},
+	"Bmatrix": {"{", "}", false}, "vmatrix": {"|", "|", false}, "Vmatrix": {"‖", "‖", false},
+	"cases": {"{", "", true}, "array": {"", "", false}, "aligned": {"", "", true},
+	"gathered": {"", "", false}, "align*": {"", "", true}, "smallmatrix": {"", "", false},
+}
+
+type texParser struct {
+	src    string
+	pos    int
+	fences int // Open \left: \right ends a row
+	envs   int // Open \begin: \end ends a row
+	depth  int // Nested atoms, capped at maxMathDepth
+}
+
+func (p *texParser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *texParser) skipSpace() {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\r\n", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// command reads the name after a backslash: letters, or one other character
+func (p *texParser) command() string {
+	p.pos++ // The backslash
+	start := p.pos
+	for p.pos < len(p.src) && isLetter(p.src[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start && p.pos < len(p.src) {
+		_, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		p.pos += size
+	}
+	return p.src[start:p.pos]
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// atEnd reports whether the current row ends here: "}", "\right", "\end", and
+// in tables also "&" and "\\"
+func (p *texParser) atEnd(inTable bool) bool {
+	rest := p.src[p.pos:]
+	switch {
+	case rest == "", rest[0] == '}':
+		return true
+	case p.fences > 0 && strings.HasPrefix(rest, `\right`) && !isLetterAt(rest, 6),
+		p.envs > 0 && strings.HasPrefix(rest, `\end`) && !isLetterAt(rest, 4):
+		return true
+	case inTable && (rest[0] == '&' || strings.HasPrefix(rest, `\\`)):
+		return true
+	}
+	return false
+}
+
+func isLetterAt(s string, i int) bool {
+	return i < len(s) && isLetter(s[i])
+}
+
+// row parses atoms with their scripts until the row ends
+func (p *texParser) row(inTable bool) string {
+	var b strings.Builder
+	for {
+		p.skipSpace()
+		if p.atEnd(inTable) {
+			return b.String()
+		}
+		base, limits := p.atom(inTable)
+		b.WriteString(p.scripts(base, limits))
+	}
+}
+
+// scripts attaches any ^ and _ (and primes) following base
+func (p *texParser) scripts(base string, limits bool) string {
+	var sub, sup, primes string
+	for {
+		p.skipSpace()
+		switch p.peek() {
+		case '_':
+			p.pos++
+			sub = p.arg()
+		case '^':
+			p.pos++
+			sup = p.arg()
+		case '\'':
+			for p.peek() == '\'' {
+				p.pos++
+				primes += "<mo>′</mo>"
+			}
+		default:
+			if primes != "" { // f'^2: primes come first in the superscript
+				sup = "<mrow>" + primes + sup + "</mrow>"
+			}
+			if base == "" && (sub != "" || sup != "") {
+				base = "<mrow></mrow>"
+			}
+			switch {
+			case sub != "" && sup != "":
+				return wrap(limits, "munderover", "msubsup", base+sub+sup)
+			case sub != "":
+				return wrap(limits, "munder", "msub", base+sub)
+			case sup != "":
+				return wrap(limits, "mover", "msup", base+sup)
+			}
+			return base
+		}
+	}
+}
+
+func wrap(limits bool, over, script, inner string) string {
+	tag := script
+	if limits {
+		tag = over
+	}
+	return "<" + tag + ">" + inner + "</" + tag + ">"
+}
+
+// arg parses a command argument or script: a {group} or a single atom (one
+// digit of a number, as TeX does: x^23 is x² 3)
+func (p *texParser) arg() string {
+	p.skipSpace()
+	switch c := p.peek(); {
+	case c == '{':
+		return "<mrow>" + p.group() + "</mrow>"
+	case c >= '0' && c <= '9':
+		p.pos++
+		return "<mn>" + string(c) + "</mn>"
+	case c == 0 || c == '}':
+		return "<mrow></mrow>"
+	}
+	a, _ := p.atom(false)
+	return a
+}
+
+// group parses {...} and returns its contents. An unclosed group ends where
+// its enclosing \left or \begin does.
+func (p *texParser) group() string {
+	p.pos++ // {
+	inner := p.row(false)
+	if p.peek() == '}' {
+		p.pos++
+	}
+	return inner
+}
+
+// rawGroup returns the unparsed text of {...} (for \text and \begin)
+func (p *texParser) rawGroup() string {
+	p.skipSpace()
+	if p.peek() != '{' {
+		return ""
+	}
+	depth := 0
+	start := p.pos + 1
+	for ; p.pos < len(p.src); p.pos++ {
+		switch p.src[p.pos] {
+		case '\\':
+			p.pos++
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				p.pos++
+				return p.src[start : p.pos-1]
+			}
+		}
+	}
+	p.pos = len(p.src) // Unclosed, maybe after a trailing backslash
+	return p.src[start:]
+}
+
+// optional returns the unparsed text of an optional [argument], if any
+func (p *texParser) optional() (string, bool) {
+	p.skipSpace()
+	if p.peek() != '[' {
+		return "", false
+	}
+	end := strings.IndexByte(p.src[p.pos:], ']')
+	if end < 0 {
+		return "", false
+	}
+	s := p.src[p.pos+1 : p.pos+end]
+	p.pos += end + 1
+	return s, true
+}
+
+func mo(s string) string { return "<mo>" + html.EscapeString(s) + "</mo>" }
+func mi(s string) string { return "<mi>" + html.EscapeString(s) + "</mi>" }
+
+func texError(s string) string {
+	return "<merror><mtext>" + html.EscapeString(s) + "</mtext></merror>"
+}
+
+// atom parses one element; limits reports a large operator. Every recursion
+// passes through here, so this is where nesting is capped: past maxMathDepth
+// the rest of the formula is dropped for an error box.
+func (p *texParser) atom(inTable bool) (string, bool) {
+	if p.depth >= maxMathDepth {
+		p.pos = len(p.src)
+		return texError("formula nested too deeply"), false
+	}
+	p.depth++
+	defer func() { p.depth-- }()
+	c := p.peek()
+	switch {
+	case c == '{':
+		return "<mrow>" + p.group() + "</mrow>", false
+	case c == '\\':
+		return p.commandAtom(inTable)
+	case c >= '0' && c <= '9' || c == '.' && p.pos+1 < len(p.src) && p.src[p.pos+1] >= '0' && p.src[p.pos+1] <= '9':
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' ||
+			p.src[p.pos] == '.' && p.pos+1 < len(p.src) && p.src[p.pos+1] >= '0' && p.src[p.pos+1] <= '9') {
+			p.pos++
+		}
+		return "<mn>" + p.src[start:p.pos] + "</mn>", false
+	case isLetter(c):
+		p.pos++
+		return mi(string(c)), false
+	case c == '&': // Outside a table: alignment marks mean nothing
+		p.pos++
+		return "", false
+	case c == '~':
+		p.pos++
+		return `<mspace width="0.25em"></mspace>`, false
+	case c == '-':
+		p.pos++
+		return mo("−"), false
+	case c == '\'':
+		p.pos++
+		return mo("′"), false
+	case c == '^' || c == '_': // Script with nothing before it
+		return "", false
+	}
+	r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+	p.pos += size
+	if unicode.IsLetter(r) {
+		return mi(string(r)), false
+	}
+	return mo(string(r)), false
+}
+
+func (p *texParser) commandAtom(inTable bool) (string, bool) {
+	start := p.pos
+	name := p.command()
+
+	if s, ok := greek[name]; ok {
+		return mi(s), false
+	}
+	if s, ok := operators[name]; ok {
+		return mo(s), false
+	}
+	if s, ok := largeOperators[name]; ok {
+		return mo(s), true
+	}
+	if limits, ok := functions[name]; ok {
+		if limits {
+			return `<mo movablelimits="true">` + name + "</mo>", true
+		}
+		return mi(name), false
+	}
+	if w, ok := spaces[name]; ok {
+		return `<mspace width="` + w + `"></mspace>`, false
+	}
+	if a, ok := accents[name]; ok {
+		inner := p.arg()
+		if a.under {
+			return "<munder>" + inner + `<mo stretchy="true">` + a.mark + "</mo></munder>", false
+		}
+		return "<mover accent=\"true\">" + inner + `<mo stretchy="true">` + a.mark + "</mo></mover>", false
+	}
+	if variant, ok := fonts[name]; ok {
+		return p.font(variant), false
+	}
+
+	switch name {
+	case "!", "displaystyle", "textstyle", "limits", "nolimits", "big", "Big", "bigg", "Bigg",
+		"bigl", "bigr", "Bigl", "Bigr", "biggl", "biggr", "Biggl", "Biggr":
+		return "", false // Sizes and styles: the browser picks them
+	case `\`, "newline", "cr": // Line break outside a table
+		return "", false
+	case "int", "iint", "oint":
+		return mo(map[string]string{"int": "∫", "iint": "∬", "oint": "∮"}[name]), false
+	case "frac", "dfrac", "tfrac", "cfrac":
+		num := p.arg()
+		return "<mfrac>" + num + p.arg() + "</mfrac>", false
+	case "binom", "dbinom", "tbinom":
+		n := p.arg()
+		return `<mrow><mo>(</mo><mfrac linethickness="0">` + n + p.arg() + "</mfrac><mo>)</mo></mrow>", false
+	case "sqrt":
+		if index, ok := p.optional(); ok {
+			radicand := p.arg()
+			return "<mroot>" + radicand + "<mrow>" + (&texParser{src: index, depth: p.depth}).row(false) + "</mrow></mroot>", false
+		}
+		return "<msqrt>" + p.arg() + "</msqrt>", false
+	case "text", "textrm", "textnormal", "mbox", "textit", "textbf", "texttt", "textup":
+		return "<mtext>" + html.EscapeString(strings.ReplaceAll(p.rawGroup(), `\ `, " ")) + "</mtext>", false
+	case "bmod", "mod":
+		return `<mo lspace="0.2222em" rspace="0.2222em">mod</mo>`, false
+	case "pmod":
+		return `<mrow><mspace width="0.5em"></mspace><mo>(</mo><mi>mod</mi><mspace width="0.2222em"></mspace>` + p.arg() + "<mo>)</mo></mrow>", false
+	case "not":
+		p.skipSpace()
+		next, _ := p.atom(inTable)
+		op, ok := strings.CutPrefix(next, "<mo>")
+		if !ok {
+			return next, false
+		}
+		op = html.UnescapeString(strings.TrimSuffix(op, "</mo>"))
+		if negated, ok := negations[op]; ok {
+			return mo(negated), false
+		}
+		return mo(op + "\u0338"), false // Combining long solidus overlay
+	case "left":
+		return p.fenced(), false
+	case "right": // Without \left
+		return p.delimiter(), false
+	case "begin":
+		return p.environment(), false
+	case "end": // Without \begin
+		p.rawGroup()
+		return "", false
+	}
+	return texError(p.src[start:p.pos]), false
+}
+
+// Operators with a precomposed \not form
+var negations = map[string]string{
+	"=": "≠", "<": "≮", ">": "≯", "≤": "≰", "≥": "≱", "∈": "∉", "≡": "≢", "⊂": "⊄",
+	"⊆": "⊈", "∼": "≁", "≈": "≉", "∣": "∤", "∥": "∦",
+}
+
+// font applies a font command to its argument
+func (p *texParser) font(variant string) string {
+	p.skipSpace()
+	raw := ""
+	switch c := p.peek(); {
+	case c == '{':
+		raw = p.rawGroup()
+	case isLetter(c): // \mathbb R
+		raw = string(c)
+		p.pos++
+	default:
+		return p.arg()
+	}
+	if variant == "double-struck" || variant == "script" {
+		var b strings.Builder
+		for _, r := range raw {
+			if r != ' ' {
+				b.WriteString(mi(styledLetter(r, variant)))
+			}
+		}
+		return "<mrow>" + b.String() + "</mrow>"
+	}
+	inner := (&texParser{src: raw, depth: p.depth}).row(false)
+	if variant == "normal" && strings.Count(inner, "<mi>") > 1 && !strings.ContainsAny(raw, `\{^_`) {
+		return mi(raw) // \operatorname{rank}: one upright word
+	}
+	switch variant {
+	case "normal":
+		return "<mrow>" + strings.ReplaceAll(inner, "<mi>", `<mi mathvariant="normal">`) + "</mrow>"
+	case "bold":
+		return `<mrow style="font-weight: bold">` + inner + "</mrow>"
+	}
+	return "<mrow>" + inner + "</mrow>"
+}
+
+// Letters of the Mathematical Alphanumeric Symbols block that live elsewhere
+var letterHoles = map[string]map[rune]rune{
+	"double-struck": {'C': 'ℂ', 'H': 'ℍ', 'N': 'ℕ', 'P': 'ℙ', 'Q': 'ℚ', 'R': 'ℝ', 'Z': 'ℤ'},
+	"script": {'B': 'ℬ', 'E': 'ℰ', 'F': 'ℱ', 'H': 'ℋ', 'I': 'ℐ', 'L': 'ℒ', 'M': 'ℳ', 'R': 'ℛ',
+		'e': 'ℯ', 'g': 'ℊ', 'o': 'ℴ'},
+}
+
+// styledLetter maps A-Z and a-z to their double-struck or script forms
+func styledLetter(r rune, variant string) string {
+	if h, ok := letterHoles[variant][r]; ok {
+		return string(h)
+	}
+	base := map[string]rune{"double-struck": 0x1D538, "script": 0x1D49C}[variant]
+	switch {
+	case r >= 'A' && r <= 'Z':
+		return string(base + r - 'A')
+	case r >= 'a' && r <= 'z':
+		return string(base + 26 + r - 'a')
+	}
+	return string(r)
+}
+
+// delimiter reads the fence after \left, \right or \big ("." is none)
+func (p *texParser) delimiter() string {
+	p.skipSpace()
+	var s string
+	switch c := p.peek(); {
+	case c == 0:
+		return ""
+	case c == '\\':
+		s = operators[p.command()]
+	default:
+		_, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		s = p.src[p.pos : p.pos+size]
+		p.pos += size
+	}
+	if s == "" || s == "." {
+		return ""
+	}
+	return `<mo fence="true" stretchy="true">` + html.EscapeString(s) + "</mo>"
+}
+
+// fenced parses \left( ... \right) into a row whose fences stretch
+func (p *texParser) fenced() string {
+	open := p.delimiter()
+	p.fences++
+	inner := p.row(false)
+	p.fences--
+	closing := ""
+	if strings.HasPrefix(p.src[p.pos:], `\right`) {
+		p.pos += len(`\right`)
+		closing = p.delimiter()
+	}
+	return "<mrow>" + open + inner + closing + "</mrow>"
+}
+
+// environment parses \begin{name} ... \end{name} into a table
+func (p *texParser) environment() string {
+	name := p.rawGroup()
+	env, ok := environments[name]
+	if !ok {
+		return texError(`\begin{` + name + `}`)
+	}
+	if name == "array" {
+		p.rawGroup() // Column spec
+	}
+
+	cell := "<mtd>"
+	if env.left {
+		cell = `<mtd style="text-align: left">`
+	}
+	p.envs++
+	defer func() { p.envs-- }()
+	var table strings.Builder
+	table.WriteString("<mtable>")
+	for {
+		table.WriteString("<mtr>")
+		for {
+			table.WriteString(cell + p.row(true) + "</mtd>")
+			if p.peek() != '&' {
+				break
+			}
+			p.pos++
+		}
+		table.WriteString("</mtr>")
+		if !strings.HasPrefix(p.src[p.pos:], `\\`) {
+			break
+		}
+		p.pos += 2
+	}
+	table.WriteString("</mtable>")
+
+	if strings.HasPrefix(p.src[p.pos:], `\end`) {
+		p.pos += len(`\end`)
+		p.rawGroup()
+	} // Else unclosed: the enclosing group or \left ends here
+
+	out := table.String()
+	if env.open != "" || env.close != "" {
+		out = "<mrow>" + fence(env.open) + out + fence(env.close) + "</mrow>"
+	}
+	return out
+}
+
+func fence(s string) string {
+	if s == "" {
+		return ""
+	}
+	return `<mo fence="true" stretchy="true">` + s + "</mo>"
+}
@@ -0,0 +1,122 @@
+package statement
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMathML(t *testing.T) {
+	tests := []struct {
+		tex, want string // want: the MathML inside the outer <mrow>
+	}{
+		{`1 \le n \le 10^5`, `<mn>1</mn><mo>≤</mo><mi>n</mi><mo>≤</mo><msup><mn>10</mn><mn>5</mn></msup>`},
+		{`x_{i,j}^2`, `<msubsup><mi>x</mi><mrow><mi>i</mi><mo>,</mo><mi>j</mi></mrow><mn>2</mn></msubsup>`},
+		{`x^23`, `<msup><mi>x</mi><mn>2</mn></msup><mn>3</mn>`},
+		{`\frac{a}{2}`, `<mfrac><mrow><mi>a</mi></mrow><mrow><mn>2</mn></mrow></mfrac>`},
+		{`\sqrt[3]{x}`, `<mroot><mrow><mi>x</mi></mrow><mrow><mn>3</mn></mrow></mroot>`},
+		{`\sum_{i=1}^n a_i`, `<munderover><mo>∑</mo><mrow><mi>i</mi><mo>=</mo><mn>1</mn></mrow><mi>n</mi></munderover><msub><mi>a</mi><mi>i</mi></msub>`},
+		{`\left\lfloor x \right\rfloor`, `<mrow><mo fence="true" stretchy="true">⌊</mo><mi>x</mi><mo fence="true" stretchy="true">⌋</mo></mrow>`},
+		{`\left( x \rightarrow y \right.`, `<mrow><mo fence="true" stretchy="true">(</mo><mi>x</mi><mo>→</mo><mi>y</mi></mrow>`},
+		{`\text{if } a < b`, `<mtext>if </mtext><mi>a</mi><mo>&lt;</mo><mi>b</mi>`},
+		{`\mathbb{N} \mathbb Z`, `<mrow><mi>ℕ</mi></mrow><mrow><mi>ℤ</mi></mrow>`},
+		{`a \not= b`, `<mi>a</mi><mo>≠</mo><mi>b</mi>`},
+		{`\operatorname{lcm}`, `<mi>lcm</mi>`},
+		{`f'(x)`, `<msup><mi>f</mi><mrow><mo>′</mo></mrow></msup><mo>(</mo><mi>x</mi><mo>)</mo>`},
+		{`\begin{pmatrix} a & b \\ c & d \end{pmatrix}`, `<mrow><mo fence="true" stretchy="true">(</mo><mtable>` +
+			`<mtr><mtd><mi>a</mi></mtd><mtd><mi>b</mi></mtd></mtr><mtr><mtd><mi>c</mi></mtd><mtd><mi>d</mi></mtd></mtr>` +
+			`</mtable><mo fence="true" stretchy="true">)</mo></mrow>`},
+		{`\unknown x`, `<merror><mtext>\unknown</mtext></merror><mi>x</mi>`},
+		{`\begin{tikz} x`, `<merror><mtext>\begin{tikz}</mtext></merror><mi>x</mi>`},
+		{`} x {`, `<mi>x</mi><mrow></mrow>`}, // Unbalanced braces do not stop the rest
+		{`\text{a\`, `<mtext>a\</mtext>`},
+		{`<script>`, `<mo>&lt;</mo><mi>s</mi><mi>c</mi><mi>r</mi><mi>i</mi><mi>p</mi><mi>t</mi><mo>&gt;</mo>`},
+	}
+	for _, tt := range tests {
+		got := mathML(tt.tex, false)
+		want := `<math><semantics><mrow>` + tt.want + `</mrow>`
+		if !strings.HasPrefix(got, want) {
+			t.Errorf("mathML(%q)\n got %s\nwant %s...", tt.tex, got, want)
+		}
+	}
+}
+
+func TestMathMLLimits(t *testing.T) {
+	tests := []struct {
+		tex, want string
+	}{
+		{strings.Repeat("x", maxMathSize+1), `<merror><mtext>formula too long</mtext></merror>`},
+		{strings.Repeat("{", 1000), `<merror><mtext>formula nested too deeply</mtext></merror>`},
+		{strings.Repeat(`\frac`, 500), `<merror><mtext>formula nested too deeply</mtext></merror>`},
+		{strings.Repeat(`\mathrm{`, 500), `<merror><mtext>formula nested too deeply</mtext></merror>`},
+		{strings.Repeat("{", maxMathDepth-1) + "x", `<mi>x</mi>`},
+	}
+	for _, tt := range tests {
+		got := mathML(tt.tex, false)
+		if !strings.Contains(got, tt.want) || !balanced(got) {
+			t.Errorf("mathML(%.20q...) = %.200s..., want %s", tt.tex, got, tt.want)
+		}
+	}
+}
+
+func FuzzMathML(f *testing.F) {
+	for _, tex := range []string{
+		`1 \le n \le 10^5`, `x_{i,j}^2`, `\frac{a}{2}`, `\sqrt[3]{x}`, `\left( x \right.`,
+		`\text{if } a < b`, `\mathbb{N}`, `\not=`, `\operatorname{lcm}`, `f'^2`,
+		`\begin{cases} a & b \\ c \end{cases}`, `\begin{array}{cc} 1 \end{array}`, `} x {`,
+	} {
+		f.Add(tex)
+	}
+	f.Fuzz(func(t *testing.T, tex string) {
+		got := mathML(tex, false)
+		if !balanced(got) {
+			t.Errorf("mathML(%q) is not balanced: %s", tex, got)
+		}
+		if len(got) > 100*len(tex)+1000 {
+			t.Errorf("mathML(%q) is %d bytes", tex, len(got))
+		}
+	})
+}
+
+// balanced reports whether every tag in s is closed in order (text is escaped,
+// so any "<" starts a tag)
+func balanced(s string) bool {
+	var open []string
+	for {
+		i := strings.IndexByte(s, '<')
+		if i < 0 {
+			return len(open) == 0
+		}
+		j := strings.IndexByte(s[i:], '>')
+		if j < 0 {
+			return false
+		}
+		tag := s[i+1 : i+j]
+		s = s[i+j+1:]
+		if name, ok := strings.CutPrefix(tag, "/"); ok {
+			if len(open) == 0 || open[len(open)-1] != name {
+				return false
+			}
+			open = open[:len(open)-1]
+		} else {
+			name, _, _ = strings.Cut(tag, " ")
+			open = append(open, name)
+		}
+	}
+}
+
+func TestRenderMath(t *testing.T) {
+	md, err := renderMarkdown([]byte("Print $a+b$ where\n\n$$a, b \\le 10^9$$\n\nCode: `$x$`\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(md, `<p>Print <math><semantics>`) || !strings.Contains(md, "\n<math display=\"block\">") ||
+		!strings.Contains(md, "<code>$x$</code>") || strings.Contains(md, "MATHPLACEHOLDER") {
+		t.Errorf("renderMarkdown = %s", md)
+	}
+
+	h := renderHTMLMath(`<p>If \(a &lt; b\):</p><pre>\(kept\)</pre>\[x\]`)
+	want := `<p>If ` + mathML("a < b", false) + `:</p><pre>\(kept\)</pre>` + mathML("x", true)
+	if h != want {
+		t.Errorf("renderHTMLMath =\n %s\nwant\n %s", h, want)
+	}
+}
@@ -0,0 +1,227 @@
+// Package statement renders problem statements authored as Markdown (with
+// LaTeX math) or HTML into the problem page.
+package statement
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	goldhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// --- Layout ---
+// storage/problems/[id]/statement/statement.md    Markdown, $inline$ and $$display$$ math
+// storage/problems/[id]/statement/statement.html  Or a ready HTML fragment
+// storage/problems/[id]/statement/*               Images etc., referenced by relative path
+//
+// Math is kept out of the Markdown parser and converted to MathML (mathml.go),
+// which browsers render without scripts or fonts from elsewhere. HTML statements
+// may use \( \) and \[ \] for math.
+
+var ErrNoStatement = errors.New("no statement")
+
+var md = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithRendererOptions(goldhtml.WithUnsafe()), // Authors are admins; allows raw HTML blocks
+)
+
+type cached struct {
+	modTime time.Time
+	html    template.HTML
+}
+
+var (
+	cache    = make(map[int]cached)
+	cacheMux sync.Mutex
+)
+
+func Dir(problemID int) string {
+	return filepath.Join("storage", "problems", strconv.Itoa(problemID), "statement")
+}
+
+// source finds the statement file; Markdown wins over HTML
+func source(problemID int) (string, os.FileInfo, error) {
+	for _, name := range []string{"statement.md", "statement.html"} {
+		path := filepath.Join(Dir(problemID), name)
+		if info, err := os.Stat(path); err == nil {
+			return path, info, nil
+		}
+	}
+	return "", nil, ErrNoStatement
+}
+
+// Render returns the statement HTML (ErrNoStatement if there is none).
+// Results are cached until the source file changes.
+func Render(problemID int) (template.HTML, error) {
+	path, info, err := source(problemID)
+	if err != nil {
+		return "", err
+	}
+
+	cacheMux.Lock()
+	c, ok := cache[problemID]
+	cacheMux.Unlock()
+	if ok && c.modTime.Equal(info.ModTime()) {
+		return c.html, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	var out string
+	if filepath.Ext(path) == ".md" {
+		out, err = renderMarkdown(raw)
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", path, err)
+		}
+	} else {
+		out = renderHTMLMath(string(raw))
+	}
+	rendered := template.HTML(rewriteRelativeURLs(out, problemID))
+
+	cacheMux.Lock()
+	cache[problemID] = cached{modTime: info.ModTime(), html: rendered}
+	cacheMux.Unlock()
+	return rendered, nil
+}
+
+// AssetPath maps /problems/[id]/statement/<name> to a file, refusing paths outside the directory
+func AssetPath(problemID int, name string) (string, error) {
+	dir := Dir(problemID)
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if !strings.HasPrefix(path, dir+string(os.PathSeparator)) {
+		return "", os.ErrNotExist
+	}
+	if base := filepath.Base(path); base == "statement.md" || base == "statement.html" {
+		return "", os.ErrNotExist // Sources are rendered, not served
+	}
+	return path, nil
+}
+
+// --- Math ---
+
+var mathPattern = regexp.MustCompile(`(?s)\$\$(.+?)\$\$|\$([^$\n]+?)\$`)
+
+// renderMarkdown swaps math for placeholders, renders, then restores the math.
+// Fenced and inline code are left untouched.
+func renderMarkdown(src []byte) (string, error) {
+	var math []string
+	protected := transformOutsideCode(string(src), func(text string) string {
+		return mathPattern.ReplaceAllStringFunc(text, func(m string) string {
+			math = append(math, m)
+			return fmt.Sprintf("MATHPLACEHOLDER%dX", len(math)-1)
+		})
+	})
+
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(protected), &buf); err != nil {
+		return "", err
+	}
+
+	out := buf.String()
+	for i := len(math) - 1; i >= 0; i-- { // Reverse: "...1X" must not match inside "...10X"
+		ph := fmt.Sprintf("MATHPLACEHOLDER%dX", i)
+		m := math[i]
+		if strings.HasPrefix(m, "$$") {
+			block := mathML(m[2:len(m)-2], true)
+			out = strings.ReplaceAll(out, "<p>"+ph+"</p>", block) // Display math alone in a paragraph
+			out = strings.ReplaceAll(out, ph, block)
+		} else {
+			out = strings.ReplaceAll(out, ph, mathML(m[1:len(m)-1], false))
+		}
+	}
+	return out, nil
+}
+
+var (
+	htmlMathPattern = regexp.MustCompile(`(?s)\\\[(.+?)\\\]|\\\((.+?)\\\)`)
+	htmlCodePattern = regexp.MustCompile(`(?is)<(pre|code)[\s>].*?</(pre|code)>`)
+)
+
+// renderHTMLMath converts \( \) and \[ \] math in an HTML statement, outside
+// <pre> and <code>
+func renderHTMLMath(src string) string {
+	var out strings.Builder
+	convert := func(text string) {
+		out.WriteString(htmlMathPattern.ReplaceAllStringFunc(text, func(m string) string {
+			tex := html.UnescapeString(m[2 : len(m)-2]) // The TeX is HTML text: &lt; is <
+			return mathML(tex, m[1] == '[')
+		}))
+	}
+
+	last := 0
+	for _, loc := range htmlCodePattern.FindAllStringIndex(src, -1) {
+		convert(src[last:loc[0]])
+		out.WriteString(src[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	convert(src[last:])
+	return out.String()
+}
+
+// transformOutsideCode applies fn to the text outside ``` fences and `code spans`
+func transformOutsideCode(src string, fn func(string) string) string {
+	var out strings.Builder
+	var text strings.Builder
+	flush := func() {
+		out.WriteString(fn(text.String()))
+		text.Reset()
+	}
+
+	inFence := false
+	for _, line := range strings.SplitAfter(src, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			if !inFence {
+				flush()
+			}
+			inFence = !inFence
+			out.WriteString(line)
+			continue
+		}
+		if inFence {
+			out.WriteString(line)
+			continue
+		}
+
+		// Inline code: odd-numbered segments between backticks stay verbatim
+		parts := strings.Split(line, "`")
+		if len(parts)%2 == 0 {
+			text.WriteString(line) // Unbalanced backtick: treat as text
+			continue
+		}
+		for i, p := range parts {
+			if i%2 == 1 {
+				flush()
+				out.WriteString("`" + p + "`")
+			} else {
+				text.WriteString(p)
+			}
+		}
+	}
+	flush()
+	return out.String()
+}
+
+// --- Links ---
+
+var relativeURL = regexp.MustCompile(`(src|href)="([^"#/:][^":]*)"`)
+
+// rewriteRelativeURLs points relative image/link targets at the statement directory
+func rewriteRelativeURLs(s string, problemID int) string {
+	prefix := "/problems/" + strconv.Itoa(problemID) + "/statement/"
+	return relativeURL.ReplaceAllString(s, `$1="`+prefix+`$2"`)
+}
@@ -2,30 +2,57 @@ package tasks
 
 import (
 	"crypto/rand"
+	"database/sql"
 	"encoding/hex"
 	"fmt"
 	"log"
+	"os"
+	"text/tabwriter"
 
 	"golang.org/x/crypto/bcrypt"
+	"github.com/ifuaslaerl/Judge/internal/audit"
+	"github.com/ifuaslaerl/Judge/internal/auth"
 	"github.com/ifuaslaerl/Judge/internal/data"
 )
 
-// AddUser generates a random user and prints credentials to stdout
-func AddUser() {
-	// 1. Generate Random Credentials
-	// 3 bytes = 6 hex characters
-	userBytes := make([]byte, 3)
-	passBytes := make([]byte, 3)
-
-	if _, err := rand.Read(userBytes); err != nil {
+// randomHex returns n random bytes as hex (2n characters)
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
 		log.Fatalf("Failed to generate random bytes: %v", err)
 	}
-	if _, err := rand.Read(passBytes); err != nil {
-		log.Fatalf("Failed to generate random bytes: %v", err)
+	return hex.EncodeToString(b)
+}
+
+func printCredentials(title, username, password string) {
+	fmt.Println("========================================")
+	fmt.Printf("       %s\n", title)
+	fmt.Println("========================================")
+	fmt.Printf(" Username : %s\n", username)
+	fmt.Printf(" Password : %s\n", password)
+	fmt.Println("========================================")
+}
+
+// AddUser generates a random user and prints credentials to stdout.
+// Admins (judges) can open judge-only pages and exports.
+// Usage: user add [-admin]
+func AddUser(args []string) {
+	fs := newFlagSet("user add", "[-admin]")
+	isAdmin := fs.Bool("admin", false, "Create an admin (judge) instead of a contestant")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
 	}
 
-	username := "user_" + hex.EncodeToString(userBytes)
-	password := hex.EncodeToString(passBytes)
+	// 1. Generate Random Credentials
+	// 3 bytes = 6 hex characters
+	prefix := "user_"
+	if *isAdmin {
+		prefix = "admin_"
+	}
+	username := prefix + randomHex(3)
+	password := randomHex(3)
 
 	// 2. Hash Password
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -35,17 +62,235 @@ func AddUser() {
 
 	// 3. Insert into Database
 	// We set display_name = username by default
-	query := `INSERT INTO users (username, password_hash, display_name) VALUES (?, ?, ?)`
-	_, err = data.DB.Exec(query, username, string(hash), username)
+	query := `INSERT INTO users (username, password_hash, display_name, is_admin) VALUES (?, ?, ?, ?)`
+	_, err = data.DB.Exec(query, username, string(hash), username, *isAdmin)
 	if err != nil {
 		log.Fatalf("DB Error: Failed to create user: %v", err)
 	}
 
+	role := "user"
+	if *isAdmin {
+		role = "admin"
+	}
+	logCLI(audit.UserCreate, username, role)
+
 	// 4. Output to Console
-	fmt.Println("========================================")
-	fmt.Println("       NEW USER ACCOUNT CREATED")
-	fmt.Println("========================================")
-	fmt.Printf(" Username : %s\n", username)
-	fmt.Printf(" Password : %s\n", password)
-	fmt.Println("========================================")
+	if *isAdmin {
+		printCredentials("NEW ADMIN ACCOUNT CREATED", username, password)
+	} else {
+		printCredentials("NEW USER ACCOUNT CREATED", username, password)
+	}
+}
+
+// ListUsers prints every account with its role and submission count
+// Usage: user list
+func ListUsers(args []string) {
+	fs := newFlagSet("user list", "")
+	fs.Parse(args)
+
+	rows, err := data.DB.Query(`
+		SELECT u.id, u.username, u.display_name, u.is_admin, COUNT(s.id)
+		FROM users u
+		LEFT JOIN submissions s ON s.user_id = u.id
+		GROUP BY u.id
+		ORDER BY u.id`)
+	if err != nil {
+		log.Fatalf("DB Error: %v", err)
+	}
+	defer rows.Close()
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tUSERNAME\tDISPLAY NAME\tROLE\tSUBMISSIONS")
+	for rows.Next() {
+		var id, count int
+		var username, display string
+		var isAdmin bool
+		if err := rows.Scan(&id, &username, &display, &isAdmin, &count); err != nil {
+			log.Fatalf("DB Error: %v", err)
+		}
+		role := "user"
+		if isAdmin {
+			role = "admin"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d\n", id, username, display, role, count)
+	}
+	tw.Flush()
+}
+
+// lookupUser resolves a username or exits
+func lookupUser(username string) int {
+	var id int
+	err := data.DB.QueryRow("SELECT id FROM users WHERE username = ?", username).Scan(&id)
+	if err == sql.ErrNoRows {
+		log.Fatalf("No user named %q", username)
+	} else if err != nil {
+		log.Fatalf("DB Error: %v", err)
+	}
+	return id
+}
+
+// ResetUser sets a new random password and logs the user out everywhere
+// (sessions and API tokens are revoked).
+// Usage: user reset <username>
+func ResetUser(args []string) {
+	fs := newFlagSet("user reset", "<username>")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	username := fs.Arg(0)
+	id := lookupUser(username)
+
+	password := randomHex(3)
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Fatalf("Failed to hash password: %v", err)
+	}
+
+	err = execAll([]query{
+		{"UPDATE users SET password_hash = ? WHERE id = ?", []interface{}{string(hash), id}},
+		{"DELETE FROM sessions WHERE user_id = ?", []interface{}{id}},
+		{"DELETE FROM api_tokens WHERE user_id = ?", []interface{}{id}},
+	})
+	if err != nil {
+		log.Fatalf("DB Error: %v", err)
+	}
+
+	logCLI(audit.PasswordReset, username, "sessions and API tokens revoked")
+	printCredentials("PASSWORD RESET", username, password)
+}
+
+// DeleteUser removes an account with its sessions, tokens and submissions
+// (database rows and source files).
+// Usage: user delete [-yes] <username>
+func DeleteUser(args []string) {
+	fs := newFlagSet("user delete", "[-yes] <username>")
+	yes := fs.Bool("yes", false, "Do not ask for confirmation")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	username := fs.Arg(0)
+	id := lookupUser(username)
+
+	var files []string
+	rows, err := data.DB.Query("SELECT file_path FROM submissions WHERE user_id = ? AND file_path != ''", id)
+	if err != nil {
+		log.Fatalf("DB Error: %v", err)
+	}
+	for rows.Next() {
+		var f string
+		if rows.Scan(&f) == nil {
+			files = append(files, f)
+		}
+	}
+	rows.Close()
+
+	if !confirm(fmt.Sprintf("Delete user %s and their %d submissions?", username, len(files)), *yes) {
+		log.Fatal("Aborted.")
+	}
+
+	// Child rows first: foreign keys are not enforced, so nothing cascades
+	subs := "SELECT id FROM submissions WHERE user_id = ?"
+	err = execAll([]query{
+		{"DELETE FROM balloons WHERE submission_id IN (" + subs + ")", []interface{}{id}},
+		{"DELETE FROM submission_tests WHERE submission_id IN (" + subs + ")", []interface{}{id}},
+		{"DELETE FROM submissions WHERE user_id = ?", []interface{}{id}},
+		{"DELETE FROM api_tokens WHERE user_id = ?", []interface{}{id}},
+		{"DELETE FROM sessions WHERE user_id = ?", []interface{}{id}},
+		{"DELETE FROM users WHERE id = ?", []interface{}{id}},
+	})
+	if err != nil {
+		log.Fatalf("DB Error: %v", err)
+	}
+
+	// Leftover binaries are removed by the reaper on the next boot
+	for _, f := range files {
+		os.Remove(f)
+	}
+
+	logCLI(audit.UserDelete, username, fmt.Sprintf("%d submissions", len(files)))
+	log.Printf("SUCCESS: Deleted user %s and %d submissions. A running server refreshes its standings within a few seconds.", username, len(files))
+}
+
+type query struct {
+	sql  string
+	args []interface{}
+}
+
+// execAll runs the statements in one transaction
+func execAll(queries []query) error {
+	tx, err := data.DB.Begin()
+	if err != nil {
+		return err
+	}
+	for _, q := range queries {
+		if _, err := tx.Exec(q.sql, q.args...); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s: %v", q.sql, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// FlushSessions logs every user out of the web interface (API tokens stay valid)
+// Usage: sessions flush
+func FlushSessions(args []string) {
+	fs := newFlagSet("sessions flush", "")
+	fs.Parse(args)
+
+	auth.NewService(data.NewSQLiteStores(data.DB)).FlushSessions()
+	logCLI(audit.FlushSessions, "", "")
+}
+
+// Migrate applies pending schema migrations. The server does the same at
+// startup; this command is for upgrading ahead of time or from scripts.
+// Usage: migrate
+func Migrate(args []string) {
+	fs := newFlagSet("migrate", "")
+	fs.Parse(args)
+
+	applied, err := data.Migrate()
+	for _, m := range applied {
+		log.Printf("Applied %s", m)
+	}
+	if err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+	if len(applied) == 0 {
+		log.Println("Database schema is already up to date.")
+	} else {
+		log.Printf("SUCCESS: Applied %d migrations.", len(applied))
+	}
+}
+
+// MigrateStatus lists every migration and when it was applied
+// Usage: migrate status
+func MigrateStatus(args []string) {
+	fs := newFlagSet("migrate status", "")
+	fs.Parse(args)
+
+	states, err := data.MigrationStatus()
+	if err != nil {
+		log.Fatalf("DB Error: %v", err)
+	}
+
+	pending := 0
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED (UTC)")
+	for _, s := range states {
+		at := "pending"
+		if s.Applied {
+			at = s.AppliedAt.Format("2006-01-02 15:04:05")
+		} else {
+			pending++
+		}
+		fmt.Fprintf(tw, "%04d\t%s\t%s\n", s.Version, s.Name, at)
+	}
+	tw.Flush()
+	if pending > 0 {
+		fmt.Printf("%d pending; run \"judge migrate\" or start the server to apply.\n", pending)
+	}
 }
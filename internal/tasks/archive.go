@@ -0,0 +1,62 @@
+package tasks
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/ifuaslaerl/Judge/internal/archive"
+	"github.com/ifuaslaerl/Judge/internal/audit"
+)
+
+// ExportArchive bundles problems (and optionally users and submissions) into one zip
+// Usage: export [-o contest.zip] [-problems A,B] [-users] [-submissions]
+func ExportArchive(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	out := fs.String("o", "contest.zip", "Output archive")
+	problems := fs.String("problems", "", "Comma-separated problem letters (default: all)")
+	users := fs.Bool("users", false, "Include users (hashed passwords)")
+	submissions := fs.Bool("submissions", false, "Include submissions and their results (implies -users)")
+	fs.Parse(args)
+
+	opts := archive.ExportOptions{Users: *users, Submissions: *submissions}
+	if *problems != "" {
+		for _, l := range strings.Split(*problems, ",") {
+			opts.Letters = append(opts.Letters, strings.TrimSpace(l))
+		}
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatalf("Failed to create %s: %v", *out, err)
+	}
+	m, err := archive.Export(f, opts)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(*out)
+		log.Fatalf("Export failed: %v", err)
+	}
+	log.Printf("SUCCESS: Exported %d problems, %d users, %d submissions to %s",
+		len(m.Problems), len(m.Users), len(m.Submissions), *out)
+}
+
+// ImportArchive restores an archive made by ExportArchive into an empty server
+// Usage: import <contest.zip>
+func ImportArchive(args []string) {
+	if len(args) != 1 {
+		log.Fatal("Usage: import <contest.zip>")
+	}
+
+	m, err := archive.Import(args[0])
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+	logCLI(audit.ArchiveImport, args[0], fmt.Sprintf("%d problems, %d users, %d submissions",
+		len(m.Problems), len(m.Users), len(m.Submissions)))
+	log.Printf("SUCCESS: Restored %d problems, %d users, %d submissions from %s",
+		len(m.Problems), len(m.Users), len(m.Submissions), args[0])
+}
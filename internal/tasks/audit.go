@@ -0,0 +1,43 @@
+package tasks
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ifuaslaerl/Judge/internal/audit"
+	"github.com/ifuaslaerl/Judge/internal/data"
+)
+
+// ShowAuditLog prints audit log entries, newest first
+// Usage: audit-log [-actor name] [-action login_failed] [-since 24h | 2006-01-02] [-n 50]
+func ShowAuditLog(args []string) {
+	fs := flag.NewFlagSet("audit-log", flag.ExitOnError)
+	actor := fs.String("actor", "", "Only entries by this actor (username or \"cli\")")
+	action := fs.String("action", "", "Only this action (e.g. login_failed, wipe)")
+	since := fs.String("since", "", "Only entries newer than a duration (24h) or a UTC date (2006-01-02)")
+	limit := fs.Int("n", 50, "Maximum number of entries")
+	fs.Parse(args)
+
+	f := data.AuditFilter{Actor: *actor, Action: *action, Limit: *limit}
+	if *since != "" {
+		var err error
+		if f.Since, err = audit.ParseSince(*since); err != nil {
+			log.Fatalf("Invalid -since: %v", err)
+		}
+	}
+
+	entries, err := data.NewSQLiteStores(data.DB).Audit.AuditEntries(f)
+	if err != nil {
+		log.Fatalf("DB Error: %v", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME (UTC)\tACTOR\tACTION\tTARGET\tDETAILS")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", e.Time.Format("2006-01-02 15:04:05"), e.Actor, e.Action, e.Target, e.Details)
+	}
+	tw.Flush()
+}
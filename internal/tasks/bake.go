@@ -7,13 +7,20 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strconv"
+
+	"github.com/ifuaslaerl/Judge/internal/audit"
 )
 
 // BakeTests generates .in and .out files for a problem
-// Usage: --bake [problemID] [seed] [count]
+// Usage: bake [problemID] [seed] [count]
+//        bake [problemID]               (runs storage/problems/[id]/testplan.txt, see testplan.go)
 func BakeTests(args []string) {
+	if len(args) == 1 {
+		BakePlan(args[0])
+		return
+	}
 	if len(args) < 3 {
-		log.Fatal("Usage: --bake [problemID] [seed] [count]")
+		log.Fatal("Usage: bake [problemID] [seed] [count]  or  bake [problemID] (with testplan.txt)")
 	}
 
 	idStr := args[0]
@@ -35,6 +42,16 @@ func BakeTests(args []string) {
 		log.Fatalf("Missing solution.cpp in %s", baseDir)
 	}
 
+	// Optional input validator (validator.py / validator.cpp)
+	val, err := loadValidator(baseDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if val != nil {
+		defer val.cleanup()
+		log.Println("Validator found: every generated input will be checked.")
+	}
+
 	// 2. Compile Reference Solution
 	log.Println("Compiling reference solution...")
 	cmd := exec.Command("g++", "-O2", solPath, "-o", binPath)
@@ -65,6 +82,16 @@ func BakeTests(args []string) {
 		}
 		fIn.Close()
 
+		// Reject inputs that break the constraints before they become tests
+		if val != nil {
+			if err := val.check(inFile); err != nil {
+				fmt.Println()
+				os.Remove(binPath)
+				val.cleanup()
+				log.Fatalf("Generator produced an invalid test %d (%s, seed %d, left for inspection): %v", i, inFile, seed, err)
+			}
+		}
+
 		// B. Run Solution -> .out
 		// cmd: ./solution < [i].in > [i].out
 		solCmd := exec.Command(binPath)
@@ -84,4 +111,5 @@ func BakeTests(args []string) {
 		fmt.Printf("\rGenerated Test %d/%d", i, count)
 	}
 	fmt.Println("\nDONE. Tests saved to", testDir)
+	logCLI(audit.ProblemEdit, "problem "+idStr, fmt.Sprintf("baked %d tests from generator.py (seed %d)", count, seedBase))
 }
@@ -0,0 +1,38 @@
+package tasks
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ifuaslaerl/Judge/internal/audit"
+	"github.com/ifuaslaerl/Judge/internal/data"
+)
+
+// newFlagSet returns a flag set for a command; -h prints its usage line and flags
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: judge %s %s\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// confirm asks before a destructive action; yes (the -yes flag) skips the question.
+// Anything but "yes" (including a closed stdin) declines.
+func confirm(question string, yes bool) bool {
+	if yes {
+		return true
+	}
+	fmt.Printf("%s Type \"yes\" to continue: ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimSpace(answer) == "yes"
+}
+
+// logCLI records a console command in the audit log, as actor audit.CLI
+func logCLI(action, target, details string) {
+	audit.New(data.NewSQLiteStores(data.DB)).Log(audit.CLI, action, target, details)
+}
@@ -0,0 +1,92 @@
+package tasks
+
+import (
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ifuaslaerl/Judge/internal/data"
+	"github.com/ifuaslaerl/Judge/internal/engine"
+	"github.com/ifuaslaerl/Judge/internal/export"
+)
+
+// ExportEventFeed writes the CLICS event feed (NDJSON) for the ICPC Resolver
+// Usage: export-feed [-o file] [-start RFC3339] [-duration 5h] [-freeze 1h] [-penalty 20] [-name "..."]
+func ExportEventFeed(args []string) {
+	defaults := export.DefaultFeedOptions()
+
+	fs := flag.NewFlagSet("export-feed", flag.ExitOnError)
+	out := fs.String("o", "event-feed.ndjson", "Output file ('-' for stdout)")
+	start := fs.String("start", "", "Contest start time, RFC3339 (default: first submission)")
+	duration := fs.Duration("duration", defaults.Duration, "Contest length")
+	freeze := fs.Duration("freeze", defaults.Freeze, "Scoreboard freeze before the end (0 disables)")
+	penalty := fs.Int("penalty", defaults.Penalty, "Penalty minutes per rejected attempt")
+	name := fs.String("name", defaults.Name, "Contest name")
+	fs.Parse(args)
+
+	opts := defaults
+	opts.Duration = *duration
+	opts.Freeze = *freeze
+	opts.Penalty = *penalty
+	opts.Name = *name
+	if *start != "" {
+		t, err := time.Parse(time.RFC3339, *start)
+		if err != nil {
+			log.Fatalf("Invalid -start %q: %v", *start, err)
+		}
+		opts.Start = t
+	}
+
+	w := os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", *out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := export.WriteEventFeed(w, opts); err != nil {
+		log.Fatalf("Event feed export failed: %v", err)
+	}
+	if *out != "-" {
+		log.Printf("SUCCESS: Event feed written to %s", *out)
+	}
+}
+
+// ExportStandings archives the current scoreboard as CSV, JSON or static HTML
+// Usage: export-standings [-format csv|json|html] [-o file]
+func ExportStandings(args []string) {
+	fs := flag.NewFlagSet("export-standings", flag.ExitOnError)
+	format := fs.String("format", export.FormatHTML, "Output format: csv, json or html")
+	out := fs.String("o", "", "Output file ('-' for stdout, default standings.[format])")
+	fs.Parse(args)
+
+	if *out == "" {
+		*out = "standings." + *format
+	}
+
+	board, err := engine.NewJudge(data.NewSQLiteStores(data.DB)).GetScoreboard()
+	if err != nil {
+		log.Fatalf("Failed to calculate standings: %v", err)
+	}
+
+	w := os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", *out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := export.WriteStandings(w, board, *format); err != nil {
+		log.Fatalf("Standings export failed: %v", err)
+	}
+	if *out != "-" {
+		log.Printf("SUCCESS: Standings written to %s", *out)
+	}
+}
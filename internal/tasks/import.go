@@ -0,0 +1,51 @@
+package tasks
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ifuaslaerl/Judge/internal/audit"
+	"github.com/ifuaslaerl/Judge/internal/importer"
+)
+
+// ImportProblem installs a Polygon package (zip or directory) or a Kattis problem directory
+// Usage: problem import [-letter C] [-time-limit ms] [-color red] <package>
+func ImportProblem(args []string) {
+	fs := newFlagSet("problem import", "[-letter C] [-time-limit ms] [-color red] <package.zip | directory>")
+	letter := fs.String("letter", "", "Problem letter (default: next free letter)")
+	timeLimit := fs.Int("time-limit", 0, "Time limit in ms (default: from the package)")
+	color := fs.String("color", "", "Balloon color: CSS color name or #rrggbb (default: from the package)")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	// log.Fatal skips deferred calls, so the extracted zip is removed inside importPackage
+	id, err := importPackage(fs.Arg(0), importer.Options{Letter: *letter, TimeLimitMs: *timeLimit, Color: *color})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("SUCCESS: Imported as problem %d (storage/problems/%d)", id, id)
+}
+
+func importPackage(path string, opts importer.Options) (int64, error) {
+	pkg, cleanup, err := importer.Read(path)
+	defer cleanup()
+	if err != nil {
+		return 0, fmt.Errorf("Cannot read package: %v", err)
+	}
+	log.Printf("Read %s package %q: %d samples, %d tests, checker: %v",
+		pkg.Format, pkg.Name, len(pkg.Samples), len(pkg.Tests), pkg.Checker != nil)
+	if pkg.Statement == "" && pkg.Markdown == "" {
+		log.Println("WARNING: No PDF or Markdown statement in the package; the problem page will have no statement")
+	}
+
+	id, err := importer.Install(pkg, opts)
+	if err != nil {
+		return 0, fmt.Errorf("Import failed: %v", err)
+	}
+	logCLI(audit.ProblemEdit, fmt.Sprintf("problem %d", id), fmt.Sprintf("imported %s package %q from %s", pkg.Format, pkg.Name, path))
+	return id, nil
+}
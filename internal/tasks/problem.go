@@ -0,0 +1,131 @@
+package tasks
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ifuaslaerl/Judge/internal/audit"
+	"github.com/ifuaslaerl/Judge/internal/data"
+	"github.com/ifuaslaerl/Judge/internal/engine"
+	"github.com/ifuaslaerl/Judge/internal/importer"
+	"github.com/ifuaslaerl/Judge/internal/statement"
+)
+
+// AddProblem creates an empty problem: the row plus storage/problems/[id] with
+// samples/ and tests/ to fill by hand, with bake or with a test plan.
+// Usage: problem add [-letter C] [-name "Title"] [-color red] [-pdf statement.pdf] -time-limit ms
+func AddProblem(args []string) {
+	fs := newFlagSet("problem add", `[-letter C] [-name "Title"] [-color red] [-pdf statement.pdf] -time-limit ms`)
+	letter := fs.String("letter", "", "Problem letter (default: next free letter)")
+	name := fs.String("name", "", "Problem title")
+	color := fs.String("color", "", "Balloon color: CSS color name or #rrggbb")
+	pdf := fs.String("pdf", "", "PDF statement to copy into the problem directory")
+	timeLimit := fs.Int("time-limit", 0, "Time limit in ms (required)")
+	fs.Parse(args)
+	if fs.NArg() != 0 || *timeLimit <= 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *pdf != "" && !fileExists(*pdf) {
+		log.Fatalf("%s does not exist", *pdf)
+	}
+
+	balloon, err := importer.ParseColor(*color)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	code, err := importer.ChooseLetter(strings.ToUpper(*letter))
+	if err != nil {
+		log.Fatal(err)
+	}
+	res, err := data.DB.Exec("INSERT INTO problems (letter_code, time_limit, pdf_path, name, color) VALUES (?, ?, '', ?, ?)",
+		code, *timeLimit, *name, balloon)
+	if err != nil {
+		log.Fatalf("DB Error: %v", err)
+	}
+	id, _ := res.LastInsertId()
+	dir := filepath.Join("storage", "problems", strconv.FormatInt(id, 10))
+
+	fail := func(err error) {
+		os.RemoveAll(dir)
+		data.DB.Exec("DELETE FROM problems WHERE id = ?", id)
+		log.Fatalf("Could not create problem: %v", err)
+	}
+	for _, sub := range []string{"samples", "tests"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			fail(err)
+		}
+	}
+	if *pdf != "" {
+		pdfPath := filepath.Join(dir, "statement.pdf")
+		if err := copyPlanFile(*pdf, pdfPath); err != nil {
+			fail(err)
+		}
+		if _, err := data.DB.Exec("UPDATE problems SET pdf_path = ? WHERE id = ?", pdfPath, id); err != nil {
+			fail(err)
+		}
+	}
+
+	logCLI(audit.ProblemEdit, fmt.Sprintf("problem %d", id), fmt.Sprintf("created %s (%dms)", code, *timeLimit))
+	log.Printf("SUCCESS: Created problem %s as %d. Add tests to %s (see \"Problem Tests\" in the README).", code, id, dir)
+}
+
+// ListProblems prints every problem with its test counts and statement/checker setup
+// Usage: problem list
+func ListProblems(args []string) {
+	fs := newFlagSet("problem list", "")
+	fs.Parse(args)
+
+	rows, err := data.DB.Query("SELECT id, letter_code, name, time_limit, pdf_path FROM problems ORDER BY letter_code")
+	if err != nil {
+		log.Fatalf("DB Error: %v", err)
+	}
+	defer rows.Close()
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tLETTER\tNAME\tTIME LIMIT\tSAMPLES\tTESTS\tCHECKER\tSTATEMENT")
+	for rows.Next() {
+		var id, timeLimit int
+		var letter, name, pdfPath string
+		if err := rows.Scan(&id, &letter, &name, &timeLimit, &pdfPath); err != nil {
+			log.Fatalf("DB Error: %v", err)
+		}
+
+		samples, tests := 0, 0
+		for _, t := range engine.LoadTests(id) {
+			if t.Sample {
+				samples++
+			} else {
+				tests++
+			}
+		}
+
+		checker := "default"
+		if c, _ := filepath.Glob(filepath.Join("storage", "problems", strconv.Itoa(id), "checker", "*.cpp")); len(c) > 0 {
+			checker = "custom"
+		}
+
+		var stmt []string
+		for _, f := range []string{"statement.md", "statement.html"} {
+			if fileExists(filepath.Join(statement.Dir(id), f)) {
+				stmt = append(stmt, strings.TrimPrefix(filepath.Ext(f), "."))
+				break
+			}
+		}
+		if pdfPath != "" {
+			stmt = append(stmt, "pdf")
+		}
+		if len(stmt) == 0 {
+			stmt = append(stmt, "none")
+		}
+
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%dms\t%d\t%d\t%s\t%s\n", id, letter, name, timeLimit, samples, tests, checker, strings.Join(stmt, "+"))
+	}
+	tw.Flush()
+}
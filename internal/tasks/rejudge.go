@@ -0,0 +1,112 @@
+package tasks
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ifuaslaerl/Judge/internal/audit"
+	"github.com/ifuaslaerl/Judge/internal/data"
+)
+
+// Rejudge resets the selected submissions to PENDING. The running server picks
+// them up within a few seconds (engine.StartPendingSweep), or on its next start.
+// Usage: rejudge [-problem A] [-user name] [-status WA] [-all] [-yes] [submission ID...]
+func Rejudge(args []string) {
+	fs := newFlagSet("rejudge", "[-problem A] [-user name] [-status WA] [-all] [-yes] [submission ID...]")
+	problem := fs.String("problem", "", "Only submissions to this problem letter")
+	user := fs.String("user", "", "Only submissions by this username")
+	status := fs.String("status", "", "Only submissions with this verdict (AC, WA, TLE, RTE, CE, IE)")
+	all := fs.Bool("all", false, "Select every submission (required when no other selector is given)")
+	yes := fs.Bool("yes", false, "Do not ask for confirmation")
+	fs.Parse(args)
+
+	// The same filter as the admin submission browser, so "-status WA" also
+	// matches "WA on test 3"
+	stores := data.NewSQLiteStores(data.DB)
+	filter := data.SubmissionFilter{Verdict: *status}
+	var desc []string
+	if fs.NArg() > 0 {
+		for _, a := range fs.Args() {
+			id, err := strconv.Atoi(a)
+			if err != nil {
+				log.Fatalf("Invalid submission ID %q", a)
+			}
+			filter.IDs = append(filter.IDs, id)
+		}
+		desc = append(desc, "ids "+strings.Join(fs.Args(), ","))
+	}
+	if *problem != "" {
+		problems, err := stores.Problems.Problems()
+		if err != nil {
+			log.Fatalf("DB Error: %v", err)
+		}
+		for _, p := range problems {
+			if p.Letter == *problem {
+				filter.ProblemID = p.ID
+			}
+		}
+		if filter.ProblemID == 0 {
+			log.Fatalf("Problem %s does not exist", *problem)
+		}
+		desc = append(desc, "problem "+*problem)
+	}
+	if *user != "" {
+		u, err := stores.Users.UserByUsername(*user)
+		if err == data.ErrNotFound {
+			log.Fatalf("User %s does not exist", *user)
+		} else if err != nil {
+			log.Fatalf("DB Error: %v", err)
+		}
+		filter.UserID = u.ID
+		desc = append(desc, "user "+*user)
+	}
+	if *status != "" {
+		desc = append(desc, "status "+*status)
+	}
+	if len(desc) == 0 && !*all {
+		fs.Usage()
+		fmt.Fprintln(os.Stderr, "\nSelect submissions by ID or filter, or pass -all.")
+		os.Exit(2)
+	}
+	if len(desc) == 0 {
+		desc = append(desc, "all")
+	}
+
+	subs, err := stores.Submissions.ListSubmissions(filter)
+	if err != nil {
+		log.Fatalf("DB Error: %v", err)
+	}
+	var ids []interface{}
+	for i := len(subs) - 1; i >= 0; i-- { // Oldest first
+		// Submissions still being uploaded have no file yet
+		if subs[i].FilePath != "" {
+			ids = append(ids, subs[i].ID)
+		}
+	}
+
+	if len(ids) == 0 {
+		log.Fatal("No submissions match.")
+	}
+	if len(ids) > 1 && !confirm(fmt.Sprintf("Rejudge %d submissions (%s)?", len(ids), strings.Join(desc, ", ")), *yes) {
+		log.Fatal("Aborted.")
+	}
+
+	in := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ") + ")"
+	err = execAll([]query{
+		{"DELETE FROM submission_tests WHERE submission_id IN " + in, ids},
+		{"UPDATE submissions SET status = 'PENDING', judged_at = NULL, compile_output = '' WHERE id IN " + in, ids},
+	})
+	if err != nil {
+		log.Fatalf("DB Error: %v", err)
+	}
+
+	target := fmt.Sprintf("submission %d", ids[0])
+	if len(ids) > 1 {
+		target = fmt.Sprintf("%d submissions", len(ids))
+	}
+	logCLI(audit.Rejudge, target, strings.Join(desc, ", "))
+	log.Printf("SUCCESS: %d submissions reset to PENDING; the server judges them again within a few seconds.", len(ids))
+}
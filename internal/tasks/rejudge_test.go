@@ -0,0 +1,44 @@
+package tasks
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/ifuaslaerl/Judge/internal/data"
+)
+
+func TestRejudgeMatchesVerdictPrefix(t *testing.T) {
+	data.OpenDB(filepath.Join(t.TempDir(), "judge.sqlite"))
+	t.Cleanup(func() { data.DB.Close() })
+	if _, err := data.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	_, err := data.DB.Exec(`INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', 'x');
+		INSERT INTO problems (id, letter_code, time_limit, pdf_path) VALUES (1, 'A', 1000, '');
+		INSERT INTO submissions (id, user_id, problem_id, status, file_path) VALUES
+			(1, 1, 1, 'WA on test 3', '1.cpp'),
+			(2, 1, 1, 'WAX', '2.cpp'),
+			(3, 1, 1, 'AC', '3.cpp'),
+			(4, 1, 1, 'WA', '4.cpp'),
+			(5, 1, 1, 'WA on test 1', '');
+		INSERT INTO submission_tests (submission_id, test_number, verdict) VALUES (1, 3, 'WA')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Rejudge([]string{"-status", "WA", "-yes"})
+
+	want := map[int]string{1: "PENDING", 2: "WAX", 3: "AC", 4: "PENDING", 5: "WA on test 1"}
+	for id, status := range want {
+		var got string
+		data.DB.QueryRow("SELECT status FROM submissions WHERE id = ?", id).Scan(&got)
+		if got != status {
+			t.Errorf("submission %d status = %q, want %q", id, got, status)
+		}
+	}
+	var tests int
+	data.DB.QueryRow("SELECT COUNT(*) FROM submission_tests").Scan(&tests)
+	if tests != 0 {
+		t.Errorf("%d test results left after rejudge", tests)
+	}
+}
@@ -0,0 +1,346 @@
+package tasks
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ifuaslaerl/Judge/internal/audit"
+)
+
+// --- Test Plans ---
+// storage/problems/[id]/testplan.txt lists one test per line, in order:
+//
+//   # Comment
+//   sample file manual/1.in         Hand-written sample (copied)
+//   file manual/max.in              Hand-written hidden test
+//   gen_random.py 10 100 7          Generator (.py or .cpp) and its arguments; stdout is the input
+//   sample gen_tree.cpp -n 5 -seed 1
+//
+// Samples are numbered 1..k in samples/, hidden tests 1..m in tests/, in plan
+// order, so the same plan always yields the same file names. Generators get no
+// hidden seed: put seeds in the arguments to keep tests reproducible. Outputs
+// come from solution.cpp; inputs are checked by the validator if there is one.
+
+const generatorTimeout = time.Minute
+
+type planEntry struct {
+	line   int
+	sample bool
+	file   string   // Hand-written input (for "file" lines)
+	gen    string   // Generator source (for generator lines)
+	args   []string // Generator arguments
+	name   string   // Target, e.g. "samples/1" or "tests/12"
+	spec   string   // The plan line, for reports
+}
+
+func parsePlan(path, baseDir string) ([]planEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []planEntry
+	samples, tests := 0, 0
+	sc := bufio.NewScanner(f)
+	for lineNo := 1; sc.Scan(); lineNo++ {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		e := planEntry{line: lineNo, spec: strings.Join(fields, " ")}
+		if fields[0] == "sample" {
+			e.sample = true
+			fields = fields[1:]
+		}
+		switch {
+		case len(fields) == 0:
+			return nil, fmt.Errorf("%s:%d: missing test after 'sample'", path, lineNo)
+		case fields[0] == "file":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("%s:%d: usage: file <path>", path, lineNo)
+			}
+			e.file = filepath.Join(baseDir, fields[1])
+			if !fileExists(e.file) {
+				return nil, fmt.Errorf("%s:%d: %s does not exist", path, lineNo, fields[1])
+			}
+		default:
+			ext := filepath.Ext(fields[0])
+			if ext != ".py" && ext != ".cpp" {
+				return nil, fmt.Errorf("%s:%d: generator %q must be a .py or .cpp file", path, lineNo, fields[0])
+			}
+			e.gen = filepath.Join(baseDir, fields[0])
+			if !fileExists(e.gen) {
+				return nil, fmt.Errorf("%s:%d: %s does not exist", path, lineNo, fields[0])
+			}
+			e.args = fields[1:]
+		}
+
+		if e.sample {
+			samples++
+			e.name = filepath.Join("samples", strconv.Itoa(samples))
+		} else {
+			tests++
+			e.name = filepath.Join("tests", strconv.Itoa(tests))
+		}
+		entries = append(entries, e)
+	}
+	return entries, sc.Err()
+}
+
+// BakePlan builds all tests listed in testplan.txt, in parallel across CPUs.
+// Tests are staged and only replace samples/ and tests/ once every one succeeded.
+func BakePlan(idStr string) {
+	baseDir := filepath.Join("storage", "problems", idStr)
+	planPath := filepath.Join(baseDir, "testplan.txt")
+	solPath := filepath.Join(baseDir, "solution.cpp")
+
+	entries, err := parsePlan(planPath, baseDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(entries) == 0 {
+		log.Fatalf("%s lists no tests", planPath)
+	}
+	if !fileExists(solPath) {
+		log.Fatalf("Missing solution.cpp in %s", baseDir)
+	}
+
+	buildDir, err := os.MkdirTemp("", "bake_")
+	if err != nil {
+		log.Fatal(err)
+	}
+	var val *validator
+	// fatal cleans up before exiting (log.Fatal skips deferred calls)
+	fatal := func(format string, v ...interface{}) {
+		os.RemoveAll(buildDir)
+		if val != nil {
+			val.cleanup()
+		}
+		log.Fatalf(format, v...)
+	}
+	defer os.RemoveAll(buildDir)
+
+	// 1. Compile the solution and every C++ generator once
+	log.Println("Compiling reference solution and generators...")
+	solBin := filepath.Join(buildDir, "solution")
+	if out, err := exec.Command("g++", "-O2", "-std=c++17", solPath, "-o", solBin).CombinedOutput(); err != nil {
+		fatal("Compilation of solution.cpp failed:\n%s", out)
+	}
+	generators := make(map[string][]string) // Source -> command
+	for _, e := range entries {
+		if e.gen == "" || generators[e.gen] != nil {
+			continue
+		}
+		if filepath.Ext(e.gen) == ".py" {
+			generators[e.gen] = []string{"python3", e.gen}
+			continue
+		}
+		bin := filepath.Join(buildDir, fmt.Sprintf("gen_%d", len(generators)))
+		if out, err := exec.Command("g++", "-O2", "-std=c++17", "-I", filepath.Dir(e.gen), e.gen, "-o", bin).CombinedOutput(); err != nil {
+			fatal("Compilation of %s failed:\n%s", e.gen, out)
+		}
+		generators[e.gen] = []string{bin}
+	}
+
+	val, err = loadValidator(baseDir)
+	if err != nil {
+		fatal("%v", err)
+	}
+	if val != nil {
+		defer val.cleanup()
+	}
+
+	// 2. Build every test in a worker pool
+	stageDir := filepath.Join(buildDir, "stage")
+	os.MkdirAll(filepath.Join(stageDir, "samples"), 0755)
+	os.MkdirAll(filepath.Join(stageDir, "tests"), 0755)
+
+	workers := runtime.NumCPU()
+	log.Printf("Baking %d tests from %s with %d workers...", len(entries), planPath, workers)
+
+	errs := make([]error, len(entries))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = bakeEntry(entries[i], stageDir, generators, solBin, val)
+			}
+		}()
+	}
+	for i := range entries {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	failed := 0
+	for i, err := range errs {
+		if err != nil {
+			log.Printf("FAILED line %d (%s): %v", entries[i].line, entries[i].spec, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		fatal("%d of %d tests failed; existing tests were left unchanged", failed, len(entries))
+	}
+
+	// 3. Swap in the new tests (a directory the plan does not produce is kept)
+	for _, dir := range []string{"samples", "tests"} {
+		staged := filepath.Join(stageDir, dir)
+		if files, _ := filepath.Glob(filepath.Join(staged, "*.in")); len(files) == 0 {
+			continue
+		}
+		target := filepath.Join(baseDir, dir)
+		if err := os.RemoveAll(target); err != nil {
+			fatal("Could not replace %s: %v", target, err)
+		}
+		if err := moveDir(staged, target); err != nil {
+			fatal("Could not replace %s: %v", target, err)
+		}
+	}
+
+	printPlanSummary(entries, baseDir)
+	logCLI(audit.ProblemEdit, "problem "+idStr, fmt.Sprintf("baked %d tests from testplan.txt", len(entries)))
+}
+
+// bakeEntry produces one .in (copy or generator) and its .out in stageDir
+func bakeEntry(e planEntry, stageDir string, generators map[string][]string, solBin string, val *validator) error {
+	inPath := filepath.Join(stageDir, e.name+".in")
+	outPath := filepath.Join(stageDir, e.name+".out")
+
+	// A. Input
+	if e.file != "" {
+		if err := copyPlanFile(e.file, inPath); err != nil {
+			return err
+		}
+	} else {
+		command := append(append([]string{}, generators[e.gen]...), e.args...) // Shared map value: copy
+		if err := runToFile(command, "", inPath); err != nil {
+			return fmt.Errorf("generator: %v", err)
+		}
+	}
+
+	// B. Validation
+	if val != nil {
+		if err := val.check(inPath); err != nil {
+			return fmt.Errorf("invalid input: %v", err)
+		}
+	}
+
+	// C. Output from the reference solution
+	if err := runToFile([]string{solBin}, inPath, outPath); err != nil {
+		return fmt.Errorf("reference solution: %v", err)
+	}
+	return nil
+}
+
+// runToFile runs a command with optional stdin file, writing stdout to outPath
+func runToFile(command []string, inPath, outPath string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), generatorTimeout)
+	defer cancel()
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	var stderr strings.Builder
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdout = out
+	cmd.Stderr = &stderr
+	if inPath != "" {
+		in, err := os.Open(inPath)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		cmd.Stdin = in
+	}
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("timed out after %v", generatorTimeout)
+		}
+		return fmt.Errorf("%v %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func copyPlanFile(src, dst string) error {
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, b, 0644)
+}
+
+// moveDir renames src to dst, falling back to copying across filesystems (/tmp)
+func moveDir(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	files, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := copyPlanFile(filepath.Join(src, f.Name()), filepath.Join(dst, f.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printPlanSummary(entries []planEntry, baseDir string) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TEST\tINPUT\tOUTPUT\tSOURCE")
+	var totalIn, totalOut int64
+	for _, e := range entries {
+		in := fileSize(filepath.Join(baseDir, e.name+".in"))
+		out := fileSize(filepath.Join(baseDir, e.name+".out"))
+		totalIn += in
+		totalOut += out
+		fmt.Fprintf(tw, "%s\t%s\t%s\tline %d: %s\n", e.name, humanSize(in), humanSize(out), e.line, e.spec)
+	}
+	fmt.Fprintf(tw, "TOTAL\t%s\t%s\t%d tests\n", humanSize(totalIn), humanSize(totalOut), len(entries))
+	tw.Flush()
+	fmt.Println("DONE. Tests saved to", baseDir)
+}
+
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+func humanSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
@@ -0,0 +1,117 @@
+package tasks
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// --- Input Validator ---
+// Optional storage/problems/[id]/validator.py or validator.cpp. It reads one
+// test input on stdin and exits 0 (or 42, Kattis convention) if the input
+// respects the constraints; any other exit code rejects it. Whatever it prints
+// (stderr, then stdout) is reported as the reason.
+
+type validator struct {
+	cmd     []string
+	cleanup func()
+}
+
+// loadValidator compiles the problem's validator. Returns nil if there is none.
+func loadValidator(baseDir string) (*validator, error) {
+	if py := filepath.Join(baseDir, "validator.py"); fileExists(py) {
+		return &validator{cmd: []string{"python3", py}, cleanup: func() {}}, nil
+	}
+
+	src := filepath.Join(baseDir, "validator.cpp")
+	if !fileExists(src) {
+		return nil, nil
+	}
+	bin := filepath.Join(baseDir, "validator_exec")
+	cmd := exec.Command("g++", "-O2", "-std=c++17", "-I", baseDir, src, "-o", bin)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("validator compilation failed:\n%s", out)
+	}
+	return &validator{cmd: []string{bin}, cleanup: func() { os.Remove(bin) }}, nil
+}
+
+// check runs the validator on one input file; the error explains the rejection
+func (v *validator) check(inPath string) error {
+	in, err := os.Open(inPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(v.cmd[0], v.cmd[1:]...)
+	cmd.Stdin = in
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+
+	var exitErr *exec.ExitError
+	if err == nil || (errors.As(err, &exitErr) && exitErr.ExitCode() == 42) {
+		return nil
+	}
+	if !errors.As(err, &exitErr) {
+		return fmt.Errorf("validator could not run: %v", err)
+	}
+
+	reason := strings.TrimSpace(stderr.String() + "\n" + stdout.String())
+	if reason == "" {
+		reason = "no message"
+	}
+	return fmt.Errorf("exit code %d: %s", exitErr.ExitCode(), reason)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// ValidateTests checks every sample and test input of a problem
+// Usage: validate-tests [problemID]
+func ValidateTests(args []string) {
+	if len(args) != 1 {
+		log.Fatal("Usage: validate-tests [problemID]")
+	}
+	baseDir := filepath.Join("storage", "problems", args[0])
+
+	v, err := loadValidator(baseDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if v == nil {
+		log.Fatalf("No validator.py or validator.cpp in %s", baseDir)
+	}
+	defer v.cleanup()
+
+	var inputs []string
+	for _, dir := range []string{"samples", "tests"} {
+		files, _ := filepath.Glob(filepath.Join(baseDir, dir, "*.in"))
+		inputs = append(inputs, files...)
+	}
+	if len(inputs) == 0 {
+		log.Fatalf("No .in files under %s/samples or %s/tests", baseDir, baseDir)
+	}
+
+	// Report every invalid file, not just the first
+	invalid := 0
+	for _, in := range inputs {
+		if err := v.check(in); err != nil {
+			log.Printf("INVALID %s: %v", in, err)
+			invalid++
+		}
+	}
+	if invalid > 0 {
+		v.cleanup()
+		log.Fatalf("FAILED: %d of %d inputs are invalid", invalid, len(inputs))
+	}
+	log.Printf("SUCCESS: All %d inputs of problem %s are valid", len(inputs), args[0])
+}
@@ -1,15 +1,32 @@
 package tasks
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"github.com/ifuaslaerl/Judge/internal/audit"
 	"github.com/ifuaslaerl/Judge/internal/data"
 )
 
+// Wipe asks for confirmation, then runs WipeAll
+// Usage: wipe [-yes]
+func Wipe(args []string) {
+	fs := newFlagSet("wipe", "[-yes]")
+	yes := fs.Bool("yes", false, "Do not ask for confirmation")
+	fs.Parse(args)
+
+	if !confirm("This deletes ALL submissions, users, sessions and API tokens.", *yes) {
+		log.Fatal("Aborted.")
+	}
+	WipeAll()
+}
+
 // WipeAll implements the "Weekly Wipe" maintenance logic
 // 1. Deletes all files in storage/submissions
 // 2. Wipes DB tables: submissions, sessions, users
+// The audit log is kept (the wipe itself is recorded in it). A running server
+// rebuilds its scoreboard at its next pending sweep.
 func WipeAll() {
 	log.Println("WARNING: STARTING WEEKLY WIPE. THIS IS DESTRUCTIVE.")
 
@@ -41,7 +58,10 @@ func WipeAll() {
 	// We DO NOT delete from 'problems', effectively resetting the contest
 	// but keeping the problem set definition.
 	queries := []string{
+		"DELETE FROM balloons",
+		"DELETE FROM submission_tests",
 		"DELETE FROM submissions",
+		"DELETE FROM api_tokens",
 		"DELETE FROM sessions",
 		"DELETE FROM users",
 		"VACUUM", // Optional: Rebuild DB file to reclaim space
@@ -53,5 +73,6 @@ func WipeAll() {
 		}
 	}
 
+	logCLI(audit.Wipe, "", fmt.Sprintf("deleted %d submission files", deletedCount))
 	log.Println("SUCCESS: System successfully wiped (Users, Sessions, Submissions).")
 }